@@ -70,6 +70,7 @@ func main() {
 	editConfig := flag.Bool("e", false, "Edit config")
 	subFlag := flag.Bool("sub", false, "Watch sub version")
 	dubFlag := flag.Bool("dub", false, "Watch dub version")
+	profile := internal.RegisterProfileFlag(flag.CommandLine)
 
 	// Custom help/usage function
 	flag.Usage = func() {
@@ -81,6 +82,25 @@ func main() {
 
 	flag.Parse()
 
+	// The flags above default to the main config, the profile is loaded now
+	// and the flags given are applied again on top of it
+	if *profile != "" {
+		given := make(map[string]string)
+		flag.Visit(func(f *flag.Flag) {
+			given[f.Name] = f.Value.String()
+		})
+		userCurdConfig, err = internal.LoadProfileConfig(configFilePath, *profile)
+		if err != nil {
+			fmt.Println("Error loading profile:", err)
+			return
+		}
+		for name, value := range given {
+			flag.Set(name, value)
+		}
+		logFile = filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "debug.log")
+		internal.ClearLogFile(logFile)
+	}
+
 	anime.Ep.ContinueLast = *continueLast
 
 	if *updateScript {
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// DefaultProfile uses the config file and storage path as they are
+const DefaultProfile = "default"
+
+// profilesDirName is the directory holding the profiles, next to the config
+// file for their overrides and in the storage path for their data
+const profilesDirName = "profiles"
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// RegisterProfileFlag adds the -profile flag, its value is given to
+// LoadProfileConfig once the flags are parsed
+func RegisterProfileFlag(fs *flag.FlagSet) *string {
+	return fs.String("profile", "", "Profile to use, each one has its own Anilist account, history and config overrides")
+}
+
+// ValidProfileName is true for names usable as a file name on every system
+func ValidProfileName(name string) bool {
+	return profileNamePattern.MatchString(name)
+}
+
+func isDefaultProfile(profile string) bool {
+	return profile == "" || profile == DefaultProfile
+}
+
+// ProfileStoragePath is where the token and history of a profile are kept
+func ProfileStoragePath(baseStoragePath, profile string) string {
+	if isDefaultProfile(profile) {
+		return baseStoragePath
+	}
+	return filepath.Join(baseStoragePath, profilesDirName, profile)
+}
+
+func profileConfigPath(configPath, profile string) string {
+	return filepath.Join(filepath.Dir(os.ExpandEnv(configPath)), profilesDirName, profile+".conf")
+}
+
+// LoadProfileConfig loads the config with the overrides of the profile on
+// top. The storage path always is the one of the profile.
+func LoadProfileConfig(configPath, profile string) (CurdConfig, error) {
+	baseConfig, err := LoadConfig(configPath)
+	if err != nil || isDefaultProfile(profile) {
+		return baseConfig, err
+	}
+	if !ValidProfileName(profile) {
+		return CurdConfig{}, fmt.Errorf("invalid profile name %q", profile)
+	}
+
+	configMap, err := loadConfigFromFile(os.ExpandEnv(configPath))
+	if err != nil {
+		return CurdConfig{}, fmt.Errorf("error loading config file: %v", err)
+	}
+	overrides, err := loadConfigFromFile(profileConfigPath(configPath, profile))
+	if os.IsNotExist(err) {
+		return CurdConfig{}, fmt.Errorf("profile %s doesn't exist", profile)
+	}
+	if err != nil {
+		return CurdConfig{}, fmt.Errorf("error loading profile %s: %v", profile, err)
+	}
+
+	for key, value := range overrides {
+		configMap[key] = value
+	}
+	configMap["StoragePath"] = ProfileStoragePath(baseConfig.StoragePath, profile)
+	return populateConfig(configMap), nil
+}
@@ -1,7 +1,9 @@
 package curdInteg
 
 import (
+	"AnimeGUI/verniy"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,6 +15,21 @@ import (
 	"strings"
 )
 
+var anilistClient = verniy.New()
+
+// SetAnilistClient shares the verniy client (and its rate limiter) used by the caller
+func SetAnilistClient(client *verniy.Client) {
+	anilistClient = client
+}
+
+// anilistClientWithToken returns a copy of the shared client that sends the given token
+// The copy keeps the same rate limiter so every request still counts against it
+func anilistClientWithToken(token string) *verniy.Client {
+	client := *anilistClient
+	client.AccessToken = token
+	return &client
+}
+
 // FindKeyByValue searches for a key associated with a given value in a map[string]string
 func FindKeyByValue(m map[string]string, value string) (string, error) {
 	for key, val := range m {
@@ -60,7 +77,7 @@ func GetAnimeMapPreview(animeList AnimeList) map[string]RofiSelectPreview {
 	populateMap := func(entries []Entry) {
 		for _, entry := range entries {
 			// Only include entries with a non-empty English title
-			Log(fmt.Sprintf("AnimeNameLanguage: ", userCurdConfig.AnimeNameLanguage), logFile)
+			Log(fmt.Sprint("AnimeNameLanguage: ", userCurdConfig.AnimeNameLanguage), logFile)
 			if entry.Media.Title.English != "" && userCurdConfig.AnimeNameLanguage == "english" {
 				animeMap[strconv.Itoa(entry.Media.ID)] = RofiSelectPreview{
 					Title:      entry.Media.Title.English,
@@ -86,7 +103,7 @@ func GetAnimeMapPreview(animeList AnimeList) map[string]RofiSelectPreview {
 }
 
 // SearchAnimeAnilist sends the query to AniList and returns a map of title to ID
-func SearchAnimeAnilistPreview(query, token string) (map[string]RofiSelectPreview, error) {
+func SearchAnimeAnilistPreview(ctx context.Context, query, token string) (map[string]RofiSelectPreview, error) {
 	url := "https://graphql.anilist.co"
 
 	queryString := `
@@ -115,7 +132,10 @@ func SearchAnimeAnilistPreview(query, token string) (map[string]RofiSelectPrevie
 		return nil, fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new request: %w", err)
 	}
@@ -166,7 +186,7 @@ func SearchAnimeAnilistPreview(query, token string) (map[string]RofiSelectPrevie
 }
 
 // SearchAnimeAnilist sends the query to AniList and returns a map of title to ID
-func SearchAnimeAnilist(query, token string) (map[string]string, error) {
+func SearchAnimeAnilist(ctx context.Context, query, token string) (map[string]string, error) {
 	url := "https://graphql.anilist.co"
 
 	queryString := `
@@ -192,7 +212,10 @@ func SearchAnimeAnilist(query, token string) (map[string]string, error) {
 		return nil, fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new request: %w", err)
 	}
@@ -240,7 +263,7 @@ func SearchAnimeAnilist(query, token string) (map[string]string, error) {
 }
 
 // Function to get AniList user ID and username
-func GetAnilistUserID(token string) (int, string, error) {
+func GetAnilistUserID(ctx context.Context, token string) (int, string, error) {
 	url := "https://graphql.anilist.co"
 	query := `
 	query {
@@ -256,7 +279,7 @@ func GetAnilistUserID(token string) (int, string, error) {
 		"Accept":        "application/json",
 	}
 
-	response, err := makePostRequest(url, query, nil, headers)
+	response, err := makePostRequest(ctx, url, query, nil, headers)
 	if err != nil {
 		return 0, "", err
 	}
@@ -268,27 +291,40 @@ func GetAnilistUserID(token string) (int, string, error) {
 	return userID, userName, nil
 }
 
-// Function to add an anime to the watching list
-func AddAnimeToWatchingList(animeID int, token string) error {
-	url := "https://graphql.anilist.co"
-	mutation := `
-	mutation ($mediaId: Int) {
-		SaveMediaListEntry (mediaId: $mediaId, status: CURRENT) {
-			id
-			status
-		}
-	}`
+// ValidateToken checks the token with a Viewer query and fills the user with
+// the account owning it
+func ValidateToken(ctx context.Context, token string, user *User) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
 
-	variables := map[string]interface{}{
-		"mediaId": animeID,
+	viewer, err := anilistClientWithToken(token).GetViewerWithContext(ctx)
+	if err != nil {
+		return fmt.Errorf("invalid token: %w", err)
+	}
+	if viewer.ID == 0 {
+		return fmt.Errorf("invalid token: no user returned")
 	}
 
-	headers := map[string]string{
-		"Authorization": "Bearer " + token,
-		"Content-Type":  "application/json",
+	user.Token = token
+	user.Id = viewer.ID
+	user.Username = viewer.Name
+	user.AvatarURL = ""
+	if viewer.Avatar != nil {
+		if viewer.Avatar.Medium != nil {
+			user.AvatarURL = *viewer.Avatar.Medium
+		} else if viewer.Avatar.Large != nil {
+			user.AvatarURL = *viewer.Avatar.Large
+		}
 	}
+	return nil
+}
 
-	_, err := makePostRequest(url, mutation, variables, headers)
+// Function to add an anime to the watching list
+func AddAnimeToWatchingList(ctx context.Context, animeID int, token string) error {
+	_, err := anilistClientWithToken(token).SaveMediaListEntryWithContext(ctx, verniy.MediaListInput{
+		MediaID: animeID,
+		Status:  verniy.MediaListStatusCurrent,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to add anime: %w", err)
 	}
@@ -298,7 +334,7 @@ func AddAnimeToWatchingList(animeID int, token string) error {
 }
 
 // Function to get MAL ID using AniList media ID
-func GetAnimeMalID(anilistMediaID int) (int, error) {
+func GetAnimeMalID(ctx context.Context, anilistMediaID int) (int, error) {
 	url := "https://graphql.anilist.co"
 	query := `
 	query ($id: Int) {
@@ -311,7 +347,7 @@ func GetAnimeMalID(anilistMediaID int) (int, error) {
 		"id": anilistMediaID,
 	}
 
-	response, err := makePostRequest(url, query, variables, nil)
+	response, err := makePostRequest(ctx, url, query, variables, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -321,7 +357,7 @@ func GetAnimeMalID(anilistMediaID int) (int, error) {
 }
 
 // This function retrieves the MAL ID and cover image URL for an anime from AniList
-func GetAnimeIDAndImage(anilistMediaID int) (int, string, error) {
+func GetAnimeIDAndImage(ctx context.Context, anilistMediaID int) (int, string, error) {
 	url := "https://graphql.anilist.co"
 	query := `
 	query ($id: Int) {
@@ -337,7 +373,7 @@ func GetAnimeIDAndImage(anilistMediaID int) (int, string, error) {
 		"id": anilistMediaID,
 	}
 
-	response, err := makePostRequest(url, query, variables, nil)
+	response, err := makePostRequest(ctx, url, query, variables, nil)
 	if err != nil {
 		return 0, "", err
 	}
@@ -350,7 +386,7 @@ func GetAnimeIDAndImage(anilistMediaID int) (int, string, error) {
 }
 
 // Function to get user data from AniList
-func GetUserData(token string, userID int) (map[string]interface{}, error) {
+func GetUserData(ctx context.Context, token string, userID int) (map[string]interface{}, error) {
 	query := fmt.Sprintf(`
 	{
 		MediaListCollection(userId: %d, type: ANIME) {
@@ -379,7 +415,7 @@ func GetUserData(token string, userID int) (map[string]interface{}, error) {
 		"Content-Type":  "application/json",
 	}
 
-	response, err := makePostRequest("https://graphql.anilist.co", query, nil, headers)
+	response, err := makePostRequest(ctx, "https://graphql.anilist.co", query, nil, headers)
 	if err != nil {
 		return nil, err
 	}
@@ -387,7 +423,7 @@ func GetUserData(token string, userID int) (map[string]interface{}, error) {
 	return response, nil
 }
 
-func GetUserDataPreview(token string, userID int) (map[string]interface{}, error) {
+func GetUserDataPreview(ctx context.Context, token string, userID int) (map[string]interface{}, error) {
 	query := fmt.Sprintf(`
 	{
 		MediaListCollection(userId: %d, type: ANIME) {
@@ -419,7 +455,7 @@ func GetUserDataPreview(token string, userID int) (map[string]interface{}, error
 		"Content-Type":  "application/json",
 	}
 
-	response, err := makePostRequest("https://graphql.anilist.co", query, nil, headers)
+	response, err := makePostRequest(ctx, "https://graphql.anilist.co", query, nil, headers)
 	if err != nil {
 		return nil, err
 	}
@@ -475,27 +511,11 @@ func SearchAnimeByTitle(jsonData map[string]interface{}, searchTitle string) []m
 }
 
 // Function to update anime progress
-func UpdateAnimeProgress(token string, mediaID, progress int) error {
-	url := "https://graphql.anilist.co"
-	query := `
-	mutation($mediaId: Int, $progress: Int) {
-		SaveMediaListEntry(mediaId: $mediaId, progress: $progress) {
-			id
-			progress
-		}
-	}`
-
-	variables := map[string]interface{}{
-		"mediaId":  mediaID,
-		"progress": progress,
-	}
-
-	headers := map[string]string{
-		"Authorization": "Bearer " + token,
-		"Content-Type":  "application/json",
-	}
-
-	_, err := makePostRequest(url, query, variables, headers)
+func UpdateAnimeProgress(ctx context.Context, token string, mediaID, progress int) error {
+	_, err := anilistClientWithToken(token).SaveMediaListEntryWithContext(ctx, verniy.MediaListInput{
+		MediaID:  mediaID,
+		Progress: &progress,
+	})
 	if err != nil {
 		return err
 	}
@@ -504,27 +524,11 @@ func UpdateAnimeProgress(token string, mediaID, progress int) error {
 	return nil
 }
 
-func UpdateAnimeStatus(token string, mediaID int, status string) error {
-	url := "https://graphql.anilist.co"
-	query := `
-	mutation($mediaId: Int, $status: MediaListStatus) {
-		SaveMediaListEntry(mediaId: $mediaId, status: $status) {
-			id
-			status
-		}
-	}`
-
-	variables := map[string]interface{}{
-		"mediaId": mediaID,
-		"status":  status,
-	}
-
-	headers := map[string]string{
-		"Authorization": "Bearer " + token,
-		"Content-Type":  "application/json",
-	}
-
-	_, err := makePostRequest(url, query, variables, headers)
+func UpdateAnimeStatus(ctx context.Context, token string, mediaID int, status string) error {
+	_, err := anilistClientWithToken(token).SaveMediaListEntryWithContext(ctx, verniy.MediaListInput{
+		MediaID: mediaID,
+		Status:  verniy.MediaListStatus(status),
+	})
 	if err != nil {
 		return fmt.Errorf("failed to update anime status: %w", err)
 	}
@@ -542,7 +546,7 @@ func UpdateAnimeStatus(token string, mediaID int, status string) error {
 }
 
 // Function to rate an anime on AniList
-func RateAnime(token string, mediaID int) error {
+func RateAnime(ctx context.Context, token string, mediaID int) error {
 	var score float64
 	var err error
 
@@ -565,27 +569,10 @@ func RateAnime(token string, mediaID int) error {
 		fmt.Scanln(&score)
 	}
 
-	url := "https://graphql.anilist.co"
-	query := `
-	mutation($mediaId: Int, $score: Float) {
-		SaveMediaListEntry(mediaId: $mediaId, score: $score) {
-			id
-			mediaId
-			score
-		}
-	}`
-
-	variables := map[string]interface{}{
-		"mediaId": mediaID,
-		"score":   score,
-	}
-
-	headers := map[string]string{
-		"Authorization": "Bearer " + token,
-		"Content-Type":  "application/json",
-	}
-
-	_, err = makePostRequest(url, query, variables, headers)
+	_, err = anilistClientWithToken(token).SaveMediaListEntryWithContext(ctx, verniy.MediaListInput{
+		MediaID: mediaID,
+		Score:   &score,
+	})
 	if err != nil {
 		return err
 	}
@@ -595,7 +582,7 @@ func RateAnime(token string, mediaID int) error {
 }
 
 // Helper function to make POST requests
-func makePostRequest(url, query string, variables map[string]interface{}, headers map[string]string) (map[string]interface{}, error) {
+func makePostRequest(ctx context.Context, url, query string, variables map[string]interface{}, headers map[string]string) (map[string]interface{}, error) {
 	requestBody, err := json.Marshal(map[string]interface{}{
 		"query":     query,
 		"variables": variables,
@@ -604,7 +591,10 @@ func makePostRequest(url, query string, variables map[string]interface{}, header
 		return nil, fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -763,7 +753,7 @@ func FindAnimeByAnilistIDInAnimes(animes []Anime, anilistID int) (*Anime, error)
 }
 
 // GetAnimeDataByID retrieves detailed anime data from AniList using the anime's ID and user token
-func GetAnimeDataByID(anilistID int, token string) (Anime, error) {
+func GetAnimeDataByID(ctx context.Context, anilistID int, token string) (Anime, error) {
 	query := `
 	query ($id: Int) {
 		Media (id: $id, type: ANIME) {
@@ -789,7 +779,10 @@ func GetAnimeDataByID(anilistID int, token string) (Anime, error) {
 		"variables": variables,
 	})
 
-	req, err := http.NewRequest("POST", "https://graphql.anilist.co", bytes.NewBuffer(jsonValue))
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://graphql.anilist.co", bytes.NewBuffer(jsonValue))
 	if err != nil {
 		return Anime{}, fmt.Errorf("error creating request: %v", err)
 	}
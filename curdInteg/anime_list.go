@@ -1,6 +1,7 @@
 package curdInteg
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/charmbracelet/log"
@@ -35,14 +36,22 @@ type response struct {
 // 	query := "one piece"
 
 // 	// Search anime
-// 	animeList, err := SearchAnime(string(query), mode)
+// 	animeList, err := SearchAnime(context.Background(), string(query), mode)
 // 	if err != nil {
 
 // 	}
 // 	fmt.Println(animeList)
 // }
 
-func SearchAnime(query, mode string) (map[string]string, error) {
+// SearchAnime searches the primary provider, see Provider.Search.
+func SearchAnime(ctx context.Context, query, mode string) (map[string]string, error) {
+	return PrimaryProvider().Search(ctx, query, mode)
+}
+
+func (p *allanimeProvider) Search(ctx context.Context, query, mode string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
 	userCurdConfig := GetGlobalConfig()
 	var logFile string
 	if userCurdConfig == nil {
@@ -50,13 +59,6 @@ func SearchAnime(query, mode string) (map[string]string, error) {
 	} else {
 		logFile = filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "debug.log")
 	}
-	const (
-		agent        = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/121.0"
-		allanimeRef  = "https://allanime.to"
-		allanimeBase = "allanime.day"
-		allanimeAPI  = "https://api." + allanimeBase + "/api"
-	)
-
 	// Prepare the anime list
 	animeList := make(map[string]string)
 
@@ -81,7 +83,7 @@ func SearchAnime(query, mode string) (map[string]string, error) {
 		},
 		"limit":           40,
 		"page":            1,
-		"translationType": mode,
+		"translationType": p.translationType(mode),
 		"countryOrigin":   "ALL",
 	}
 
@@ -93,16 +95,15 @@ func SearchAnime(query, mode string) (map[string]string, error) {
 	}
 
 	// Build the request URL
-	url := fmt.Sprintf("%s?variables=%s&query=%s", allanimeAPI, url.QueryEscape(string(variablesJSON)), url.QueryEscape(searchGql))
+	url := fmt.Sprintf("%s?variables=%s&query=%s", p.api, url.QueryEscape(string(variablesJSON)), url.QueryEscape(searchGql))
 
 	// Make the HTTP request
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		log.Error(fmt.Sprintf("Error creating HTTP request: %v", err))
 		return animeList, err
 	}
-	req.Header.Set("User-Agent", agent)
-	req.Header.Set("Referer", allanimeRef)
+	p.setHeaders(req)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -134,7 +135,7 @@ func SearchAnime(query, mode string) (map[string]string, error) {
 	for _, anime := range response.Data.Shows.Edges {
 		var episodesStr string
 		if episodes, ok := anime.AvailableEpisodes.(map[string]interface{}); ok {
-			if subEpisodes, ok := episodes["sub"].(float64); ok {
+			if subEpisodes, ok := episodes[p.translationType(mode)].(float64); ok {
 				episodesStr = fmt.Sprintf("%d", int(subEpisodes))
 			} else {
 				log.Error(subEpisodes)
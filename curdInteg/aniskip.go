@@ -1,6 +1,7 @@
 package curdInteg
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -26,13 +27,21 @@ type skipInterval struct {
 }
 
 // GetAniSkipData fetches skip times data for a given anime ID and episode
-func GetAniSkipData(animeMalId int, episode int) (string, error) {
+func GetAniSkipData(ctx context.Context, animeMalId int, episode int) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
 	baseURL := "https://api.aniskip.com/v1/skip-times"
 	url := fmt.Sprintf("%s/%d/%d?types=op&types=ed", baseURL, animeMalId, episode)
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		Log(fmt.Sprintf("error fetching data from AniSkip API: %w", err), logFile)
+		Log(fmt.Sprintf("error fetching data from AniSkip API: %v", err), logFile)
 		return "", fmt.Errorf("error fetching data from AniSkip API: %w", err)
 	}
 	defer resp.Body.Close()
@@ -44,7 +53,7 @@ func GetAniSkipData(animeMalId int, episode int) (string, error) {
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		Log(fmt.Sprintf("failed to read response body: %w", err), logFile)
+		Log(fmt.Sprintf("failed to read response body: %v", err), logFile)
 		return "", fmt.Errorf("failed to read response body %w", err)
 	}
 
@@ -94,8 +103,8 @@ func ParseAniSkipResponse(responseText string, anime *Anime, timePrecision int)
 }
 
 // GetAndParseAniSkipData fetches and parses skip times for a given anime ID and episode
-func GetAndParseAniSkipData(animeMalId int, episode int, timePrecision int, anime *Anime) error {
-	responseText, err := GetAniSkipData(animeMalId, episode)
+func GetAndParseAniSkipData(ctx context.Context, animeMalId int, episode int, timePrecision int, anime *Anime) error {
+	responseText, err := GetAniSkipData(ctx, animeMalId, episode)
 	if err != nil {
 		return err
 	}
@@ -17,10 +17,12 @@ type CurdConfig struct {
 	Player                   string `config:"Player"`
 	SubsLanguage             string `config:"SubsLanguage"`
 	SubOrDub                 string `config:"SubOrDub"`
+	Providers                string `config:"Providers"`
 	StoragePath              string `config:"StoragePath"`
 	AnimeNameLanguage        string `config:"AnimeNameLanguage"`
 	PercentageToMarkComplete int    `config:"PercentageToMarkComplete"`
 	NextEpisodePrompt        bool   `config:"NextEpisodePrompt"`
+	BingeMode                bool   `config:"BingeMode"`
 	SkipOp                   bool   `config:"SkipOp"`
 	SkipEd                   bool   `config:"SkipEd"`
 	SkipFiller               bool   `config:"SkipFiller"`
@@ -31,6 +33,12 @@ type CurdConfig struct {
 	ScoreOnCompletion        bool   `config:"ScoreOnCompletion"`
 	SaveMpvSpeed             bool   `config:"SaveMpvSpeed"`
 	DiscordPresence          bool   `config:"DiscordPresence"`
+	AiringNotifications      bool   `config:"AiringNotifications"`
+	NotifyWhenAvailable      bool   `config:"NotifyWhenAvailable"`
+	AnilistClientID          string `config:"AnilistClientID"`
+	AnilistAuthorizeURL      string `config:"AnilistAuthorizeURL"`
+	OAuthRedirectPort        int    `config:"OAuthRedirectPort"`
+	Profile                  string `config:"Profile"`
 }
 
 // Default configuration values as a map
@@ -41,8 +49,10 @@ func defaultConfigMap() map[string]string {
 		"AnimeNameLanguage":        "english",
 		"SubsLanguage":             "english",
 		"SubOrDub":                 "sub",
+		"Providers":                "allanime",
 		"PercentageToMarkComplete": "85",
 		"NextEpisodePrompt":        "false",
+		"BingeMode":                "false",
 		"SkipOp":                   "true",
 		"SkipEd":                   "true",
 		"SkipFiller":               "true",
@@ -52,6 +62,12 @@ func defaultConfigMap() map[string]string {
 		"ScoreOnCompletion":        "true",
 		"SaveMpvSpeed":             "true",
 		"DiscordPresence":          "true",
+		"AiringNotifications":      "true",
+		"NotifyWhenAvailable":      "false",
+		"AnilistClientID":          PasteClientID,
+		"AnilistAuthorizeURL":      AnilistAuthorizeURL,
+		"OAuthRedirectPort":        "47219",
+		"Profile":                  DefaultProfile,
 	}
 }
 
@@ -242,6 +258,33 @@ func saveConfigToFile(path string, configMap map[string]string) error {
 	return writer.Flush()
 }
 
+// SaveConfig writes the config struct back to the config file, keys that
+// aren't part of CurdConfig are kept as they are
+func SaveConfig(configPath string, config CurdConfig) error {
+	configPath = os.ExpandEnv(configPath)
+	configMap, err := loadConfigFromFile(configPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("error loading config file: %v", err)
+		}
+		configMap = make(map[string]string)
+	}
+
+	configValue := reflect.ValueOf(config)
+	for i := 0; i < configValue.NumField(); i++ {
+		tag := configValue.Type().Field(i).Tag.Get("config")
+		if tag == "" {
+			continue
+		}
+		configMap[tag] = fmt.Sprintf("%v", configValue.Field(i).Interface())
+	}
+
+	if err := saveConfigToFile(configPath, configMap); err != nil {
+		return fmt.Errorf("error saving config file: %v", err)
+	}
+	return nil
+}
+
 // Populate the CurdConfig struct from a map
 func populateConfig(configMap map[string]string) CurdConfig {
 	config := CurdConfig{}
@@ -0,0 +1,298 @@
+package curdInteg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/charmbracelet/x/term"
+	"golang.org/x/crypto/pbkdf2"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	keyringService = "curd"
+	keyringAccount = "anilist-token"
+
+	encryptedTokenName = "token.enc"
+	// pbkdf2Iterations follows the OWASP advice for PBKDF2-HMAC-SHA256
+	pbkdf2Iterations = 600000
+)
+
+var (
+	// ErrNoCredential is returned when no token was saved
+	ErrNoCredential = errors.New("no token saved")
+	// ErrPassphraseNeeded is returned when the keyring can't be used and the
+	// passphrase of the encrypted token file wasn't given
+	ErrPassphraseNeeded = errors.New("passphrase needed for the encrypted token")
+	// ErrWrongPassphrase is returned when the encrypted token can't be decrypted
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+	// errKeyringUnavailable is returned by the keyring functions when there is
+	// no keyring on this system
+	errKeyringUnavailable = errors.New("keyring unavailable")
+)
+
+// PassphrasePrompt asks the passphrase of the encrypted token file, confirm
+// is true when a new file is created. The GUI sets it to nil and gives the
+// passphrase with SetTokenPassphrase instead.
+var PassphrasePrompt func(confirm bool) (string, error) = terminalPassphrase
+
+var (
+	passphraseMutex sync.Mutex
+	tokenPassphrase string
+)
+
+// SetTokenPassphrase gives the passphrase used for the encrypted token file
+func SetTokenPassphrase(passphrase string) {
+	passphraseMutex.Lock()
+	tokenPassphrase = passphrase
+	passphraseMutex.Unlock()
+}
+
+func getTokenPassphrase(confirm bool) (string, error) {
+	passphraseMutex.Lock()
+	defer passphraseMutex.Unlock()
+	if tokenPassphrase != "" {
+		return tokenPassphrase, nil
+	}
+	if PassphrasePrompt == nil {
+		return "", ErrPassphraseNeeded
+	}
+	passphrase, err := PassphrasePrompt(confirm)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", ErrPassphraseNeeded
+	}
+	tokenPassphrase = passphrase
+	return passphrase, nil
+}
+
+func terminalPassphrase(confirm bool) (string, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return "", ErrPassphraseNeeded
+	}
+	fmt.Print("No keyring available, passphrase for the Anilist token: ")
+	passphrase, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		fmt.Print("Confirm the passphrase: ")
+		again, err := term.ReadPassword(os.Stdin.Fd())
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		if string(again) != string(passphrase) {
+			return "", errors.New("passphrases don't match")
+		}
+	}
+	return string(passphrase), nil
+}
+
+// CredentialStore keeps the Anilist token in the OS keyring, or in a
+// passphrase encrypted file next to the config when there is none
+type CredentialStore struct {
+	account       string
+	encryptedFile string
+}
+
+// NewCredentialStore returns the store of the token saved in the storage
+// path, each profile has its own keyring entry
+func NewCredentialStore(storagePath string) *CredentialStore {
+	account := keyringAccount
+	if profile := profileOfStoragePath(storagePath); !isDefaultProfile(profile) {
+		account += "-" + profile
+	}
+	return &CredentialStore{
+		account:       account,
+		encryptedFile: filepath.Join(os.ExpandEnv(storagePath), encryptedTokenName),
+	}
+}
+
+// Get returns ErrNoCredential when no token was saved
+func (s *CredentialStore) Get() (string, error) {
+	token, err := keyringGet(keyringService, s.account)
+	if err == nil {
+		return token, nil
+	}
+	if !errors.Is(err, ErrNoCredential) && !errors.Is(err, errKeyringUnavailable) {
+		Log(fmt.Sprint("Keyring read failed: ", err), logFile)
+	}
+
+	if _, statErr := os.Stat(s.encryptedFile); statErr != nil {
+		return "", ErrNoCredential
+	}
+	return s.readEncrypted()
+}
+
+// Set saves the token in the keyring, falling back to the encrypted file
+func (s *CredentialStore) Set(token string) error {
+	err := keyringSet(keyringService, s.account, token)
+	if err == nil {
+		// Some keyring tools don't report every failure, read it back
+		if saved, getErr := keyringGet(keyringService, s.account); getErr != nil || saved != token {
+			err = fmt.Errorf("token not found in the keyring after saving it: %v", getErr)
+		}
+	}
+	if err == nil {
+		// Don't leave an older token behind
+		os.Remove(s.encryptedFile)
+		return nil
+	}
+	Log(fmt.Sprint("Keyring write failed, using the encrypted file: ", err), logFile)
+	return s.writeEncrypted(token)
+}
+
+// Delete removes the token from every place it can be saved
+func (s *CredentialStore) Delete() error {
+	err := keyringDelete(keyringService, s.account)
+	if err != nil && (errors.Is(err, ErrNoCredential) || errors.Is(err, errKeyringUnavailable)) {
+		err = nil
+	}
+	if removeErr := os.Remove(s.encryptedFile); removeErr != nil && !os.IsNotExist(removeErr) && err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+type encryptedToken struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+func (s *CredentialStore) readEncrypted() (string, error) {
+	data, err := os.ReadFile(s.encryptedFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read encrypted token: %w", err)
+	}
+	var file encryptedToken
+	if err := json.Unmarshal(data, &file); err != nil {
+		return "", fmt.Errorf("failed to parse encrypted token: %w", err)
+	}
+
+	passphrase, err := getTokenPassphrase(false)
+	if err != nil {
+		return "", err
+	}
+	aead, err := tokenCipher(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return "", err
+	}
+	token, err := aead.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		// The passphrase is kept otherwise, every later read would fail too
+		SetTokenPassphrase("")
+		return "", ErrWrongPassphrase
+	}
+	return string(token), nil
+}
+
+func (s *CredentialStore) writeEncrypted(token string) error {
+	_, statErr := os.Stat(s.encryptedFile)
+	passphrase, err := getTokenPassphrase(statErr != nil)
+	if err != nil {
+		return err
+	}
+
+	file := encryptedToken{Version: 1, Iterations: pbkdf2Iterations, Salt: make([]byte, 16)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	aead, err := tokenCipher(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Data = aead.Seal(nil, file.Nonce, []byte(token), nil)
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.encryptedFile), 0700); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+	if err := os.WriteFile(s.encryptedFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write encrypted token: %w", err)
+	}
+	return nil
+}
+
+func tokenCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	if iterations <= 0 {
+		return nil, errors.New("invalid encrypted token")
+	}
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(passphrase), salt, iterations, 32, sha256.New))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// GetTokenFromFile returns the saved token. A plain text token left at
+// filePath by older versions is moved to the credential store.
+func GetTokenFromFile(filePath string) (string, error) {
+	store := NewCredentialStore(filepath.Dir(filePath))
+	token, err := store.Get()
+	if err == nil {
+		return token, nil
+	}
+	if !errors.Is(err, ErrNoCredential) {
+		return "", err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token from file: %w", err)
+	}
+	token = strings.TrimSpace(string(data))
+	if token == "" {
+		return "", nil
+	}
+
+	if err := store.Set(token); err != nil {
+		// Still usable, the migration is tried again on the next start
+		Log(fmt.Sprint("Failed to migrate the plain text token: ", err), logFile)
+		return token, nil
+	}
+	if err := os.Remove(filePath); err != nil {
+		Log(fmt.Sprint("Failed to remove the plain text token: ", err), logFile)
+	}
+	return token, nil
+}
+
+// WriteTokenToFile saves the token in the credential store of the directory
+// of filePath, the plain text file itself is no longer written
+func WriteTokenToFile(token string, filePath string) error {
+	if err := NewCredentialStore(filepath.Dir(filePath)).Set(token); err != nil {
+		return fmt.Errorf("failed to save token: %w", err)
+	}
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		Log(fmt.Sprint("Failed to remove the plain text token: ", err), logFile)
+	}
+	return nil
+}
+
+// DeleteToken removes the saved token, wherever it is
+func DeleteToken(filePath string) error {
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return NewCredentialStore(filepath.Dir(filePath)).Delete()
+}
@@ -0,0 +1,133 @@
+package curdInteg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// encryptedStore is a store using only the encrypted file, the keyring of
+// the machine running the tests is left alone
+func encryptedStore(t *testing.T) *CredentialStore {
+	t.Helper()
+	prompt := PassphrasePrompt
+	PassphrasePrompt = nil
+	t.Cleanup(func() {
+		PassphrasePrompt = prompt
+		SetTokenPassphrase("")
+	})
+	return &CredentialStore{account: "test", encryptedFile: filepath.Join(t.TempDir(), encryptedTokenName)}
+}
+
+func TestEncryptedTokenRoundTrip(t *testing.T) {
+	store := encryptedStore(t)
+	SetTokenPassphrase("correct horse")
+	const token = "eyJ0eXAiOiJKV1QiLCJhbGciOiJSUzI1NiJ9.token"
+	if err := store.writeEncrypted(token); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(store.encryptedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("token")) {
+		t.Error("token saved in clear")
+	}
+	var file encryptedToken
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Iterations != pbkdf2Iterations || len(file.Salt) != 16 {
+		t.Errorf("saved with %d iterations and a %d bytes salt", file.Iterations, len(file.Salt))
+	}
+
+	// A new session asks the passphrase again
+	SetTokenPassphrase("")
+	PassphrasePrompt = func(bool) (string, error) { return "correct horse", nil }
+	got, err := store.readEncrypted()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != token {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestEncryptedTokenWrongPassphrase(t *testing.T) {
+	store := encryptedStore(t)
+	SetTokenPassphrase("correct horse")
+	if err := store.writeEncrypted("token"); err != nil {
+		t.Fatal(err)
+	}
+
+	SetTokenPassphrase("battery staple")
+	if _, err := store.readEncrypted(); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("got %v, want a wrong passphrase", err)
+	}
+	// The wrong passphrase is forgotten, without a prompt it is needed again
+	if _, err := store.readEncrypted(); !errors.Is(err, ErrPassphraseNeeded) {
+		t.Errorf("got %v, want the passphrase to be asked again", err)
+	}
+}
+
+func TestEncryptedTokenSaltsDiffer(t *testing.T) {
+	store := encryptedStore(t)
+	SetTokenPassphrase("correct horse")
+	var saved [2]encryptedToken
+	for i := range saved {
+		if err := store.writeEncrypted("token"); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(store.encryptedFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, &saved[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bytes.Equal(saved[0].Salt, saved[1].Salt) || bytes.Equal(saved[0].Data, saved[1].Data) {
+		t.Error("two saves of the same token are identical")
+	}
+}
+
+func TestEncryptedTokenTampered(t *testing.T) {
+	store := encryptedStore(t)
+	SetTokenPassphrase("correct horse")
+	if err := store.writeEncrypted("token"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(store.encryptedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file encryptedToken
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	file.Data[0] ^= 1
+	data, _ = json.Marshal(file)
+	if err := os.WriteFile(store.encryptedFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.readEncrypted(); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("tampered token read: %v", err)
+	}
+}
+
+func TestTokenCipherRejectsNoIterations(t *testing.T) {
+	if _, err := tokenCipher("passphrase", []byte("salt"), 0); err == nil {
+		t.Error("no iterations accepted")
+	}
+}
+
+func TestEncryptedTokenNeedsPassphrase(t *testing.T) {
+	store := encryptedStore(t)
+	if err := store.writeEncrypted("token"); !errors.Is(err, ErrPassphraseNeeded) {
+		t.Errorf("got %v, want the passphrase to be needed", err)
+	}
+}
@@ -1,7 +1,8 @@
 package curdInteg
 
 import (
-	"crypto/md5"
+	"AnimeGUI/imagecache"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/gen2brain/beeep"
@@ -17,18 +18,9 @@ import (
 	"time"
 )
 
-func GetTokenFromFile(filePath string) (string, error) {
-	// Read the token from the file
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read token from file: %w", err)
-	}
-
-	// Convert the byte slice to a string and remove any surrounding whitespace or newlines
-	token := strings.TrimSpace(string(data))
-
-	return token, nil
-}
+// requestTimeout bounds every single network request, the caller context
+// can still cancel earlier.
+const requestTimeout = 15 * time.Second
 
 func EditConfig(configFilePath string) {
 	// Get the user's preferred editor from the EDITOR environment variable
@@ -356,7 +348,7 @@ func UpdateAnimeEntry(userCurdConfig *CurdConfig, user *User, logFile string) {
 			ExitCurd(nil)
 		}
 
-		err = UpdateAnimeStatus(user.Token, animeID, categorySelection.Key)
+		err = UpdateAnimeStatus(context.Background(), user.Token, animeID, categorySelection.Key)
 		if err != nil {
 			Log(fmt.Sprintf("Failed to update anime status: %v", err), logFile)
 			ExitCurd(fmt.Errorf("Failed to update anime status"))
@@ -387,7 +379,7 @@ func UpdateAnimeEntry(userCurdConfig *CurdConfig, user *User, logFile string) {
 			ExitCurd(fmt.Errorf("Failed to convert progress to number"))
 		}
 
-		err = UpdateAnimeProgress(user.Token, animeID, progressNum)
+		err = UpdateAnimeProgress(context.Background(), user.Token, animeID, progressNum)
 		if err != nil {
 			Log(fmt.Sprintf("Failed to update anime progress: %v", err), logFile)
 			ExitCurd(fmt.Errorf("Failed to update anime progress"))
@@ -400,7 +392,7 @@ func UpdateAnimeEntry(userCurdConfig *CurdConfig, user *User, logFile string) {
 		}
 		CurdOut(fmt.Sprintf("Current score: %s", currentScore))
 
-		err = RateAnime(user.Token, animeID)
+		err = RateAnime(context.Background(), user.Token, animeID)
 		if err != nil {
 			Log(fmt.Sprintf("Failed to update anime score: %v", err), logFile)
 			ExitCurd(fmt.Errorf("Failed to update anime score"))
@@ -493,9 +485,9 @@ func AddNewAnime(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseA
 		fmt.Scanln(&query)
 	}
 	if userCurdConfig.RofiSelection && userCurdConfig.ImagePreview {
-		animeMapPreview, err = SearchAnimeAnilistPreview(query, user.Token)
+		animeMapPreview, err = SearchAnimeAnilistPreview(context.Background(), query, user.Token)
 	} else {
-		animeMap, err = SearchAnimeAnilist(query, user.Token)
+		animeMap, err = SearchAnimeAnilist(context.Background(), query, user.Token)
 	}
 	if err != nil {
 		Log(fmt.Sprintf("Failed to search anime: %v", err), logFile)
@@ -520,22 +512,22 @@ func AddNewAnime(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseA
 		Log(fmt.Sprintf("Failed to convert anime ID to integer: %v", err), logFile)
 		ExitCurd(fmt.Errorf("Failed to convert anime ID to integer"))
 	}
-	err = AddAnimeToWatchingList(animeID, user.Token)
+	err = AddAnimeToWatchingList(context.Background(), animeID, user.Token)
 	if err != nil {
 		Log(fmt.Sprintf("Failed to add anime to watching list: %v", err), logFile)
 		ExitCurd(fmt.Errorf("Failed to add anime to watching list"))
 	}
 	if user.Id == 0 {
-		user.Id, user.Username, err = GetAnilistUserID(user.Token)
+		user.Id, user.Username, err = GetAnilistUserID(context.Background(), user.Token)
 		if err != nil {
 			Log(fmt.Sprintf("Failed to get user ID: %v", err), logFile)
 			ExitCurd(fmt.Errorf("Failed to get user ID\nYou can reset the token by running `curd -change-token`"))
 		}
 	}
 	if userCurdConfig.RofiSelection && userCurdConfig.ImagePreview {
-		anilistUserDataPreview, err = GetUserDataPreview(user.Token, user.Id)
+		anilistUserDataPreview, err = GetUserDataPreview(context.Background(), user.Token, user.Id)
 	} else {
-		anilistUserData, err = GetUserData(user.Token, user.Id)
+		anilistUserData, err = GetUserData(context.Background(), user.Token, user.Id)
 	}
 	if err != nil {
 		Log(fmt.Sprintf("Failed to get user data: %v", err), logFile)
@@ -560,7 +552,7 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 	var animeListMapPreview map[string]RofiSelectPreview
 
 	// Get user id, username and Anime list
-	user.Id, user.Username, err = GetAnilistUserID(user.Token)
+	user.Id, user.Username, err = GetAnilistUserID(context.Background(), user.Token)
 	if err != nil {
 		Log(fmt.Sprintf("Failed to get user ID: %v", err), logFile)
 		ExitCurd(fmt.Errorf("Failed to get user ID\nYou can reset the token by running `curd -change-token`"))
@@ -568,14 +560,14 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 
 	// Get the anime list data
 	if userCurdConfig.RofiSelection && userCurdConfig.ImagePreview {
-		anilistUserDataPreview, err = GetUserDataPreview(user.Token, user.Id)
+		anilistUserDataPreview, err = GetUserDataPreview(context.Background(), user.Token, user.Id)
 		if err != nil {
 			Log(fmt.Sprintf("Failed to get user data preview: %v", err), logFile)
 			ExitCurd(fmt.Errorf("Failed to get user data preview"))
 		}
 		user.AnimeList = ParseAnimeList(anilistUserDataPreview)
 	} else {
-		anilistUserData, err = GetUserData(user.Token, user.Id)
+		anilistUserData, err = GetUserData(context.Background(), user.Token, user.Id)
 		if err != nil {
 			Log(fmt.Sprintf("Failed to get user data: %v", err), logFile)
 			ExitCurd(fmt.Errorf("Failed to get user ID\nYou can reset the token by running `curd -change-token`"))
@@ -756,7 +748,7 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 		// Get Anime list (All anime)
 		Log(fmt.Sprintf("Searching for anime with query: %s, SubOrDub: %s", userQuery, userCurdConfig.SubOrDub), logFile)
 
-		animeList, err = SearchAnime(string(userQuery), userCurdConfig.SubOrDub)
+		animeList, err = SearchAnime(context.Background(), string(userQuery), userCurdConfig.SubOrDub)
 		Log(fmt.Sprintf("SearchAnime result - animeList: %+v, err: %v", animeList, err), logFile)
 		if err != nil {
 			Log(fmt.Sprintf("Failed to select anime: %v", err), logFile)
@@ -812,7 +804,7 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 	if anime.TotalEpisodes == 0 {
 		// Get updated anime data
 		Log(selectedAllanimeAnime, logFile)
-		updatedAnime, err := GetAnimeDataByID(anime.AnilistId, user.Token)
+		updatedAnime, err := GetAnimeDataByID(context.Background(), anime.AnilistId, user.Token)
 		Log(updatedAnime, logFile)
 		if err != nil {
 			Log(fmt.Sprintf("Error getting updated anime data: %v", err), logFile)
@@ -824,7 +816,7 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 
 	if anime.TotalEpisodes == 0 { // If failed to get anime data
 		CurdOut("Failed to get anime data. Attempting to retrieve from anime list.")
-		animeList, err := SearchAnime(string(userQuery), userCurdConfig.SubOrDub)
+		animeList, err := SearchAnime(context.Background(), string(userQuery), userCurdConfig.SubOrDub)
 		if err != nil {
 			CurdOut(fmt.Sprintf("Failed to retrieve anime list: %v", err))
 		} else {
@@ -882,32 +874,13 @@ func SetupCurd(userCurdConfig *CurdConfig, anime *Anime, user *User, databaseAni
 
 }
 
-// CreateOrWriteTokenFile creates the token file if it doesn't exist and writes the token to it
-func WriteTokenToFile(token string, filePath string) error {
-	// Extract the directory path
-	dir := filepath.Dir(filePath)
-
-	// Create all necessary parent directories
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directories: %v", err)
-	}
-
-	// Write the token to the file, creating it if it doesn't exist
-	err := os.WriteFile(filePath, []byte(token), 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write token to file: %v", err)
-	}
-
-	return nil
-}
-
 func StartCurd(userCurdConfig *CurdConfig, anime *Anime, logFile string) string {
 
 	// Get episode link
-	link, err := GetEpisodeURL(*userCurdConfig, anime.AllanimeId, anime.Ep.Number)
+	link, err := GetEpisodeURLWithFallback(context.Background(), *userCurdConfig, anime.AllanimeId, GetAnimeName(*anime), anime.Ep.Number)
 	if err != nil {
 		// If unable to get episode link automatically get manually
-		episodeList, err := EpisodesList(anime.AllanimeId, userCurdConfig.SubOrDub)
+		episodeList, err := EpisodesList(context.Background(), anime.AllanimeId, userCurdConfig.SubOrDub)
 		if err != nil {
 			CurdOut("No episode list found")
 			RestoreScreen()
@@ -924,7 +897,7 @@ func StartCurd(userCurdConfig *CurdConfig, anime *Anime, logFile string) string
 			CurdOut(fmt.Sprintf("Enter the episode (%v episodes)", episodeList[len(episodeList)-1]))
 			fmt.Scanln(&anime.Ep.Number)
 		}
-		link, err = GetEpisodeURL(*userCurdConfig, anime.AllanimeId, anime.Ep.Number)
+		link, err = GetEpisodeURLWithFallback(context.Background(), *userCurdConfig, anime.AllanimeId, GetAnimeName(*anime), anime.Ep.Number)
 		if err != nil {
 			CurdOut("Failed to get episode link")
 			os.Exit(1)
@@ -954,13 +927,10 @@ func StartCurd(userCurdConfig *CurdConfig, anime *Anime, logFile string) string
 	// Display starting message with cover image and episode info
 	if anime.CoverImage != "" && userCurdConfig.ImagePreview && userCurdConfig.RofiSelection {
 		// Get the cached image path
-		cacheDir := os.ExpandEnv("${HOME}/.cache/curd/images")
-		filename := fmt.Sprintf("%x.jpg", md5.Sum([]byte(anime.CoverImage)))
-		cachePath := filepath.Join(cacheDir, filename)
+		cachePath, cached := imagecache.Default.Cached(anime.CoverImage)
 
 		// Display the image if it exists in cache
-		_, err := os.Stat(cachePath)
-		if err == nil {
+		if cached {
 			// File exists
 			Log(fmt.Sprintf("Image found at %s", cachePath), logFile)
 			CurdOut(fmt.Sprintf("-i %s \"%s - Episode %d\"", cachePath, GetAnimeName(*anime), anime.Ep.Number))
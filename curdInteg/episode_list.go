@@ -1,6 +1,7 @@
 package curdInteg
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -30,29 +31,29 @@ type episodesResponse struct {
 // 	fmt.Println(episodeList)
 // }
 
-// episodesList performs the API call and fetches the episodes list
-func EpisodesList(showID, mode string) ([]string, error) {
-	const (
-		agent        = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/121.0"
-		allanimeRef  = "https://allanime.to"
-		allanimeBase = "allanime.day"
-		allanimeAPI  = "https://api." + allanimeBase + "/api"
-	)
+// EpisodesList fetches the episodes list from the primary provider
+func EpisodesList(ctx context.Context, showID, mode string) ([]string, error) {
+	return PrimaryProvider().Episodes(ctx, showID, mode)
+}
+
+// Episodes performs the API call and fetches the episodes list
+func (p *allanimeProvider) Episodes(ctx context.Context, showID, mode string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
 
 	episodesListGql := `query ($showId: String!) { show( _id: $showId ) { _id availableEpisodesDetail }}`
 
 	// Build the request URL
-	url := fmt.Sprintf("%s?variables={\"showId\":\"%s\"}&query=%s", allanimeAPI, showID, episodesListGql)
+	url := fmt.Sprintf("%s?variables={\"showId\":\"%s\"}&query=%s", p.api, showID, episodesListGql)
 	episodes := []string{}
 
 	// Make the HTTP request
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		Log(fmt.Sprint("Error creating HTTP request:", err), logFile)
 		return episodes, err
 	}
-	req.Header.Set("User-Agent", agent)
-	req.Header.Set("Referer", allanimeRef)
+	p.setHeaders(req)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -77,7 +78,7 @@ func EpisodesList(showID, mode string) ([]string, error) {
 	}
 
 	// Extract and sort the episodes
-	episodes = extractEpisodes(response.Data.Show.AvailableEpisodesDetail, mode)
+	episodes = extractEpisodes(response.Data.Show.AvailableEpisodesDetail, p.translationType(mode))
 	return episodes, nil
 }
 
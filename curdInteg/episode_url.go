@@ -1,6 +1,7 @@
 package curdInteg
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/charmbracelet/log"
@@ -22,23 +23,14 @@ type allanimeResponse struct {
 	} `json:"data"`
 }
 
-func decodeProviderID(encoded string) string {
+func (p *allanimeProvider) decodeProviderID(encoded string) string {
 	// Split the string into pairs of characters (.. equivalent of 'sed s/../&\n/g')
 	re := regexp.MustCompile("..")
 	pairs := re.FindAllString(encoded, -1)
 
-	// Mapping for the replacements
-	replacements := map[string]string{
-		"01": "9", "08": "0", "05": "=", "0a": "2", "0b": "3", "0c": "4", "07": "?",
-		"00": "8", "5c": "d", "0f": "7", "5e": "f", "17": "/", "54": "l", "09": "1",
-		"48": "p", "4f": "w", "0e": "6", "5b": "c", "5d": "e", "0d": "5", "53": "k",
-		"1e": "&", "5a": "b", "59": "a", "4a": "r", "4c": "t", "4e": "v", "57": "o",
-		"51": "i",
-	}
-
 	// Perform the replacement equivalent to sed 's/^../.../'
 	for i, pair := range pairs {
-		if val, exists := replacements[pair]; exists {
+		if val, exists := p.decodeTable[pair]; exists {
 			pairs[i] = val
 		}
 	}
@@ -53,11 +45,13 @@ func decodeProviderID(encoded string) string {
 	return result
 }
 
-func extractLinks(provider_id string) map[string]interface{} {
-	allanime_base := "https://allanime.day"
-	url := allanime_base + provider_id
+func (p *allanimeProvider) extractLinks(ctx context.Context, provider_id string) map[string]interface{} {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	url := p.base + provider_id
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	var videoData map[string]interface{}
 	if err != nil {
 		Log(fmt.Sprint("Error creating request:", err), logFile)
@@ -65,8 +59,7 @@ func extractLinks(provider_id string) map[string]interface{} {
 	}
 
 	// Add the headers
-	req.Header.Set("Referer", "https://allanime.to")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/121.0")
+	p.setHeaders(req)
 
 	// Send the request
 	resp, err := client.Do(req)
@@ -98,19 +91,24 @@ func extractLinks(provider_id string) map[string]interface{} {
 // If the link is found, it returns a list of links. Otherwise, it returns an error.
 //
 // Parameters:
+// - ctx: Context used to cancel the lookup, each request also gets its own deadline.
 // - config: Configuration of the anime search.
-// - id: Allanime id of the anime to search for.
+// - id: Primary provider id of the anime to search for.
 // - epNo: Anime episode number to get links for.
 //
 // Returns:
 // - []string: a list of links for specified episode.
 // - error: an error if the episode is not found or if there is an issue during the search.
-func GetEpisodeURL(config CurdConfig, id string, epNo int) ([]string, error) {
+func GetEpisodeURL(ctx context.Context, config CurdConfig, id string, epNo int) ([]string, error) {
+	return PrimaryProvider().Sources(ctx, id, config.SubOrDub, epNo)
+}
+
+func (p *allanimeProvider) Sources(ctx context.Context, id, mode string, epNo int) ([]string, error) {
 	query := `query($showId:String!,$translationType:VaildTranslationTypeEnumType!,$episodeString:String!){episode(showId:$showId,translationType:$translationType,episodeString:$episodeString){episodeString sourceUrls}}`
 
 	variables := map[string]string{
 		"showId":          id,
-		"translationType": config.SubOrDub,
+		"translationType": p.translationType(mode),
 		"episodeString":   fmt.Sprintf("%d", epNo),
 	}
 
@@ -123,16 +121,18 @@ func GetEpisodeURL(config CurdConfig, id string, epNo int) ([]string, error) {
 	values.Set("query", query)
 	values.Set("variables", string(variablesJSON))
 
-	reqURL := fmt.Sprintf("%s/api?%s", "https://api.allanime.day", values.Encode())
+	reqURL := fmt.Sprintf("%s?%s", p.api, values.Encode())
+
+	reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
 
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", reqURL, nil)
+	req, err := http.NewRequestWithContext(reqCtx, "GET", reqURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/121.0")
-	req.Header.Set("Referer", "https://allanime.to")
+	p.setHeaders(req)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -158,9 +158,12 @@ func GetEpisodeURL(config CurdConfig, id string, epNo int) ([]string, error) {
 
 	// Iterate through the SourceUrls and print each URL
 	for _, url := range response.Data.Episode.SourceUrls {
+		if ctx.Err() != nil {
+			return allinks, ctx.Err()
+		}
 		if len(url.SourceUrl) > 2 && unicode.IsDigit(rune(url.SourceUrl[2])) { // Source Url 3rd letter is a number (it stars as --32f23k31jk)
-			decodedProviderID := decodeProviderID(url.SourceUrl[2:]) // Decode the source url to get the provider id
-			extractedLinks := extractLinks(decodedProviderID)        // Extract the links using provider id
+			decodedProviderID := p.decodeProviderID(url.SourceUrl[2:]) // Decode the source url to get the provider id
+			extractedLinks := p.extractLinks(ctx, decodedProviderID)   // Extract the links using provider id
 			if linksInterface, ok := extractedLinks["links"].([]interface{}); ok {
 				for _, linkInterface := range linksInterface {
 					if linkMap, ok := linkInterface.(map[string]interface{}); ok {
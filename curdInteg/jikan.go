@@ -1,18 +1,20 @@
 package curdInteg
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 )
 
 // GetEpisodeData fetches episode data for a given anime ID and episode number
-func GetEpisodeData(animeID int, episodeNo int, anime *Anime) error {
+func GetEpisodeData(ctx context.Context, animeID int, episodeNo int, anime *Anime) error {
 	url := fmt.Sprintf("https://api.jikan.moe/v4/anime/%d/episodes/%d", animeID, episodeNo)
 
 	// Use the helper function for making the GET request
-	response, err := makeGetRequest(url, nil)
+	response, err := makeGetRequest(ctx, url, nil)
 	if err != nil {
 		Log(fmt.Sprintf("Warning: Jikan API error: %v - continuing without filler data", err), logFile)
 		// Set default values when API fails
@@ -70,8 +72,11 @@ func GetEpisodeData(animeID int, episodeNo int, anime *Anime) error {
 }
 
 // Helper function to make GET requests
-func makeGetRequest(url string, headers map[string]string) (map[string]interface{}, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func makeGetRequest(ctx context.Context, url string, headers map[string]string) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create GET request: %w", err)
 	}
@@ -104,3 +109,74 @@ func makeGetRequest(url string, headers map[string]string) (map[string]interface
 
 	return responseData, nil
 }
+
+type jikanEpisodesResponse struct {
+	Pagination struct {
+		HasNextPage bool `json:"has_next_page"`
+	} `json:"pagination"`
+	Data []struct {
+		MalID         int    `json:"mal_id"`
+		Title         string `json:"title"`
+		TitleJapanese string `json:"title_japanese"`
+		TitleRomanji  string `json:"title_romanji"`
+		Aired         string `json:"aired"`
+		Filler        bool   `json:"filler"`
+		Recap         bool   `json:"recap"`
+	} `json:"data"`
+}
+
+// GetAnimeEpisodes fetches the metadata of every episode of an anime from
+// Jikan, keyed by episode number. Jikan pages hold 100 episodes.
+func GetAnimeEpisodes(ctx context.Context, animeID int) (map[int]Episode, error) {
+	episodes := make(map[int]Episode)
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("https://api.jikan.moe/v4/anime/%d/episodes?page=%d", animeID, page)
+		reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+		req, err := http.NewRequestWithContext(reqCtx, "GET", url, nil)
+		if err != nil {
+			cancel()
+			return episodes, fmt.Errorf("failed to create GET request: %w", err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			cancel()
+			return episodes, fmt.Errorf("failed to send GET request: %w", err)
+		}
+		var response jikanEpisodesResponse
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("failed with status %d", resp.StatusCode)
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&response)
+		}
+		resp.Body.Close()
+		cancel()
+		if err != nil {
+			return episodes, err
+		}
+
+		for _, ep := range response.Data {
+			episodes[ep.MalID] = Episode{
+				Number: ep.MalID,
+				Title: AnimeTitle{
+					English:  ep.Title,
+					Japanese: ep.TitleJapanese,
+					Romaji:   ep.TitleRomanji,
+				},
+				Aired:    ep.Aired,
+				IsFiller: ep.Filler,
+				IsRecap:  ep.Recap,
+			}
+		}
+
+		if !response.Pagination.HasNextPage {
+			return episodes, nil
+		}
+		// Jikan allows 3 requests per second
+		select {
+		case <-ctx.Done():
+			return episodes, ctx.Err()
+		case <-time.After(time.Second / 3):
+		}
+	}
+}
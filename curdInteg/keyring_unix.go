@@ -0,0 +1,94 @@
+//go:build !windows
+// +build !windows
+
+package curdInteg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// The keyring is used through the tools shipped with it, secret-tool
+// (libsecret) for the Secret Service and security on macOS.
+
+func keyringGet(service, account string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	}
+	out, err := runKeyringCommand(cmd, nil)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(out)
+	if token == "" {
+		return "", ErrNoCredential
+	}
+	return token, nil
+}
+
+func keyringSet(service, account, secret string) error {
+	var cmd *exec.Cmd
+	var stdin string
+	if runtime.GOOS == "darwin" {
+		// Through the interactive mode so the secret isn't in the arguments
+		cmd = exec.Command("security", "-i")
+		stdin = fmt.Sprintf("add-generic-password -U -s %q -a %q -w %q\n", service, account, secret)
+	} else {
+		cmd = exec.Command("secret-tool", "store", "--label=curd Anilist token", "service", service, "account", account)
+		stdin = secret
+	}
+	_, err := runKeyringCommand(cmd, strings.NewReader(stdin))
+	return err
+}
+
+func keyringDelete(service, account string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "delete-generic-password", "-s", service, "-a", account)
+	} else {
+		cmd = exec.Command("secret-tool", "clear", "service", service, "account", account)
+	}
+	_, err := runKeyringCommand(cmd, nil)
+	return err
+}
+
+// runKeyringCommand maps the failures of the keyring tools to
+// errKeyringUnavailable and ErrNoCredential
+func runKeyringCommand(cmd *exec.Cmd, stdin *strings.Reader) (string, error) {
+	// Set when the tool isn't installed
+	if cmd.Err != nil {
+		return "", errKeyringUnavailable
+	}
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		message := strings.TrimSpace(stderr.String())
+		switch {
+		// security exits with errSecItemNotFound
+		case runtime.GOOS == "darwin" && exitErr.ExitCode() == 44:
+			return "", ErrNoCredential
+		// secret-tool exits with 1 and says nothing when the item isn't found
+		case runtime.GOOS != "darwin" && exitErr.ExitCode() == 1 && message == "":
+			return "", ErrNoCredential
+		}
+		return "", fmt.Errorf("%w: %s", errKeyringUnavailable, message)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errKeyringUnavailable, err)
+	}
+	return stdout.String(), nil
+}
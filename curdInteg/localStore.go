@@ -0,0 +1,261 @@
+package curdInteg
+
+import (
+	"AnimeGUI/atomicfile"
+	"bytes"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// localDatabaseVersion is bumped each time localRecord changes in a way
+// older readers can't handle, readLocalDatabase migrates older versions.
+const localDatabaseVersion = 1
+
+const (
+	localDatabaseName       = "curd_history.json"
+	legacyLocalDatabaseName = "curd_history.txt"
+)
+
+const (
+	// localLockTimeout is how long a writer waits for another one to finish
+	localLockTimeout = 5 * time.Second
+	// localLockStale is the age after which a lock file is considered left
+	// behind by a crashed process
+	localLockStale = 30 * time.Second
+)
+
+type localDatabase struct {
+	Version int           `json:"version"`
+	Anime   []localRecord `json:"anime"`
+}
+
+type localRecord struct {
+	AnilistId    int        `json:"anilist_id"`
+	AllanimeId   string     `json:"allanime_id"`
+	Episode      int        `json:"episode"`
+	PlaybackTime int        `json:"playback_time"`
+	Duration     int        `json:"duration"`
+	Name         string     `json:"name"`
+	Speed        float64    `json:"speed,omitempty"`
+	SkipTimes    *SkipTimes `json:"skip_times,omitempty"`
+	SubOrDub     string     `json:"sub_or_dub,omitempty"`
+	LastWatched  time.Time  `json:"last_watched"`
+}
+
+// LocalDatabaseFile returns the path of the local history in the storage path
+func LocalDatabaseFile(storagePath string) string {
+	return filepath.Join(os.ExpandEnv(storagePath), localDatabaseName)
+}
+
+func recordFromAnime(anime Anime) localRecord {
+	record := localRecord{
+		AnilistId:    anime.AnilistId,
+		AllanimeId:   anime.AllanimeId,
+		Episode:      anime.Ep.Number,
+		PlaybackTime: anime.Ep.Player.PlaybackTime,
+		Duration:     anime.Ep.Duration,
+		Name:         anime.Title.English,
+		Speed:        anime.Ep.Player.Speed,
+		SubOrDub:     anime.SubOrDub,
+		LastWatched:  anime.LastWatched,
+	}
+	if record.Name == "" {
+		record.Name = anime.Title.Romaji
+	}
+	if anime.Ep.SkipTimes != (SkipTimes{}) {
+		skipTimes := anime.Ep.SkipTimes
+		record.SkipTimes = &skipTimes
+	}
+	return record
+}
+
+func (r localRecord) toAnime() Anime {
+	anime := Anime{
+		AnilistId:  r.AnilistId,
+		AllanimeId: r.AllanimeId,
+		Title: AnimeTitle{
+			English: r.Name,
+			Romaji:  r.Name,
+		},
+		Ep: Episode{
+			Number: r.Episode,
+			Player: playingVideo{
+				PlaybackTime: r.PlaybackTime,
+				Speed:        r.Speed,
+			},
+			Duration: r.Duration,
+		},
+		SubOrDub:    r.SubOrDub,
+		LastWatched: r.LastWatched,
+	}
+	if r.SkipTimes != nil {
+		anime.Ep.SkipTimes = *r.SkipTimes
+	}
+	return anime
+}
+
+// readLocalDatabase loads the history, falling back to the legacy CSV file
+// next to it. migrated is true when the data didn't come from the current format.
+func readLocalDatabase(databaseFile string) (animeList []Anime, migrated bool, err error) {
+	data, err := os.ReadFile(databaseFile)
+	if os.IsNotExist(err) {
+		legacyFile := filepath.Join(filepath.Dir(databaseFile), legacyLocalDatabaseName)
+		data, err = os.ReadFile(legacyFile)
+		if os.IsNotExist(err) {
+			return []Anime{}, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		animeList, err = parseLegacyDatabase(data)
+		return animeList, true, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return []Anime{}, false, nil
+	}
+	// Old curd history written as CSV at the new path
+	if data[0] != '{' {
+		animeList, err = parseLegacyDatabase(data)
+		return animeList, true, err
+	}
+
+	var db localDatabase
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, false, fmt.Errorf("failed to parse local database: %w", err)
+	}
+	if db.Version > localDatabaseVersion {
+		return nil, false, fmt.Errorf("local database version %d is newer than supported version %d", db.Version, localDatabaseVersion)
+	}
+
+	animeList = make([]Anime, 0, len(db.Anime))
+	for _, record := range db.Anime {
+		animeList = append(animeList, record.toAnime())
+	}
+	return animeList, db.Version < localDatabaseVersion, nil
+}
+
+// parseLegacyDatabase reads the CSV layout used before the versioned format
+func parseLegacyDatabase(data []byte) ([]Anime, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse legacy database: %w", err)
+	}
+
+	animeList := []Anime{}
+	for _, row := range records {
+		anime := parseAnimeRow(row)
+		if anime != nil {
+			animeList = append(animeList, *anime)
+		}
+	}
+	return animeList, nil
+}
+
+// writeLocalDatabase replaces the history atomically, a crash leaves either
+// the old or the new file but never a truncated one.
+func writeLocalDatabase(databaseFile string, animeList []Anime) error {
+	db := localDatabase{Version: localDatabaseVersion, Anime: make([]localRecord, 0, len(animeList))}
+	for _, anime := range animeList {
+		db.Anime = append(db.Anime, recordFromAnime(anime))
+	}
+	data, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode local database: %w", err)
+	}
+
+	if err := atomicfile.Write(databaseFile, data); err != nil {
+		return fmt.Errorf("failed to replace local database: %w", err)
+	}
+	return nil
+}
+
+// lockLocalDatabase takes the lock file shared by every curd and GUI process
+// and returns the function releasing it.
+func lockLocalDatabase(databaseFile string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(databaseFile), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// The token tells our lock apart from the one of a process that took
+	// over after finding ours stale
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to create lock token: %w", err)
+	}
+	token := fmt.Sprintf("%d-%s", os.Getpid(), hex.EncodeToString(random))
+
+	lockPath := databaseFile + ".lock"
+	deadline := time.Now().Add(localLockTimeout)
+	for {
+		lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = lock.WriteString(token)
+			if closeErr := lock.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(lockPath)
+				return nil, fmt.Errorf("failed to write lock file: %w", err)
+			}
+			return func() { releaseLock(lockPath, token) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > localLockStale {
+			Log("Removing stale local database lock", logFile)
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("local database is locked by another process")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// releaseLock removes the lock file only while it still holds our token
+func releaseLock(lockPath, token string) {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return
+	}
+	if string(data) != token {
+		Log("Local database lock was taken over, leaving it", logFile)
+		return
+	}
+	os.Remove(lockPath)
+}
+
+// updateLocalDatabase runs a read-modify-write of the history under the lock
+func updateLocalDatabase(databaseFile string, update func([]Anime) []Anime) ([]Anime, error) {
+	unlock, err := lockLocalDatabase(databaseFile)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	animeList, _, err := readLocalDatabase(databaseFile)
+	if err != nil {
+		return nil, err
+	}
+	animeList = update(animeList)
+	if err := writeLocalDatabase(databaseFile, animeList); err != nil {
+		return nil, err
+	}
+	return animeList, nil
+}
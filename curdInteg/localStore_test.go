@@ -0,0 +1,219 @@
+package curdInteg
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestMain keeps the package log out of the source tree
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "curd-test")
+	if err != nil {
+		panic(err)
+	}
+	logFile = filepath.Join(dir, "debug.log")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestLocalGetAllAnimeMigratesLegacyHistory(t *testing.T) {
+	dir := t.TempDir()
+	legacy := "21,ReooPAxPMsHM4KPMY,3,120,1420,One Piece\n" +
+		"1535,5LLCkgRGKCqcsGjK7,7,60,Death Note\n"
+	legacyFile := filepath.Join(dir, legacyLocalDatabaseName)
+	if err := os.WriteFile(legacyFile, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	databaseFile := LocalDatabaseFile(dir)
+	animeList := LocalGetAllAnime(databaseFile)
+	if len(animeList) != 2 {
+		t.Fatalf("got %d entries, want 2", len(animeList))
+	}
+	first := animeList[0]
+	if first.AnilistId != 21 || first.AllanimeId != "ReooPAxPMsHM4KPMY" || first.Ep.Number != 3 ||
+		first.Ep.Player.PlaybackTime != 120 || first.Ep.Duration != 1420 || first.Title.English != "One Piece" {
+		t.Errorf("six column row parsed as %+v", first)
+	}
+	second := animeList[1]
+	if second.AnilistId != 1535 || second.Ep.Duration != 0 || second.Title.English != "Death Note" {
+		t.Errorf("five column row parsed as %+v", second)
+	}
+
+	data, err := os.ReadFile(databaseFile)
+	if err != nil {
+		t.Fatalf("history not migrated: %v", err)
+	}
+	var db localDatabase
+	if err := json.Unmarshal(data, &db); err != nil {
+		t.Fatal(err)
+	}
+	if db.Version != localDatabaseVersion || len(db.Anime) != 2 {
+		t.Errorf("migrated file has version %d and %d entries", db.Version, len(db.Anime))
+	}
+	if kept, _ := os.ReadFile(legacyFile); string(kept) != legacy {
+		t.Error("legacy history was modified")
+	}
+}
+
+func TestReadLocalDatabaseCSVAtNewPath(t *testing.T) {
+	databaseFile := LocalDatabaseFile(t.TempDir())
+	if err := os.WriteFile(databaseFile, []byte("5114,abc,12,0,1440,Fullmetal Alchemist\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	animeList, migrated, err := readLocalDatabase(databaseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !migrated || len(animeList) != 1 || animeList[0].AnilistId != 5114 {
+		t.Errorf("got %+v, migrated %v", animeList, migrated)
+	}
+}
+
+func TestReadLocalDatabaseRejectsNewerVersion(t *testing.T) {
+	databaseFile := LocalDatabaseFile(t.TempDir())
+	data := fmt.Sprintf(`{"version":%d,"anime":[]}`, localDatabaseVersion+1)
+	if err := os.WriteFile(databaseFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readLocalDatabase(databaseFile); err == nil {
+		t.Error("a newer database was read")
+	}
+}
+
+func TestReadLocalDatabaseMissing(t *testing.T) {
+	animeList, migrated, err := readLocalDatabase(LocalDatabaseFile(t.TempDir()))
+	if err != nil || migrated || len(animeList) != 0 {
+		t.Errorf("got %v, %v, %v", animeList, migrated, err)
+	}
+}
+
+func TestLocalUpdateAnimeKeepsSavedFields(t *testing.T) {
+	databaseFile := LocalDatabaseFile(t.TempDir())
+	anime := newLocalAnime(1, "a", 2, 30, 1400, "Show")
+	anime.Ep.Player.Speed = 1.5
+	anime.Ep.SkipTimes = SkipTimes{Op: Skip{Start: 10, End: 100}}
+	if err, _ := LocalSaveAnime(databaseFile, anime); err != nil {
+		t.Fatal(err)
+	}
+	LocalAddAnime(databaseFile, 2, "b", 1, 0, 0, "Other")
+
+	err, animeList := LocalUpdateAnime(databaseFile, 1, "a", 3, 0, 1400, "Show")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(animeList) != 2 || animeList[1].AnilistId != 1 {
+		t.Fatalf("updated entry isn't last: %+v", animeList)
+	}
+
+	saved := LocalFindAnime(LocalGetAllAnime(databaseFile), 1, "a")
+	if saved == nil {
+		t.Fatal("entry lost")
+	}
+	if saved.Ep.Number != 3 || saved.Ep.Player.Speed != 1.5 || saved.Ep.SkipTimes.Op.End != 100 {
+		t.Errorf("saved as %+v", saved.Ep)
+	}
+
+	LocalDeleteAnime(databaseFile, 1, "a")
+	if animeList := LocalGetAllAnime(databaseFile); len(animeList) != 1 || animeList[0].AnilistId != 2 {
+		t.Errorf("after delete %+v", animeList)
+	}
+}
+
+func TestUpdateLocalDatabaseConcurrentWriters(t *testing.T) {
+	databaseFile := LocalDatabaseFile(t.TempDir())
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 1; i <= writers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			LocalUpdateAnime(databaseFile, id, fmt.Sprint(id), 1, 0, 0, "Show")
+		}(i)
+	}
+	wg.Wait()
+
+	if animeList := LocalGetAllAnime(databaseFile); len(animeList) != writers {
+		t.Errorf("got %d entries, want %d", len(animeList), writers)
+	}
+	if _, err := os.Stat(databaseFile + ".lock"); !os.IsNotExist(err) {
+		t.Error("lock file left behind")
+	}
+}
+
+func TestLockLocalDatabaseRemovesStaleLock(t *testing.T) {
+	databaseFile := LocalDatabaseFile(t.TempDir())
+	lockPath := databaseFile + ".lock"
+	if err := os.WriteFile(lockPath, []byte("1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * localLockStale)
+	if err := os.Chtimes(lockPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := lockLocalDatabase(databaseFile)
+	if err != nil {
+		t.Fatalf("stale lock not taken over: %v", err)
+	}
+	unlock()
+}
+
+func TestLockLocalDatabaseWaitsForHolder(t *testing.T) {
+	databaseFile := LocalDatabaseFile(t.TempDir())
+	unlock, err := lockLocalDatabase(databaseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		close(released)
+		unlock()
+	}()
+
+	second, err := lockLocalDatabase(databaseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second()
+	select {
+	case <-released:
+	default:
+		t.Error("lock taken while still held")
+	}
+}
+
+func TestUnlockLeavesLockTakenOver(t *testing.T) {
+	databaseFile := LocalDatabaseFile(t.TempDir())
+	lockPath := databaseFile + ".lock"
+	unlock, err := lockLocalDatabase(databaseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process found the lock stale and took it over
+	if err := os.WriteFile(lockPath, []byte("4242-other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+	if data, err := os.ReadFile(lockPath); err != nil || string(data) != "4242-other" {
+		t.Fatalf("lock of the other process removed: %q, %v", data, err)
+	}
+	os.Remove(lockPath)
+
+	unlock, err = lockLocalDatabase(databaseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Error("own lock not removed")
+	}
+}
@@ -1,33 +1,17 @@
 package curdInteg
 
 import (
-	"encoding/csv"
+	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strconv"
 	"time"
 )
 
 // Function to add an anime entry
 func LocalAddAnime(databaseFile string, anilistID int, allanimeID string, watchingEpisode int, watchingTime int, animeDuration int, animeName string) {
-	file, err := os.OpenFile(databaseFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		CurdOut(fmt.Sprintf("Error opening file: %v", err))
-		return
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	err = writer.Write([]string{
-		strconv.Itoa(anilistID),
-		allanimeID,
-		strconv.Itoa(watchingEpisode),
-		strconv.Itoa(watchingTime),
-		strconv.Itoa(animeDuration),
-		animeName,
+	_, err := updateLocalDatabase(databaseFile, func(animeList []Anime) []Anime {
+		return append(animeList, newLocalAnime(anilistID, allanimeID, watchingEpisode, watchingTime, animeDuration, animeName))
 	})
 	if err != nil {
 		CurdOut(fmt.Sprintf("Error writing to file: %v", err))
@@ -38,41 +22,16 @@ func LocalAddAnime(databaseFile string, anilistID int, allanimeID string, watchi
 
 // Function to delete an anime entry by Anilist ID and Allanime ID
 func LocalDeleteAnime(databaseFile string, anilistID int, allanimeID string) {
-	animeList := [][]string{}
-	file, err := os.Open(databaseFile)
-	if err != nil {
-		CurdOut(fmt.Sprintf("Error opening file: %v", err))
-		return
-	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		CurdOut(fmt.Sprintf("Error reading file: %v", err))
-		return
-	}
-
-	// Filter out the anime entry
-	for _, row := range records {
-		aid, _ := strconv.Atoi(row[0]) // Anilist ID
-		if aid != anilistID || row[1] != allanimeID {
-			animeList = append(animeList, row)
+	_, err := updateLocalDatabase(databaseFile, func(animeList []Anime) []Anime {
+		// Filter out the anime entry
+		filtered := animeList[:0]
+		for _, anime := range animeList {
+			if anime.AnilistId != anilistID || anime.AllanimeId != allanimeID {
+				filtered = append(filtered, anime)
+			}
 		}
-	}
-
-	// Write the filtered list back to the file
-	fileWrite, err := os.OpenFile(databaseFile, os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		CurdOut(fmt.Sprintf("Error opening file for writing: %v", err))
-		return
-	}
-	defer fileWrite.Close()
-
-	writer := csv.NewWriter(fileWrite)
-	defer writer.Flush()
-
-	err = writer.WriteAll(animeList)
+		return filtered
+	})
 	if err != nil {
 		CurdOut(fmt.Sprintf("Error writing to file: %v", err))
 	}
@@ -80,44 +39,22 @@ func LocalDeleteAnime(databaseFile string, anilistID int, allanimeID string) {
 
 // Function to get all anime entries from the database
 func LocalGetAllAnime(databaseFile string) []Anime {
-	animeList := []Anime{}
-
-	// Ensure the directory exists
-	dir := filepath.Dir(databaseFile)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		CurdOut(fmt.Sprintf("Error creating directory: %v", err))
-		return animeList
-	}
-
-	// Open the file, create if it doesn't exist
-	file, err := os.OpenFile(databaseFile, os.O_RDONLY|os.O_CREATE, 0644)
-	if err != nil {
-		CurdOut(fmt.Sprintf("Error opening or creating file: %v", err))
-		return animeList
-	}
-	defer file.Close()
-
-	// If the file was just created, it will be empty, so return an empty list
-	fileInfo, err := file.Stat()
-	if err != nil {
-		CurdOut(fmt.Sprintf("Error getting file info: %v", err))
-		return animeList
-	}
-	if fileInfo.Size() == 0 {
-		return animeList
-	}
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	animeList, migrated, err := readLocalDatabase(databaseFile)
 	if err != nil {
 		CurdOut(fmt.Sprintf("Error reading file: %v", err))
-		return animeList
+		return []Anime{}
 	}
 
-	for _, row := range records {
-		anime := parseAnimeRow(row)
-		if anime != nil {
-			animeList = append(animeList, *anime)
+	// Convert the old CSV history once, the legacy file is left untouched
+	if migrated {
+		migratedList, err := updateLocalDatabase(databaseFile, func(current []Anime) []Anime {
+			return current
+		})
+		if err != nil {
+			CurdOut(fmt.Sprintf("Error migrating local database: %v", err))
+		} else {
+			Log(fmt.Sprintf("Migrated %d entries to %s", len(migratedList), databaseFile), logFile)
+			animeList = migratedList
 		}
 	}
 
@@ -154,6 +91,8 @@ func parseAnimeRow(row []string) *Anime {
 			Romaji:  row[5],
 		}
 	} else if len(row) == 5 {
+		// Oldest layout had no duration column
+		anime.Ep.Duration = 0
 		anime.Title = AnimeTitle{
 			English: row[4],
 			Romaji:  row[4],
@@ -172,68 +111,74 @@ func GetAnimeName(anime Anime) string {
 	return anime.Title.Romaji
 }
 
-// Function to update or add a new anime entry
-func LocalUpdateAnime(databaseFile string, anilistID int, allanimeID string, watchingEpisode int, playbackTime int, animeDuration int, animeName string) (error, []Anime) {
-	// Read existing entries
-	animeList := LocalGetAllAnime(databaseFile)
-
-	// Find and update existing entry or add new one
-	updated := false
-	for i, anime := range animeList {
-		if anime.AnilistId == anilistID && anime.AllanimeId == allanimeID {
-			animeList[i].Ep.Number = watchingEpisode
-			animeList[i].Ep.Player.PlaybackTime = playbackTime
-			animeList[i].Ep.Duration = animeDuration
-			animeList[i].Title.English = animeName
-			animeList[i].Title.Romaji = animeName
-			updated = true
-			animeList = moveToEnd(animeList, i)
-			break
-		}
-	}
-
-	if !updated {
-		newAnime := Anime{
-			AnilistId:  anilistID,
-			AllanimeId: allanimeID,
-			Ep: Episode{
-				Number: watchingEpisode,
-				Player: playingVideo{
-					PlaybackTime: playbackTime,
-				},
-				Duration: animeDuration,
-			},
-			Title: AnimeTitle{
-				English: animeName,
-				Romaji:  animeName,
+func newLocalAnime(anilistID int, allanimeID string, watchingEpisode int, playbackTime int, animeDuration int, animeName string) Anime {
+	anime := Anime{
+		AnilistId:  anilistID,
+		AllanimeId: allanimeID,
+		Ep: Episode{
+			Number: watchingEpisode,
+			Player: playingVideo{
+				PlaybackTime: playbackTime,
 			},
-		}
-		animeList = append(animeList, newAnime)
+			Duration: animeDuration,
+		},
+		Title: AnimeTitle{
+			English: animeName,
+			Romaji:  animeName,
+		},
+		LastWatched: time.Now(),
 	}
+	if config := GetGlobalConfig(); config != nil {
+		anime.SubOrDub = config.SubOrDub
+	}
+	return anime
+}
 
-	// Write updated list back to file
-	file, err := os.Create(databaseFile)
+// Function to update or add a new anime entry
+func LocalUpdateAnime(databaseFile string, anilistID int, allanimeID string, watchingEpisode int, playbackTime int, animeDuration int, animeName string) (error, []Anime) {
+	animeList, err := updateLocalDatabase(databaseFile, func(animeList []Anime) []Anime {
+		// Find and update existing entry or add new one, fields not given
+		// here (speed, skip times...) are kept
+		for i, anime := range animeList {
+			if anime.AnilistId == anilistID && anime.AllanimeId == allanimeID {
+				animeList[i].Ep.Number = watchingEpisode
+				animeList[i].Ep.Player.PlaybackTime = playbackTime
+				animeList[i].Ep.Duration = animeDuration
+				animeList[i].Title.English = animeName
+				animeList[i].Title.Romaji = animeName
+				animeList[i].LastWatched = time.Now()
+				if config := GetGlobalConfig(); config != nil {
+					animeList[i].SubOrDub = config.SubOrDub
+				}
+				return moveToEnd(animeList, i)
+			}
+		}
+		return append(animeList, newLocalAnime(anilistID, allanimeID, watchingEpisode, playbackTime, animeDuration, animeName))
+	})
 	if err != nil {
-		CurdOut(fmt.Sprintf("Error creating file: %v", err))
+		CurdOut(fmt.Sprintf("Error writing local database: %v", err))
 		return err, nil
 	}
-	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	return nil, animeList
+}
 
-	for _, anime := range animeList {
-		record := []string{
-			strconv.Itoa(anime.AnilistId),
-			anime.AllanimeId,
-			strconv.Itoa(anime.Ep.Number),
-			strconv.Itoa(anime.Ep.Player.PlaybackTime),
-			strconv.Itoa(anime.Ep.Duration),
-			GetAnimeName(anime),
-		}
-		if err := writer.Write(record); err != nil {
-			CurdOut(fmt.Sprintf("Error writing record: %v", err))
+// LocalSaveAnime stores every persisted field of the anime (speed, skip
+// times, sub or dub...) replacing the entry with the same ids.
+func LocalSaveAnime(databaseFile string, anime Anime) (error, []Anime) {
+	anime.LastWatched = time.Now()
+	animeList, err := updateLocalDatabase(databaseFile, func(animeList []Anime) []Anime {
+		for i, existing := range animeList {
+			if existing.AnilistId == anime.AnilistId && existing.AllanimeId == anime.AllanimeId {
+				animeList[i] = anime
+				return moveToEnd(animeList, i)
+			}
 		}
+		return append(animeList, anime)
+	})
+	if err != nil {
+		CurdOut(fmt.Sprintf("Error writing local database: %v", err))
+		return err, nil
 	}
 
 	return nil, animeList
@@ -282,7 +227,7 @@ func WatchUntracked(userCurdConfig *CurdConfig, logFile string) {
 	}
 
 	// Search for the anime
-	animeList, err = SearchAnime(query, userCurdConfig.SubOrDub)
+	animeList, err = SearchAnime(context.Background(), query, userCurdConfig.SubOrDub)
 	if err != nil {
 		Log(fmt.Sprintf("Failed to search anime: %v", err), logFile)
 		ExitCurd(fmt.Errorf("Failed to search anime"))
@@ -328,7 +273,7 @@ func WatchUntracked(userCurdConfig *CurdConfig, logFile string) {
 
 	for {
 		// Get episode link
-		link, err := GetEpisodeURL(*userCurdConfig, anime.AllanimeId, anime.Ep.Number)
+		link, err := GetEpisodeURL(context.Background(), *userCurdConfig, anime.AllanimeId, anime.Ep.Number)
 		if err != nil {
 			Log(fmt.Sprintf("Failed to get episode link: %v", err), logFile)
 			ExitCurd(fmt.Errorf("Failed to get episode link"))
@@ -350,80 +295,58 @@ func WatchUntracked(userCurdConfig *CurdConfig, logFile string) {
 		anime.Ep.Player.SocketPath = mpvSocketPath
 		anime.Ep.Started = false
 
-		Log(fmt.Sprintf("Started mpvsocketpath ", anime.Ep.Player.SocketPath), logFile)
-
-		// Get video duration
-		go func() {
-			for {
-				if anime.Ep.Started {
-					if anime.Ep.Duration == 0 {
-						// Get video duration
-						durationPos, err := MPVSendCommand(anime.Ep.Player.SocketPath, []interface{}{"get_property", "duration"})
-						if err != nil {
-							Log("Error getting video duration: "+err.Error(), logFile)
-						} else if durationPos != nil {
-							if duration, ok := durationPos.(float64); ok {
-								anime.Ep.Duration = int(duration + 0.5) // Round to nearest integer
-								Log(fmt.Sprintf("Video duration: %d seconds", anime.Ep.Duration), logFile)
-							} else {
-								Log("Error: duration is not a float64", logFile)
-							}
-						}
-						break
-					}
-				}
-				time.Sleep(1 * time.Second)
-			}
-		}()
-
-		// Listen for video started
-		for {
-			timePos, err := MPVSendCommand(anime.Ep.Player.SocketPath, []interface{}{"get_property", "time-pos"})
-			if err != nil {
-				Log("Error getting playback time: "+err.Error(), logFile)
-
-				// Check if the error is due to invalid JSON
-				// User closed the video
-				if anime.Ep.Started {
-					percentageWatched := PercentageWatched(anime.Ep.Player.PlaybackTime, anime.Ep.Duration)
-					// Episode is completed
-					Log(fmt.Sprint(percentageWatched), logFile)
-					Log(fmt.Sprint(anime.Ep.Player.PlaybackTime), logFile)
-					Log(fmt.Sprint(anime.Ep.Duration), logFile)
-					Log(fmt.Sprint(userCurdConfig.PercentageToMarkComplete), logFile)
-					if int(percentageWatched) >= userCurdConfig.PercentageToMarkComplete {
-						anime.Ep.Number++
-						anime.Ep.Started = false
-						Log("Completed episode, starting next.", logFile)
-						anime.Ep.IsCompleted = true
-						// Exit the skip loop
-						break
-					} else if fmt.Sprintf("%v", err) == "invalid character '{' after top-level value" { // Episode is not completed
-						Log("Received invalid JSON response, continuing...", logFile)
-					} else {
-						Log("Episode is not completed, exiting", logFile)
-						ExitCurd(nil)
-					}
-				}
-			}
+		Log(fmt.Sprint("Started mpvsocketpath ", anime.Ep.Player.SocketPath), logFile)
 
-			// Convert timePos to integer
-			if timePos != nil {
-				if !anime.Ep.Started {
-					anime.Ep.Started = true
-				}
+		dialCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		client, err := DialMPV(dialCtx, anime.Ep.Player.SocketPath)
+		cancel()
+		if err != nil {
+			Log(err.Error(), logFile)
+			ExitCurd(fmt.Errorf("Failed to connect to mpv"))
+		}
 
-				animePosition, ok := timePos.(float64)
-				if !ok {
-					Log("Error: timePos is not a float64", logFile)
-					continue
-				}
+		err = client.Observe(context.Background(), MPVDuration, MPVTimePos)
+		if err != nil {
+			Log(err.Error(), logFile)
+			ExitCurd(fmt.Errorf("Failed to observe mpv"))
+		}
 
-				anime.Ep.Player.PlaybackTime = int(animePosition + 0.5) // Round to nearest integer
+		// Listen for playback until mpv quits
+		for event := range client.Events() {
+			if event.Unavailable {
+				continue
 			}
-			time.Sleep(1 * time.Second)
-
+			switch event.Property {
+			case MPVDuration:
+				if anime.Ep.Duration == 0 {
+					anime.Ep.Duration = int(event.Float + 0.5) // Round to nearest integer
+					Log(fmt.Sprintf("Video duration: %d seconds", anime.Ep.Duration), logFile)
+				}
+			case MPVTimePos:
+				anime.Ep.Started = true
+				anime.Ep.Player.PlaybackTime = int(event.Float + 0.5) // Round to nearest integer
+			}
+		}
+		client.Close()
+
+		// User closed the video
+		percentageWatched := PercentageWatched(anime.Ep.Player.PlaybackTime, anime.Ep.Duration)
+		Log(fmt.Sprint(percentageWatched), logFile)
+		Log(fmt.Sprint(anime.Ep.Player.PlaybackTime), logFile)
+		Log(fmt.Sprint(anime.Ep.Duration), logFile)
+		Log(fmt.Sprint(userCurdConfig.PercentageToMarkComplete), logFile)
+		if !anime.Ep.Started || int(percentageWatched) < userCurdConfig.PercentageToMarkComplete {
+			Log("Episode is not completed, exiting", logFile)
+			ExitCurd(nil)
 		}
+
+		// Episode is completed
+		anime.Ep.Number++
+		anime.Ep.Started = false
+		anime.Ep.Duration = 0
+		anime.Ep.Player.PlaybackTime = 0
+		Log("Completed episode, starting next.", logFile)
+		anime.Ep.IsCompleted = true
 	}
 
 }
@@ -0,0 +1,329 @@
+package curdInteg
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// MPVProperty is a property the client can observe
+type MPVProperty string
+
+const (
+	MPVTimePos    MPVProperty = "time-pos"
+	MPVPause      MPVProperty = "pause"
+	MPVSpeed      MPVProperty = "speed"
+	MPVDuration   MPVProperty = "duration"
+	MPVEOFReached MPVProperty = "eof-reached"
+)
+
+// MPVEvent is a change of an observed property. Float is set for time-pos,
+// speed and duration, Bool for pause and eof-reached. Unavailable is true
+// while mpv has no value for the property (no file loaded yet...).
+type MPVEvent struct {
+	Property    MPVProperty
+	Float       float64
+	Bool        bool
+	Unavailable bool
+}
+
+// ErrMPVClosed is returned by commands sent after the connection ended
+var ErrMPVClosed = errors.New("mpv connection closed")
+
+type mpvMessage struct {
+	RequestID *int64          `json:"request_id"`
+	Error     string          `json:"error"`
+	Data      json.RawMessage `json:"data"`
+	Event     string          `json:"event"`
+	Name      string          `json:"name"`
+}
+
+// MPVClient keeps a single IPC connection to mpv. Responses are matched to
+// their command by request_id so events sent in between don't get in the way.
+type MPVClient struct {
+	conn net.Conn
+
+	writeMutex sync.Mutex
+
+	mutex     sync.Mutex
+	nextID    int64
+	observeID int
+	pending   map[int64]chan mpvMessage
+
+	// queue holds the events the consumer didn't take yet, the read loop
+	// never waits for it
+	eventsMutex sync.Mutex
+	eventsCond  *sync.Cond
+	queue       []MPVEvent
+	readDone    bool
+
+	events    chan MPVEvent
+	done      chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMPVClient connects to the mpv ipc socket or pipe
+func NewMPVClient(ipcSocketPath string) (*MPVClient, error) {
+	conn, err := connectToPipe(ipcSocketPath)
+	if err != nil {
+		return nil, err
+	}
+	return newMPVClient(conn), nil
+}
+
+func newMPVClient(conn net.Conn) *MPVClient {
+	c := &MPVClient{
+		conn:    conn,
+		pending: make(map[int64]chan mpvMessage),
+		events:  make(chan MPVEvent),
+		done:    make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	c.eventsCond = sync.NewCond(&c.eventsMutex)
+	go c.readLoop()
+	go c.forwardEvents()
+	return c
+}
+
+// DialMPV retries NewMPVClient until mpv created its socket or ctx is done,
+// mpv needs a moment after StartVideo before it listens.
+func DialMPV(ctx context.Context, ipcSocketPath string) (*MPVClient, error) {
+	for {
+		c, err := NewMPVClient(ipcSocketPath)
+		if err == nil {
+			return c, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to connect to mpv: %w", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (c *MPVClient) readLoop() {
+	defer c.shutdown()
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var msg mpvMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			Log(fmt.Sprint("Invalid mpv message: ", err), logFile)
+			continue
+		}
+
+		if msg.RequestID != nil {
+			c.mutex.Lock()
+			ch, ok := c.pending[*msg.RequestID]
+			delete(c.pending, *msg.RequestID)
+			c.mutex.Unlock()
+			if ok {
+				ch <- msg
+			}
+			continue
+		}
+
+		if msg.Event == "property-change" {
+			c.emit(parseMPVEvent(msg))
+		}
+	}
+}
+
+// emit queues the event without blocking the read loop. While the consumer
+// is behind, time-pos, speed and duration only keep their latest value, pause
+// and eof-reached are always delivered in order.
+func (c *MPVClient) emit(event MPVEvent) {
+	c.eventsMutex.Lock()
+	defer c.eventsMutex.Unlock()
+	defer c.eventsCond.Signal()
+	if !keepsEveryEvent(event.Property) {
+		// Values are only merged since the last pause or eof-reached, so
+		// they stay in order with them
+		for i := len(c.queue) - 1; i >= 0 && !keepsEveryEvent(c.queue[i].Property); i-- {
+			if c.queue[i].Property == event.Property {
+				c.queue[i] = event
+				return
+			}
+		}
+	}
+	c.queue = append(c.queue, event)
+}
+
+func keepsEveryEvent(property MPVProperty) bool {
+	return property == MPVPause || property == MPVEOFReached
+}
+
+// forwardEvents sends the queued events on Events, those received before mpv
+// quit included. It stops early once the client is closed.
+func (c *MPVClient) forwardEvents() {
+	defer close(c.events)
+	for {
+		c.eventsMutex.Lock()
+		for len(c.queue) == 0 && !c.readDone {
+			c.eventsCond.Wait()
+		}
+		if len(c.queue) == 0 {
+			c.eventsMutex.Unlock()
+			return
+		}
+		event := c.queue[0]
+		c.queue = c.queue[1:]
+		c.eventsMutex.Unlock()
+
+		select {
+		case c.events <- event:
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func parseMPVEvent(msg mpvMessage) MPVEvent {
+	event := MPVEvent{Property: MPVProperty(msg.Name)}
+	if len(msg.Data) == 0 || string(msg.Data) == "null" {
+		event.Unavailable = true
+		return event
+	}
+
+	switch event.Property {
+	case MPVPause, MPVEOFReached:
+		if err := json.Unmarshal(msg.Data, &event.Bool); err != nil {
+			event.Unavailable = true
+		}
+	default:
+		if err := json.Unmarshal(msg.Data, &event.Float); err != nil {
+			event.Unavailable = true
+		}
+	}
+	return event
+}
+
+// shutdown is only called by the read loop, the events already queued are
+// still delivered
+func (c *MPVClient) shutdown() {
+	c.conn.Close()
+
+	c.mutex.Lock()
+	close(c.done)
+	c.pending = map[int64]chan mpvMessage{}
+	c.mutex.Unlock()
+
+	c.eventsMutex.Lock()
+	c.readDone = true
+	c.eventsCond.Broadcast()
+	c.eventsMutex.Unlock()
+}
+
+// Command sends a command and waits for its own response
+func (c *MPVClient) Command(ctx context.Context, args ...interface{}) (interface{}, error) {
+	c.mutex.Lock()
+	select {
+	case <-c.done:
+		c.mutex.Unlock()
+		return nil, ErrMPVClosed
+	default:
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan mpvMessage, 1)
+	c.pending[id] = ch
+	c.mutex.Unlock()
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"command":    args,
+		"request_id": id,
+	})
+	if err != nil {
+		c.forget(id)
+		return nil, err
+	}
+
+	c.writeMutex.Lock()
+	_, err = c.conn.Write(append(payload, '\n'))
+	c.writeMutex.Unlock()
+	if err != nil {
+		c.forget(id)
+		return nil, err
+	}
+
+	select {
+	case msg := <-ch:
+		if msg.Error != "" && msg.Error != "success" {
+			return nil, fmt.Errorf("mpv: %s", msg.Error)
+		}
+		var data interface{}
+		if len(msg.Data) > 0 {
+			if err := json.Unmarshal(msg.Data, &data); err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+	case <-c.done:
+		return nil, ErrMPVClosed
+	case <-ctx.Done():
+		c.forget(id)
+		return nil, ctx.Err()
+	}
+}
+
+func (c *MPVClient) forget(id int64) {
+	c.mutex.Lock()
+	delete(c.pending, id)
+	c.mutex.Unlock()
+}
+
+// GetProperty returns the current value of a property
+func (c *MPVClient) GetProperty(ctx context.Context, name string) (interface{}, error) {
+	return c.Command(ctx, "get_property", name)
+}
+
+// SetProperty changes a property
+func (c *MPVClient) SetProperty(ctx context.Context, name string, value interface{}) error {
+	_, err := c.Command(ctx, "set_property", name, value)
+	return err
+}
+
+// Seek jumps to an absolute position in seconds
+func (c *MPVClient) Seek(ctx context.Context, seconds int) error {
+	_, err := c.Command(ctx, "seek", seconds, "absolute")
+	return err
+}
+
+// Observe subscribes to the properties, their changes are sent on Events.
+// mpv sends the current value of each property right away.
+func (c *MPVClient) Observe(ctx context.Context, properties ...MPVProperty) error {
+	for _, property := range properties {
+		c.mutex.Lock()
+		c.observeID++
+		id := c.observeID
+		c.mutex.Unlock()
+		if _, err := c.Command(ctx, "observe_property", id, string(property)); err != nil {
+			return fmt.Errorf("failed to observe %s: %w", property, err)
+		}
+	}
+	return nil
+}
+
+// Events is closed once mpv quits or the client is closed
+func (c *MPVClient) Events() <-chan MPVEvent {
+	return c.events
+}
+
+// Done is closed once the connection ended
+func (c *MPVClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close ends the connection, mpv keeps running. Events not read yet are
+// dropped.
+func (c *MPVClient) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.conn.Close()
+}
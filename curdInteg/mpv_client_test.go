@@ -0,0 +1,169 @@
+package curdInteg
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeMPV is the mpv end of a pipe connected to a client
+func fakeMPV(t *testing.T) (*MPVClient, net.Conn) {
+	t.Helper()
+	clientConn, mpvConn := net.Pipe()
+	client := newMPVClient(clientConn)
+	t.Cleanup(func() {
+		client.Close()
+		mpvConn.Close()
+	})
+	return client, mpvConn
+}
+
+func TestMPVClientMatchesOutOfOrderReplies(t *testing.T) {
+	client, mpv := fakeMPV(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go func() {
+		requests := map[string]int64{}
+		scanner := bufio.NewScanner(mpv)
+		for len(requests) < 2 && scanner.Scan() {
+			var request struct {
+				Command   []interface{} `json:"command"`
+				RequestID int64         `json:"request_id"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+				t.Error(err)
+				return
+			}
+			requests[request.Command[1].(string)] = request.RequestID
+		}
+		fmt.Fprintln(mpv, `{"event":"property-change","id":1,"name":"time-pos","data":1.5}`)
+		fmt.Fprintf(mpv, `{"request_id":%d,"error":"success","data":1420.5}`+"\n", requests["duration"])
+		fmt.Fprintln(mpv, `{"event":"property-change","id":2,"name":"pause","data":true}`)
+		fmt.Fprintf(mpv, `{"request_id":%d,"error":"success","data":12.25}`+"\n", requests["time-pos"])
+	}()
+
+	type result struct {
+		name  string
+		value interface{}
+		err   error
+	}
+	results := make(chan result, 2)
+	for _, name := range []string{"time-pos", "duration"} {
+		go func() {
+			value, err := client.GetProperty(ctx, name)
+			results <- result{name, value, err}
+		}()
+	}
+
+	want := map[string]float64{"time-pos": 12.25, "duration": 1420.5}
+	for range 2 {
+		r := <-results
+		if r.err != nil {
+			t.Fatalf("%s: %v", r.name, r.err)
+		}
+		if r.value != want[r.name] {
+			t.Errorf("%s = %v, want %v", r.name, r.value, want[r.name])
+		}
+	}
+
+	for _, want := range []MPVEvent{{Property: MPVTimePos, Float: 1.5}, {Property: MPVPause, Bool: true}} {
+		select {
+		case event := <-client.Events():
+			if event != want {
+				t.Errorf("got %+v, want %+v", event, want)
+			}
+		case <-ctx.Done():
+			t.Fatal("event not delivered")
+		}
+	}
+}
+
+func TestMPVClientKeepsStateEventsWhenBehind(t *testing.T) {
+	client, mpv := fakeMPV(t)
+
+	// Nobody reads the events while mpv sends them
+	for i := 1; i <= 200; i++ {
+		fmt.Fprintf(mpv, `{"event":"property-change","name":"time-pos","data":%d}`+"\n", i)
+		if i == 100 {
+			fmt.Fprintln(mpv, `{"event":"property-change","name":"pause","data":true}`)
+			fmt.Fprintln(mpv, `{"event":"property-change","name":"pause","data":false}`)
+		}
+	}
+	fmt.Fprintln(mpv, `{"event":"property-change","name":"eof-reached","data":true}`)
+	mpv.Close()
+
+	var events []MPVEvent
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case event, ok := <-client.Events():
+			if !ok {
+				done = true
+				break
+			}
+			events = append(events, event)
+		case <-timeout:
+			t.Fatal("events not closed after mpv quit")
+		}
+	}
+
+	var pauses []bool
+	var lastPos float64
+	eof := false
+	for _, event := range events {
+		switch event.Property {
+		case MPVPause:
+			if lastPos > 100 {
+				t.Errorf("pause delivered after time-pos %v", lastPos)
+			}
+			pauses = append(pauses, event.Bool)
+		case MPVTimePos:
+			lastPos = event.Float
+		case MPVEOFReached:
+			eof = event.Bool
+		}
+	}
+	if len(pauses) != 2 || !pauses[0] || pauses[1] {
+		t.Errorf("got pause events %v, want [true false]", pauses)
+	}
+	if !eof {
+		t.Error("eof-reached lost")
+	}
+	if lastPos != 200 {
+		t.Errorf("last time-pos %v, want 200", lastPos)
+	}
+	if len(events) > 10 {
+		t.Errorf("time-pos not coalesced, got %d events", len(events))
+	}
+}
+
+func TestMPVClientCloseStopsEvents(t *testing.T) {
+	client, mpv := fakeMPV(t)
+	fmt.Fprintln(mpv, `{"event":"property-change","name":"pause","data":true}`)
+	client.Close()
+
+	select {
+	case <-client.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("read loop still running after Close")
+	}
+	if _, err := client.GetProperty(context.Background(), "pause"); err != ErrMPVClosed {
+		t.Errorf("got %v, want ErrMPVClosed", err)
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-client.Events():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("events not closed after Close")
+		}
+	}
+}
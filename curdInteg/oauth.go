@@ -0,0 +1,242 @@
+package curdInteg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AnilistAuthorizeURL is the default implicit grant endpoint, the config can
+// point to another one
+const AnilistAuthorizeURL = "https://anilist.co/api/v2/oauth/authorize"
+
+// PasteClientID is the Anilist client of curd, its redirect shows the token
+// for the user to paste. A client registered with OAuthRedirectURL must be
+// configured to log in through the loopback listener.
+const PasteClientID = "23782"
+
+// ErrLoopbackUnavailable is returned by LoopbackLogin when the client can't
+// redirect to the loopback listener
+var ErrLoopbackUnavailable = errors.New("the Anilist client doesn't redirect to the login listener")
+
+// LoopbackAvailable tells if the configured client can be used with
+// LoopbackLogin, otherwise the token has to be pasted
+func LoopbackAvailable(config *CurdConfig) bool {
+	return config.AnilistClientID != "" && config.AnilistClientID != PasteClientID
+}
+
+// PasteLoginURL is the authorize page of the paste flow, it ends on a page
+// showing the token whatever client is configured
+func PasteLoginURL(config *CurdConfig) (*url.URL, error) {
+	return authorizeEndpoint(config, url.Values{"client_id": {PasteClientID}, "response_type": {"token"}})
+}
+
+// OAuthToken is the token received at the end of the login
+type OAuthToken struct {
+	AccessToken string
+	// ExpiresAt is zero when the server didn't say
+	ExpiresAt time.Time
+}
+
+// Expired is false when the expiry is unknown
+func (t OAuthToken) Expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
+
+// callbackPage moves the token from the url fragment, which browsers never
+// send, to the query of a second request the listener can read
+const callbackPage = `<!DOCTYPE html>
+<html><head><title>Login</title></head>
+<body><p>Finishing login...</p>
+<script>
+var params = window.location.hash.substring(1) || window.location.search.substring(1);
+window.location.replace("/token?" + params);
+</script></body></html>`
+
+const loginDonePage = `<!DOCTYPE html>
+<html><head><title>Login</title></head>
+<body><p>%s</p></body></html>`
+
+// OAuthRedirectURL is the url the client must have registered on Anilist
+func OAuthRedirectURL(port int) string {
+	return fmt.Sprintf("http://127.0.0.1:%d/callback", port)
+}
+
+// LoopbackLogin opens the authorize page with openURL and waits on a
+// localhost listener for the redirect carrying the token. It returns when
+// the token arrived, the user denied access or ctx is done.
+func LoopbackLogin(ctx context.Context, config *CurdConfig, openURL func(*url.URL) error) (OAuthToken, error) {
+	if !LoopbackAvailable(config) {
+		return OAuthToken{}, ErrLoopbackUnavailable
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", config.OAuthRedirectPort))
+	if err != nil {
+		return OAuthToken{}, fmt.Errorf("failed to start login listener: %w", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	state, err := randomState()
+	if err != nil {
+		listener.Close()
+		return OAuthToken{}, err
+	}
+
+	authorizeURL, err := buildAuthorizeURL(config, port, state)
+	if err != nil {
+		listener.Close()
+		return OAuthToken{}, err
+	}
+
+	type result struct {
+		token OAuthToken
+		err   error
+	}
+	results := make(chan result, 1)
+	send := func(r result) {
+		select {
+		case results <- r:
+		default:
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, callbackPage)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		token, err := parseTokenResponse(r.URL.Query(), state)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, loginDonePage, "Login failed: "+err.Error())
+			send(result{err: err})
+			return
+		}
+		fmt.Fprintf(w, loginDonePage, "Logged in, you can close this tab.")
+		send(result{token: token})
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := openURL(authorizeURL); err != nil {
+		return OAuthToken{}, fmt.Errorf("failed to open login page: %w", err)
+	}
+
+	select {
+	case r := <-results:
+		return r.token, r.err
+	case <-ctx.Done():
+		return OAuthToken{}, ctx.Err()
+	}
+}
+
+func buildAuthorizeURL(config *CurdConfig, port int, state string) (*url.URL, error) {
+	return authorizeEndpoint(config, url.Values{
+		"client_id":     {config.AnilistClientID},
+		"response_type": {"token"},
+		"redirect_uri":  {OAuthRedirectURL(port)},
+		"state":         {state},
+	})
+}
+
+// authorizeEndpoint adds the parameters to the configured authorize url
+func authorizeEndpoint(config *CurdConfig, params url.Values) (*url.URL, error) {
+	endpoint := config.AnilistAuthorizeURL
+	if endpoint == "" {
+		endpoint = AnilistAuthorizeURL
+	}
+	authorizeURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authorize url: %w", err)
+	}
+	query := authorizeURL.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	authorizeURL.RawQuery = query.Encode()
+	return authorizeURL, nil
+}
+
+// parseTokenResponse reads the parameters of the implicit grant redirect.
+// The state must be the one sent, any page could push a token to the
+// listener otherwise.
+func parseTokenResponse(params url.Values, state string) (OAuthToken, error) {
+	if errorCode := params.Get("error"); errorCode != "" {
+		if description := params.Get("error_description"); description != "" {
+			return OAuthToken{}, fmt.Errorf("%s: %s", errorCode, description)
+		}
+		return OAuthToken{}, errors.New(errorCode)
+	}
+	if received := params.Get("state"); received == "" || received != state {
+		return OAuthToken{}, errors.New("state mismatch")
+	}
+
+	token := OAuthToken{AccessToken: strings.TrimSpace(params.Get("access_token"))}
+	if token.AccessToken == "" {
+		return OAuthToken{}, errors.New("no access token in redirect")
+	}
+	if expiresIn, err := strconv.Atoi(params.Get("expires_in")); err == nil && expiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+func randomState() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func tokenExpiryFile(tokenPath string) string {
+	return tokenPath + "_expiry"
+}
+
+// WriteTokenExpiry saves the expiry next to the token file, a zero time
+// removes it
+func WriteTokenExpiry(tokenPath string, expiresAt time.Time) error {
+	if expiresAt.IsZero() {
+		err := os.Remove(tokenExpiryFile(tokenPath))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.WriteFile(tokenExpiryFile(tokenPath), []byte(expiresAt.Format(time.RFC3339)), 0644); err != nil {
+		return fmt.Errorf("failed to write token expiry: %w", err)
+	}
+	return nil
+}
+
+// GetTokenExpiry returns a zero time when the expiry was never saved
+func GetTokenExpiry(tokenPath string) (time.Time, error) {
+	data, err := os.ReadFile(tokenExpiryFile(tokenPath))
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read token expiry: %w", err)
+	}
+	expiresAt, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid token expiry: %w", err)
+	}
+	return expiresAt, nil
+}
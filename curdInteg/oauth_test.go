@@ -0,0 +1,248 @@
+package curdInteg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAuthorize stands in for the Anilist authorize page and the browser: it
+// loads the callback page then follows it to /token with the given
+// parameters, the state of the request replaces "{state}"
+func fakeAuthorize(t *testing.T, params string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("response_type") != "token" || query.Get("client_id") != "1234" {
+			t.Errorf("unexpected authorize query %q", r.URL.RawQuery)
+		}
+		redirect, err := url.Parse(query.Get("redirect_uri"))
+		if err != nil {
+			t.Errorf("invalid redirect uri: %v", err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+		go func() {
+			page, err := http.Get(redirect.String())
+			if err != nil {
+				t.Errorf("callback: %v", err)
+				return
+			}
+			body, _ := io.ReadAll(page.Body)
+			page.Body.Close()
+			if !strings.Contains(string(body), `"/token?"`) {
+				t.Errorf("callback page doesn't forward to /token: %s", body)
+			}
+
+			tokenURL := *redirect
+			tokenURL.Path = "/token"
+			tokenURL.RawQuery = strings.ReplaceAll(params, "{state}", query.Get("state"))
+			if response, err := http.Get(tokenURL.String()); err == nil {
+				response.Body.Close()
+			}
+		}()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func openWithGet(authorizeURL *url.URL) error {
+	response, err := http.Get(authorizeURL.String())
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+func loginConfig(authorize string) *CurdConfig {
+	return &CurdConfig{AnilistClientID: "1234", AnilistAuthorizeURL: authorize, OAuthRedirectPort: 0}
+}
+
+func TestLoopbackLoginReceivesToken(t *testing.T) {
+	authorize := fakeAuthorize(t, "access_token=abc&token_type=Bearer&expires_in=3600&state={state}")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	before := time.Now()
+	token, err := LoopbackLogin(ctx, loginConfig(authorize.URL), openWithGet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "abc" {
+		t.Errorf("got token %q", token.AccessToken)
+	}
+	if token.ExpiresAt.Before(before.Add(time.Hour)) || token.ExpiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("expiry %v isn't an hour from now", token.ExpiresAt)
+	}
+}
+
+func TestLoopbackLoginStateMismatch(t *testing.T) {
+	authorize := fakeAuthorize(t, "access_token=abc&state=forged")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := LoopbackLogin(ctx, loginConfig(authorize.URL), openWithGet)
+	if err == nil || !strings.Contains(err.Error(), "state mismatch") {
+		t.Errorf("got %v, want a state mismatch", err)
+	}
+}
+
+func TestLoopbackLoginDenied(t *testing.T) {
+	authorize := fakeAuthorize(t, "error=access_denied&error_description=The+user+denied&state={state}")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := LoopbackLogin(ctx, loginConfig(authorize.URL), openWithGet)
+	if err == nil || !strings.Contains(err.Error(), "access_denied") {
+		t.Errorf("got %v, want access_denied", err)
+	}
+}
+
+func TestLoopbackLoginTimeout(t *testing.T) {
+	// The browser never comes back
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := LoopbackLogin(ctx, loginConfig("http://127.0.0.1:1/authorize"), func(*url.URL) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the deadline", err)
+	}
+}
+
+func TestLoopbackLoginCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := LoopbackLogin(ctx, loginConfig(""), func(authorizeURL *url.URL) error {
+			if !strings.HasPrefix(authorizeURL.String(), AnilistAuthorizeURL+"?") {
+				t.Errorf("default endpoint not used: %s", authorizeURL)
+			}
+			return nil
+		})
+		done <- err
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, want canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("login didn't stop on cancel")
+	}
+}
+
+func TestLoopbackLoginOpenFails(t *testing.T) {
+	_, err := LoopbackLogin(context.Background(), loginConfig(""), func(*url.URL) error { return errors.New("no browser") })
+	if err == nil || !strings.Contains(err.Error(), "no browser") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestParseTokenResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  string
+		wantErr string
+		expires time.Duration
+	}{
+		{name: "expiry", params: "access_token=abc&expires_in=31536000&state=s", expires: 31536000 * time.Second},
+		{name: "no expiry", params: "access_token=abc&state=s"},
+		{name: "invalid expiry", params: "access_token=abc&expires_in=soon&state=s"},
+		{name: "negative expiry", params: "access_token=abc&expires_in=-5&state=s"},
+		{name: "no state", params: "access_token=abc", wantErr: "state mismatch"},
+		{name: "empty state", params: "access_token=abc&state=", wantErr: "state mismatch"},
+		{name: "other state", params: "access_token=abc&state=x", wantErr: "state mismatch"},
+		{name: "no token", params: "state=s", wantErr: "no access token"},
+		{name: "blank token", params: "access_token=+&state=s", wantErr: "no access token"},
+		{name: "error", params: "error=access_denied", wantErr: "access_denied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := url.ParseQuery(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := time.Now()
+			token, err := parseTokenResponse(params, "s")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.AccessToken != "abc" {
+				t.Errorf("got token %q", token.AccessToken)
+			}
+			if tt.expires == 0 {
+				if !token.ExpiresAt.IsZero() || token.Expired() {
+					t.Errorf("expiry %v, want unknown", token.ExpiresAt)
+				}
+				return
+			}
+			if token.ExpiresAt.Before(before.Add(tt.expires)) || token.Expired() {
+				t.Errorf("expiry %v", token.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestTokenExpiryFile(t *testing.T) {
+	tokenPath := t.TempDir() + "/token"
+	if expiresAt, err := GetTokenExpiry(tokenPath); err != nil || !expiresAt.IsZero() {
+		t.Fatalf("missing expiry read as %v, %v", expiresAt, err)
+	}
+	want := time.Date(2027, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := WriteTokenExpiry(tokenPath, want); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := GetTokenExpiry(tokenPath); err != nil || !got.Equal(want) {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if err := WriteTokenExpiry(tokenPath, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := GetTokenExpiry(tokenPath); !got.IsZero() {
+		t.Errorf("expiry not removed: %v", got)
+	}
+	if !(OAuthToken{ExpiresAt: time.Now().Add(-time.Minute)}).Expired() {
+		t.Error("past expiry not expired")
+	}
+}
+
+func TestLoopbackLoginNeedsOwnClient(t *testing.T) {
+	config := &CurdConfig{AnilistClientID: PasteClientID}
+	_, err := LoopbackLogin(context.Background(), config, func(*url.URL) error {
+		t.Error("login page opened with the paste client")
+		return nil
+	})
+	if !errors.Is(err, ErrLoopbackUnavailable) {
+		t.Errorf("got %v, want the loopback unavailable", err)
+	}
+	if LoopbackAvailable(&CurdConfig{}) || !LoopbackAvailable(loginConfig("")) {
+		t.Error("loopback offered for the wrong clients")
+	}
+}
+
+func TestPasteLoginURL(t *testing.T) {
+	pasteURL, err := PasteLoginURL(loginConfig(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := pasteURL.Query()
+	if !strings.HasPrefix(pasteURL.String(), AnilistAuthorizeURL+"?") || query.Get("client_id") != PasteClientID ||
+		query.Get("response_type") != "token" || query.Has("redirect_uri") {
+		t.Errorf("got %s", pasteURL)
+	}
+}
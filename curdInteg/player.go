@@ -2,8 +2,8 @@ package curdInteg
 
 import (
 	// "fmt"
+	"context"
 	"crypto/rand"
-	"encoding/json"
 	"fmt"
 	"github.com/charmbracelet/log"
 	"os"
@@ -94,43 +94,19 @@ func joinArgs(args []string) string {
 	return result
 }
 
+// MPVSendCommand sends a single command over a short lived connection,
+// use MPVClient to send several or to observe properties.
 func MPVSendCommand(ipcSocketPath string, command []interface{}) (interface{}, error) {
-	conn, err := connectToPipe(ipcSocketPath)
+	client, err := NewMPVClient(ipcSocketPath)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
+	defer client.Close()
 
-	commandStr, err := json.Marshal(map[string]interface{}{
-		"command": command,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	// Send the command
-	_, err = conn.Write(append(commandStr, '\n'))
-	if err != nil {
-		return nil, err
-	}
-
-	// Receive the response
-	buf := make([]byte, 4096)
-	n, err := conn.Read(buf)
-	if err != nil {
-		return nil, err
-	}
-
-	var response map[string]interface{}
-	if err := json.Unmarshal(buf[:n], &response); err != nil {
-		return nil, err
-	}
-
-	if data, exists := response["data"]; exists {
-		return data, nil
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
 
-	return nil, nil
+	return client.Command(ctx, command...)
 }
 
 func SeekMPV(ipcSocketPath string, time int) (interface{}, error) {
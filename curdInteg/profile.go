@@ -0,0 +1,220 @@
+package curdInteg
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultProfile uses the config file and storage path as they are, like
+// before profiles existed
+const DefaultProfile = "default"
+
+// profilesDirName is the directory holding the profiles, next to the config
+// file for their overrides and in the storage path for their data
+const profilesDirName = "profiles"
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// RegisterProfileFlag adds the -profile flag, its value is given to
+// LoadProfileConfig once the flags are parsed
+func RegisterProfileFlag(fs *flag.FlagSet) *string {
+	return fs.String("profile", "", "Profile to use, each one has its own Anilist account, history and config overrides")
+}
+
+// ValidProfileName is true for names usable as a file name on every system
+func ValidProfileName(name string) bool {
+	return profileNamePattern.MatchString(name)
+}
+
+func isDefaultProfile(profile string) bool {
+	return profile == "" || profile == DefaultProfile
+}
+
+// ProfileStoragePath is where the token and history of a profile are kept
+func ProfileStoragePath(baseStoragePath, profile string) string {
+	if isDefaultProfile(profile) {
+		return baseStoragePath
+	}
+	return filepath.Join(baseStoragePath, profilesDirName, profile)
+}
+
+func profileConfigPath(configPath, profile string) string {
+	return filepath.Join(filepath.Dir(os.ExpandEnv(configPath)), profilesDirName, profile+".conf")
+}
+
+// profileOfStoragePath returns the profile owning the storage path, used to
+// keep one keyring entry per profile
+func profileOfStoragePath(storagePath string) string {
+	storagePath = filepath.Clean(os.ExpandEnv(storagePath))
+	if filepath.Base(filepath.Dir(storagePath)) == profilesDirName {
+		return filepath.Base(storagePath)
+	}
+	return DefaultProfile
+}
+
+// ListProfiles returns the default profile followed by the others sorted by name
+func ListProfiles(configPath string) ([]string, error) {
+	profiles := []string{DefaultProfile}
+	entries, err := os.ReadDir(filepath.Dir(profileConfigPath(configPath, DefaultProfile)))
+	if os.IsNotExist(err) {
+		return profiles, nil
+	}
+	if err != nil {
+		return profiles, fmt.Errorf("failed to list profiles: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".conf")
+		if entry.IsDir() || name == entry.Name() || !ValidProfileName(name) || name == DefaultProfile {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append(profiles, names...), nil
+}
+
+// CreateProfile adds a profile without overrides, it starts logged out with
+// an empty history
+func CreateProfile(configPath, profile string) error {
+	if !ValidProfileName(profile) || isDefaultProfile(profile) {
+		return fmt.Errorf("invalid profile name %q, use letters, digits, - and _", profile)
+	}
+	path := profileConfigPath(configPath, profile)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("profile %s already exists", profile)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+	content := fmt.Sprintf("# Config overrides of the profile %s, missing keys come from curd.conf\n", profile)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("error creating profile: %v", err)
+	}
+	return nil
+}
+
+// LoadProfileConfig loads the config with the overrides of the profile on
+// top. The storage path always is the one of the profile.
+func LoadProfileConfig(configPath, profile string) (CurdConfig, error) {
+	baseConfig, err := LoadConfig(configPath)
+	if err != nil || isDefaultProfile(profile) {
+		return baseConfig, err
+	}
+
+	configMap, err := loadConfigFromFile(os.ExpandEnv(configPath))
+	if err != nil {
+		return CurdConfig{}, fmt.Errorf("error loading config file: %v", err)
+	}
+	overrides, err := loadConfigFromFile(profileConfigPath(configPath, profile))
+	if os.IsNotExist(err) {
+		return CurdConfig{}, fmt.Errorf("profile %s doesn't exist", profile)
+	}
+	if err != nil {
+		return CurdConfig{}, fmt.Errorf("error loading profile %s: %v", profile, err)
+	}
+
+	for key, value := range overrides {
+		configMap[key] = value
+	}
+	configMap["StoragePath"] = ProfileStoragePath(baseConfig.StoragePath, profile)
+	configMap["Profile"] = baseConfig.Profile
+	return populateConfig(configMap), nil
+}
+
+// SaveProfileConfig saves the config of the default profile as is. For other
+// profiles the values changed since the config was loaded are merged into
+// the overrides, the ones already there are kept even when equal to the
+// main config.
+func SaveProfileConfig(configPath, profile string, config CurdConfig) error {
+	if isDefaultProfile(profile) {
+		return SaveConfig(configPath, config)
+	}
+
+	previous, err := LoadProfileConfig(configPath, profile)
+	if err != nil {
+		return err
+	}
+
+	changes := make(map[string]string)
+	previousValue := reflect.ValueOf(previous)
+	configValue := reflect.ValueOf(config)
+	for i := 0; i < configValue.NumField(); i++ {
+		tag := configValue.Type().Field(i).Tag.Get("config")
+		if tag == "" || tag == "StoragePath" || tag == "Profile" {
+			continue
+		}
+		value := fmt.Sprintf("%v", configValue.Field(i).Interface())
+		if value != fmt.Sprintf("%v", previousValue.Field(i).Interface()) {
+			changes[tag] = value
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if err := mergeConfigFile(profileConfigPath(configPath, profile), changes); err != nil {
+		return fmt.Errorf("error saving profile %s: %v", profile, err)
+	}
+	return nil
+}
+
+// mergeConfigFile sets the keys in the file, comments and the other lines
+// are kept in place and new keys are added at the end
+func mergeConfigFile(path string, changes map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	written := make(map[string]bool)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		key, _, found := strings.Cut(trimmed, "=")
+		key = strings.TrimSpace(key)
+		if value, changed := changes[key]; found && changed {
+			lines[i] = key + "=" + value
+			written[key] = true
+		}
+	}
+
+	var added []string
+	for key := range changes {
+		if !written[key] {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	for _, key := range added {
+		lines = append(lines, key+"="+changes[key])
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// SetActiveProfile remembers the profile used at the next start
+func SetActiveProfile(configPath, profile string) error {
+	configPath = os.ExpandEnv(configPath)
+	configMap, err := loadConfigFromFile(configPath)
+	if err != nil {
+		return fmt.Errorf("error loading config file: %v", err)
+	}
+	configMap["Profile"] = profile
+	if err := saveConfigToFile(configPath, configMap); err != nil {
+		return fmt.Errorf("error saving config file: %v", err)
+	}
+	return nil
+}
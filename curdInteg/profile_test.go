@@ -0,0 +1,103 @@
+package curdInteg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveProfileConfigMergesOverrides(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "curd.conf")
+	if _, err := LoadConfig(configPath); err != nil {
+		t.Fatal(err)
+	}
+	base, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateProfile(configPath, "kid"); err != nil {
+		t.Fatal(err)
+	}
+
+	// An override equal to the main config, set on purpose
+	path := profileConfigPath(configPath, "kid")
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.WriteString("SubOrDub=" + base.SubOrDub + "\n")
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadProfileConfig(configPath, "kid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SkipOp = !base.SkipOp
+	config.SubOrDub = "dub"
+	if err := SaveProfileConfig(configPath, "kid", config); err != nil {
+		t.Fatal(err)
+	}
+	config.PercentageToMarkComplete = base.PercentageToMarkComplete + 1
+	if err := SaveProfileConfig(configPath, "kid", config); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if !strings.HasPrefix(lines[0], "# Config overrides of the profile kid") {
+		t.Errorf("header lost: %q", lines[0])
+	}
+	if lines[1] != "SubOrDub=dub" {
+		t.Errorf("override not updated in place: %q", lines[1])
+	}
+	if len(lines) != 4 {
+		t.Errorf("got %d lines, want the header and 3 overrides:\n%s", len(lines), data)
+	}
+
+	saved, err := LoadProfileConfig(configPath, "kid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.SkipOp == base.SkipOp || saved.SubOrDub != "dub" || saved.PercentageToMarkComplete != base.PercentageToMarkComplete+1 {
+		t.Errorf("saved as %+v", saved)
+	}
+
+	// Going back to the main config's value keeps it as an override
+	saved.SubOrDub = base.SubOrDub
+	if err := SaveProfileConfig(configPath, "kid", saved); err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := loadConfigFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overrides["SubOrDub"] != base.SubOrDub {
+		t.Errorf("override dropped: %v", overrides)
+	}
+	if main, _ := LoadConfig(configPath); main.SubOrDub != base.SubOrDub || main.SkipOp != base.SkipOp {
+		t.Error("main config changed by the profile")
+	}
+}
+
+func TestProfileStoragePath(t *testing.T) {
+	if got := ProfileStoragePath("/data", DefaultProfile); got != "/data" {
+		t.Errorf("default profile stored in %s", got)
+	}
+	path := ProfileStoragePath("/data", "kid")
+	if path != filepath.Join("/data", profilesDirName, "kid") {
+		t.Errorf("profile stored in %s", path)
+	}
+	if profile := profileOfStoragePath(path); profile != "kid" {
+		t.Errorf("storage path owned by %s", profile)
+	}
+	if profile := profileOfStoragePath("/data"); profile != DefaultProfile {
+		t.Errorf("base storage path owned by %s", profile)
+	}
+}
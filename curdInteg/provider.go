@@ -0,0 +1,157 @@
+package curdInteg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Provider is a site anime streams are scraped from.
+//
+// Show ids returned by Search are only meaningful for the provider that
+// returned them, the local history stores the ones of the primary provider.
+type Provider interface {
+	// Name is the key used in the Providers config field.
+	Name() string
+	// Search returns a map of show id to "Name (N episodes)" labels.
+	Search(ctx context.Context, query, mode string) (map[string]string, error)
+	// Episodes returns the sorted episode numbers available for the show.
+	Episodes(ctx context.Context, showID, mode string) ([]string, error)
+	// Sources returns the playable links of an episode.
+	Sources(ctx context.Context, showID, mode string, epNo int) ([]string, error)
+}
+
+var (
+	providersMutex sync.RWMutex
+	providers      = map[string]Provider{}
+	providerOrder  []string
+)
+
+func init() {
+	RegisterProvider(newAllanimeProvider())
+}
+
+// providerKey is the registry key of a provider name, names are case
+// insensitive
+func providerKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// RegisterProvider adds a provider to the registry, registering a name twice
+// replaces the previous provider.
+func RegisterProvider(p Provider) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+	key := providerKey(p.Name())
+	if _, exists := providers[key]; !exists {
+		providerOrder = append(providerOrder, key)
+	}
+	providers[key] = p
+}
+
+// GetProvider returns the registered provider with the given name.
+func GetProvider(name string) (Provider, error) {
+	providersMutex.RLock()
+	defer providersMutex.RUnlock()
+	p, ok := providers[providerKey(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q", name)
+	}
+	return p, nil
+}
+
+// ProviderChain returns the providers listed in the Providers config field,
+// in order. Unknown names are skipped and an empty list falls back to every
+// registered provider.
+func ProviderChain(config *CurdConfig) []Provider {
+	var chain []Provider
+	if config != nil {
+		for _, name := range strings.Split(config.Providers, ",") {
+			if strings.TrimSpace(name) == "" {
+				continue
+			}
+			p, err := GetProvider(name)
+			if err != nil {
+				Log(err.Error(), logFile)
+				continue
+			}
+			chain = append(chain, p)
+		}
+	}
+	if len(chain) > 0 {
+		return chain
+	}
+
+	providersMutex.RLock()
+	defer providersMutex.RUnlock()
+	for _, name := range providerOrder {
+		chain = append(chain, providers[name])
+	}
+	return chain
+}
+
+// PrimaryProvider is the first provider of the configured chain, the one
+// show ids are searched and saved with.
+func PrimaryProvider() Provider {
+	return ProviderChain(GetGlobalConfig())[0]
+}
+
+// GetEpisodeURLWithFallback asks every provider of the chain for the episode
+// links and returns the first non empty result. showID belongs to the first
+// provider of the chain, the other ones look the show up by title first.
+func GetEpisodeURLWithFallback(ctx context.Context, config CurdConfig, showID, title string, epNo int) ([]string, error) {
+	chain := ProviderChain(&config)
+	primary := chain[0]
+	var lastErr error
+	for _, p := range chain {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		id := showID
+		if p != primary {
+			var err error
+			id, err = findShowByTitle(ctx, p, title, config.SubOrDub)
+			if err != nil {
+				lastErr = err
+				Log(fmt.Sprintf("Provider %s: %v", p.Name(), err), logFile)
+				continue
+			}
+		}
+
+		links, err := p.Sources(ctx, id, config.SubOrDub, epNo)
+		if err != nil {
+			lastErr = err
+			Log(fmt.Sprintf("Provider %s: %v", p.Name(), err), logFile)
+			continue
+		}
+		if len(links) > 0 {
+			return links, nil
+		}
+		Log(fmt.Sprintf("Provider %s returned no links for episode %d", p.Name(), epNo), logFile)
+	}
+
+	if lastErr != nil {
+		return nil, fmt.Errorf("no provider returned links: %w", lastErr)
+	}
+	return nil, fmt.Errorf("no provider returned links for episode %d", epNo)
+}
+
+// findShowByTitle searches the provider and keeps the result whose label
+// starts with the exact title.
+func findShowByTitle(ctx context.Context, p Provider, title, mode string) (string, error) {
+	if title == "" {
+		return "", fmt.Errorf("no title to search with")
+	}
+	results, err := p.Search(ctx, title, mode)
+	if err != nil {
+		return "", err
+	}
+	for id, label := range results {
+		if strings.HasPrefix(strings.ToLower(label), strings.ToLower(title)+" (") {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("no match for %q", title)
+}
@@ -0,0 +1,108 @@
+package curdInteg
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// fakeProvider serves the links of a single show
+type fakeProvider struct {
+	name    string
+	shows   map[string]string
+	links   map[string][]string
+	sources []string
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) Search(ctx context.Context, query, mode string) (map[string]string, error) {
+	return p.shows, nil
+}
+
+func (p *fakeProvider) Episodes(ctx context.Context, showID, mode string) ([]string, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) Sources(ctx context.Context, showID, mode string, epNo int) ([]string, error) {
+	p.sources = append(p.sources, showID)
+	return p.links[showID], nil
+}
+
+// registerFake adds the provider for the duration of the test
+func registerFake(t *testing.T, p *fakeProvider) {
+	t.Helper()
+	RegisterProvider(p)
+	t.Cleanup(func() {
+		providersMutex.Lock()
+		defer providersMutex.Unlock()
+		key := providerKey(p.name)
+		delete(providers, key)
+		providerOrder = slices.DeleteFunc(providerOrder, func(name string) bool { return name == key })
+	})
+}
+
+func TestProviderNamesIgnoreCase(t *testing.T) {
+	fake := &fakeProvider{name: "Fake"}
+	registerFake(t, fake)
+
+	for _, name := range []string{"fake", "FAKE", " Fake "} {
+		if p, err := GetProvider(name); err != nil || p != fake {
+			t.Errorf("GetProvider(%q) = %v, %v", name, p, err)
+		}
+	}
+
+	replacement := &fakeProvider{name: "fake"}
+	RegisterProvider(replacement)
+	if p, _ := GetProvider("Fake"); p != replacement {
+		t.Error("registering the name in lower case didn't replace the provider")
+	}
+	if slices.Index(providerOrder, "fake") != len(providerOrder)-1 || slices.Contains(providerOrder, "Fake") {
+		t.Errorf("got registry order %v", providerOrder)
+	}
+}
+
+func TestProviderChain(t *testing.T) {
+	empty := &fakeProvider{name: "Empty"}
+	backup := &fakeProvider{name: "backup"}
+	registerFake(t, empty)
+	registerFake(t, backup)
+
+	chain := ProviderChain(&CurdConfig{Providers: "BACKUP, unknown, empty"})
+	if len(chain) != 2 || chain[0] != backup || chain[1] != empty {
+		t.Errorf("got %v", chain)
+	}
+	if all := ProviderChain(&CurdConfig{Providers: " , "}); len(all) != len(providerOrder) {
+		t.Errorf("empty list gave %d providers, want all %d", len(all), len(providerOrder))
+	}
+}
+
+func TestGetEpisodeURLWithFallback(t *testing.T) {
+	empty := &fakeProvider{name: "empty"}
+	backup := &fakeProvider{
+		name:  "backup",
+		shows: map[string]string{"b1": "Frieren 2nd Season (10 episodes)", "b2": "Frieren (28 episodes)"},
+		links: map[string][]string{"b2": {"https://example.com/frieren-5.m3u8"}},
+	}
+	registerFake(t, empty)
+	registerFake(t, backup)
+
+	config := CurdConfig{Providers: "empty,backup", SubOrDub: "sub"}
+	links, err := GetEpisodeURLWithFallback(context.Background(), config, "e1", "Frieren", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(links, []string{"https://example.com/frieren-5.m3u8"}) {
+		t.Errorf("got %v", links)
+	}
+	if !slices.Equal(empty.sources, []string{"e1"}) {
+		t.Errorf("first provider asked for %v, want the saved show id", empty.sources)
+	}
+	if !slices.Equal(backup.sources, []string{"b2"}) {
+		t.Errorf("fallback asked for %v, want the show found by title", backup.sources)
+	}
+
+	if _, err := GetEpisodeURLWithFallback(context.Background(), CurdConfig{Providers: "empty"}, "e1", "Frieren", 5); err == nil {
+		t.Error("no error without links")
+	}
+}
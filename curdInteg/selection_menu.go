@@ -1,12 +1,12 @@
 package curdInteg
 
 import (
+	"AnimeGUI/fuzzy"
+	"AnimeGUI/imagecache"
 	"bytes"
-	"crypto/md5"
+	"context"
 	"fmt"
 	"github.com/charmbracelet/bubbletea"
-	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -143,25 +143,26 @@ func (m Model) visibleItemsCount() int {
 	return m.terminalHeight - 4 // Adjust this number based on your terminal layout
 }
 
-// filterOptions filters and sorts options based on the search term
+// filterOptions filters the options with the fuzzy matcher, best matches
+// first and alphabetically otherwise
 func (m *Model) filterOptions() {
-	m.filteredKeys = []SelectionOption{}
-
+	options := make([]SelectionOption, 0, len(m.options))
 	for key, value := range m.options {
-		// When the key is " ", compare and display using the value instead
-		if key == " " {
-			if strings.Contains(strings.ToLower(value), strings.ToLower(m.filter)) {
-				m.filteredKeys = append(m.filteredKeys, SelectionOption{Label: value, Key: key})
-			}
-		} else if strings.Contains(strings.ToLower(value), strings.ToLower(m.filter)) {
-			m.filteredKeys = append(m.filteredKeys, SelectionOption{Label: value, Key: key})
-		}
+		options = append(options, SelectionOption{Label: value, Key: key})
 	}
 
-	// Sort the filtered options alphabetically
-	sort.Slice(m.filteredKeys, func(i, j int) bool {
-		return m.filteredKeys[i].Label < m.filteredKeys[j].Label
+	// Sort the options alphabetically, the ranking keeps that order for ties
+	sort.Slice(options, func(i, j int) bool {
+		return options[i].Label < options[j].Label
+	})
+
+	ranked := fuzzy.Rank(m.filter, len(options), func(i int) []string {
+		return []string{options[i].Label}
 	})
+	m.filteredKeys = make([]SelectionOption, 0, len(ranked)+2)
+	for _, index := range ranked {
+		m.filteredKeys = append(m.filteredKeys, options[index])
+	}
 
 	// Add "Add new anime" option if enabled
 	if m.addNewOption {
@@ -274,41 +275,10 @@ func preDownloadImages(options map[string]RofiSelectPreview, count int) {
 	}
 }
 
+// downloadToCache returns the path of the image in the cover cache shared
+// with the GUI, downloading it if needed
 func downloadToCache(imageURL string) (string, error) {
-	cacheDir := os.ExpandEnv("${HOME}/.cache/curd/images")
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create cache directory: %w", err)
-	}
-
-	// Create a hash of the URL to use as filename
-	filename := fmt.Sprintf("%x.jpg", md5.Sum([]byte(imageURL)))
-	cachePath := filepath.Join(cacheDir, filename)
-
-	// Check if file already exists in cache
-	if _, err := os.Stat(cachePath); err == nil {
-		return cachePath, nil
-	}
-
-	// Download the image
-	resp, err := http.Get(imageURL)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	file, err := os.Create(cachePath)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	_, err = io.Copy(file, resp.Body)
-	if err != nil {
-		os.Remove(cachePath) // Clean up on error
-		return "", err
-	}
-
-	return cachePath, nil
+	return imagecache.Default.Path(context.Background(), imageURL)
 }
 
 func showCachedImagePreview(imageURL string) error {
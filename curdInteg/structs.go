@@ -1,5 +1,7 @@
 package curdInteg
 
+import "time"
+
 type AnimeTitle struct {
 	Romaji   string `json:"title_romanji"`
 	English  string `json:"title"`
@@ -15,6 +17,8 @@ type Anime struct {
 	AnilistId     int        `json:"anilist_id"` // Assuming you have an Anilist ID in your struct
 	Rewatching    bool
 	AllanimeId    string // Can be populated as necessary
+	SubOrDub      string
+	LastWatched   time.Time
 }
 
 type Skip struct {
@@ -57,6 +61,9 @@ type User struct {
 	Username  string
 	Id        int
 	AnimeList AnimeList
+	AvatarURL string
+	// TokenExpiresAt is zero when the expiry is unknown
+	TokenExpiresAt time.Time
 }
 
 // AniListAnime is the struct for the API response
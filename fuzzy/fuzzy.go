@@ -0,0 +1,195 @@
+// Package fuzzy ranks anime titles against what the user typed, shared by the
+// GUI filters and curd's selection menu.
+//
+// Both sides are split in words. Every query word must match a title word
+// exactly, as a prefix, as a substring or within a few typos (Damerau
+// Levenshtein distance, one typo allowed per four letters). A query made of
+// the initials of the title, like aot for Attack on Titan, matches too, and
+// titles without spaces, like Japanese ones, are matched as a whole.
+package fuzzy
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Query is a normalized search, reused for every candidate
+type Query struct {
+	text  string
+	words []string
+}
+
+// NewQuery prepares the text for matching
+func NewQuery(text string) Query {
+	words := split(text)
+	return Query{text: strings.Join(words, " "), words: words}
+}
+
+// Empty is true when the query matches everything
+func (q Query) Empty() bool {
+	return len(q.words) == 0
+}
+
+// Score returns how well the best of the titles matches, 0 when none does.
+// An exact title scores 2, the more typos and partial words the lower.
+func (q Query) Score(titles ...string) float64 {
+	if q.Empty() {
+		return 1
+	}
+	best := 0.0
+	for _, title := range titles {
+		if title == "" {
+			continue
+		}
+		best = max(best, q.scoreTitle(title))
+	}
+	return best
+}
+
+func (q Query) scoreTitle(title string) float64 {
+	words := split(title)
+	if len(words) == 0 {
+		return 0
+	}
+	text := strings.Join(words, " ")
+	if text == q.text {
+		return 2
+	}
+
+	score := 0.0
+	if strings.Contains(text, q.text) {
+		// Typed as it is written, like most searches
+		score = 0.6
+		if strings.HasPrefix(text, q.text) {
+			score = 0.8
+		}
+	}
+
+	if initials := acronym(words); len(q.words) == 1 && len(q.text) >= 2 && len(words) >= 2 {
+		if q.text == initials {
+			score = max(score, 0.95)
+		} else if strings.HasPrefix(initials, q.text) {
+			score = max(score, 0.5)
+		}
+	}
+
+	total := 0.0
+	for _, queryWord := range q.words {
+		bestWord := 0.0
+		for _, word := range words {
+			bestWord = max(bestWord, scoreWord(queryWord, word))
+		}
+		if bestWord == 0 {
+			return score
+		}
+		total += bestWord
+	}
+	wordsScore := total / float64(len(q.words))
+	// Shorter titles are closer to what was typed
+	wordsScore -= 0.01 * float64(max(len(words)-len(q.words), 0))
+	return max(score, wordsScore)
+}
+
+// Similarity compares two whole titles, 1 when they only differ by case and
+// punctuation and 0 when nothing is alike
+func Similarity(a, b string) float64 {
+	a, b = strings.Join(split(a), " "), strings.Join(split(b), " ")
+	longest := max(len([]rune(a)), len([]rune(b)))
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(editDistance(a, b))/float64(longest)
+}
+
+// scoreWord compares a query word to a title word, 1 is an exact match
+func scoreWord(query, word string) float64 {
+	switch {
+	case query == word:
+		return 1
+	case strings.HasPrefix(word, query):
+		// Still typing the word
+		return 0.75 + 0.2*float64(len(query))/float64(len(word))
+	case len(query) >= 3 && strings.Contains(word, query):
+		return 0.55
+	}
+
+	allowed := len([]rune(query)) / 4
+	if allowed == 0 {
+		return 0
+	}
+	distance := editDistance(query, word)
+	// Typos while still typing the word
+	if prefix := []rune(word); len(prefix) > len([]rune(query)) {
+		distance = min(distance, editDistance(query, string(prefix[:len([]rune(query))])))
+	}
+	if distance > allowed {
+		return 0
+	}
+	return 0.7 - 0.15*float64(distance)
+}
+
+// editDistance is the optimal string alignment distance: insertions,
+// deletions, substitutions and swaps of two neighbour letters
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous2 := make([]int, len(rb)+1)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				current[j] = min(current[j], previous2[j-2]+1)
+			}
+		}
+		previous2, previous, current = previous, current, previous2
+	}
+	return previous[len(rb)]
+}
+
+// split lowercases the text and cuts it on everything that isn't a letter
+// or a digit
+func split(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func acronym(words []string) string {
+	var initials strings.Builder
+	for _, word := range words {
+		for _, r := range word {
+			initials.WriteRune(r)
+			break
+		}
+	}
+	return initials.String()
+}
+
+// Rank returns the indexes of the count candidates matching the query, best
+// first. Candidates scoring the same keep their order, an empty query keeps
+// them all as they are.
+func Rank(query string, count int, titles func(i int) []string) []int {
+	q := NewQuery(query)
+	indexes := make([]int, 0, count)
+	scores := make([]float64, count)
+	for i := 0; i < count; i++ {
+		scores[i] = q.Score(titles(i)...)
+		if scores[i] > 0 {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return scores[indexes[a]] > scores[indexes[b]]
+	})
+	return indexes
+}
@@ -0,0 +1,121 @@
+package fuzzy
+
+import (
+	"slices"
+	"testing"
+)
+
+// catalog is a list of anime as the GUI passes them: english, romaji,
+// native and synonyms
+var catalog = [][]string{
+	{"Attack on Titan", "Shingeki no Kyojin", "進撃の巨人", "AoT"},
+	{"Attack on Titan Final Season", "Shingeki no Kyojin: The Final Season", "進撃の巨人 The Final Season"},
+	{"Frieren: Beyond Journey's End", "Sousou no Frieren", "葬送のフリーレン"},
+	{"Fullmetal Alchemist: Brotherhood", "Hagane no Renkinjutsushi: Fullmetal Alchemist", "鋼の錬金術師 FULLMETAL ALCHEMIST"},
+	{"Kaiju No. 8", "Kaijuu 8-gou", "怪獣８号"},
+	{"Bocchi the Rock!", "Bocchi the Rock!", "ぼっち・ざ・ろっく！"},
+}
+
+func rank(query string) []string {
+	ranked := Rank(query, len(catalog), func(i int) []string { return catalog[i] })
+	names := make([]string, len(ranked))
+	for i, index := range ranked {
+		names[i] = catalog[index][0]
+	}
+	return names
+}
+
+func TestRank(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"shingeki", []string{"Attack on Titan", "Attack on Titan Final Season"}},
+		{"frieren beyond", []string{"Frieren: Beyond Journey's End"}},
+		{"aot", []string{"Attack on Titan", "Attack on Titan Final Season"}},
+		{"葬送", []string{"Frieren: Beyond Journey's End"}},
+		{"進撃の巨人", []string{"Attack on Titan", "Attack on Titan Final Season"}},
+		{"freiren", []string{"Frieren: Beyond Journey's End"}},
+		{"fulmetal alchemst", []string{"Fullmetal Alchemist: Brotherhood"}},
+		{"attack titan final", []string{"Attack on Titan Final Season"}},
+		{"kaiju 8", []string{"Kaiju No. 8"}},
+		{"BOCCHI!", []string{"Bocchi the Rock!"}},
+		{"naruto", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := rank(tt.query); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankEmptyQueryKeepsOrder(t *testing.T) {
+	for _, query := range []string{"", "  ", "!?"} {
+		ranked := Rank(query, len(catalog), func(i int) []string { return catalog[i] })
+		if len(ranked) != len(catalog) {
+			t.Fatalf("%q: got %d results, want all %d", query, len(ranked), len(catalog))
+		}
+		for i, index := range ranked {
+			if index != i {
+				t.Errorf("%q: moved %d to %d", query, index, i)
+			}
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	q := NewQuery("attack on titan")
+	exact := q.Score("Attack on Titan")
+	if exact != 2 {
+		t.Errorf("exact title scored %v", exact)
+	}
+	if longer := q.Score("Attack on Titan Final Season"); longer <= 0 || longer >= exact {
+		t.Errorf("longer title scored %v", longer)
+	}
+	typo := NewQuery("atack on titan").Score("Attack on Titan")
+	if typo <= 0 || typo >= exact {
+		t.Errorf("typo scored %v", typo)
+	}
+	if NewQuery("frieren").Score("Attack on Titan", "") != 0 {
+		t.Error("unrelated title matched")
+	}
+	// Short words don't allow typos
+	if NewQuery("ao").Score("Mob Psycho") != 0 {
+		t.Error("two letters matched with a typo")
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	if got := Similarity("Kaiju No. 8", "kaiju no 8"); got != 1 {
+		t.Errorf("punctuation changed the similarity: %v", got)
+	}
+	if got := Similarity("", ""); got != 0 {
+		t.Errorf("empty titles alike: %v", got)
+	}
+	close, far := Similarity("Sousou no Frieren", "Sousou no Furiiren"), Similarity("Sousou no Frieren", "One Piece")
+	if close <= far || close < 0.8 {
+		t.Errorf("close %v, far %v", close, far)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"frieren", "frieren", 0},
+		{"frieren", "freiren", 1},
+		{"kaiju", "kaijuu", 1},
+		{"titan", "titen", 1},
+		{"鋼の錬金術師", "鋼の錬金術", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
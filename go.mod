@@ -10,9 +10,11 @@ require (
 	github.com/bep/debounce v1.2.1
 	github.com/charmbracelet/bubbletea v1.1.1
 	github.com/charmbracelet/log v0.4.0
+	github.com/charmbracelet/x/term v0.2.0
 	github.com/dweymouth/fyne-tooltip v0.2.1
 	github.com/gen2brain/beeep v0.0.0-20240516210008-9c006672e7f4
 	github.com/hugolgst/rich-go v0.0.0-20240715122152-74618cc1ace2
+	golang.org/x/crypto v0.23.0
 )
 
 require (
@@ -21,7 +23,6 @@ require (
 	github.com/aymanbagabas/go-osc52/v2 v2.0.1 // indirect
 	github.com/charmbracelet/lipgloss v0.13.0 // indirect
 	github.com/charmbracelet/x/ansi v0.2.3 // indirect
-	github.com/charmbracelet/x/term v0.2.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/erikgeiser/coninput v0.0.0-20211004153227-1c3628e74d0f // indirect
 	github.com/fredbi/uri v1.1.0 // indirect
@@ -0,0 +1,333 @@
+// Package imagecache is the cover image cache shared by the GUI and curd.
+//
+// Images are kept on disk, named by the md5 of their url, in a size limited
+// LRU (access time is tracked with the file mtime so several processes can
+// share the directory). Files older than RevalidateAfter are checked again
+// with their ETag. Decoded images are also kept in memory.
+package imagecache
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RevalidateAfter is how long a cached file is trusted before asking the
+// server whether it changed
+const RevalidateAfter = 24 * time.Hour
+
+// Default is the cache in ~/.cache/curd/images, the directory curd used
+// before this package existed
+var Default = New(defaultDir(), 200<<20, 64)
+
+func defaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "curd", "images")
+	}
+	return filepath.Join(home, ".cache", "curd", "images")
+}
+
+// Cache is safe for concurrent use
+type Cache struct {
+	dir      string
+	maxBytes int64
+	client   *http.Client
+	memory   *memoryLRU
+
+	mutex    sync.Mutex
+	inflight map[string]*call
+	writes   int
+}
+
+type call struct {
+	done chan struct{}
+	path string
+	err  error
+}
+
+type meta struct {
+	URL     string    `json:"url"`
+	ETag    string    `json:"etag"`
+	Checked time.Time `json:"checked"`
+}
+
+// New creates a cache keeping at most maxBytes on disk and memoryEntries
+// decoded images in memory
+func New(dir string, maxBytes int64, memoryEntries int) *Cache {
+	return &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		memory:   newMemoryLRU(memoryEntries),
+		inflight: make(map[string]*call),
+	}
+}
+
+func (c *Cache) key(url string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(url)))
+}
+
+func (c *Cache) filePath(url string) string {
+	return filepath.Join(c.dir, c.key(url)+".jpg")
+}
+
+func (c *Cache) metaPath(url string) string {
+	return filepath.Join(c.dir, c.key(url)+".meta")
+}
+
+// Image returns the decoded image, from memory when possible
+func (c *Cache) Image(ctx context.Context, url string) (image.Image, error) {
+	if img, ok := c.memory.get(url); ok {
+		return img, nil
+	}
+
+	path, err := c.Path(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		// Don't keep a file we can't read
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	c.memory.add(url, img)
+	return img, nil
+}
+
+// Path returns the path of the cached file, downloading or revalidating it
+// first if needed. Concurrent calls for the same url share the download, it
+// isn't tied to the context of any of them so a caller giving up doesn't
+// fail the others.
+func (c *Cache) Path(ctx context.Context, url string) (string, error) {
+	if url == "" {
+		return "", errors.New("empty image url")
+	}
+
+	c.mutex.Lock()
+	pending, ok := c.inflight[url]
+	if !ok {
+		pending = &call{done: make(chan struct{})}
+		c.inflight[url] = pending
+		go c.download(url, pending)
+	}
+	c.mutex.Unlock()
+
+	select {
+	case <-pending.done:
+		return pending.path, pending.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// download runs the shared fetch, the client timeout bounds it
+func (c *Cache) download(url string, pending *call) {
+	pending.path, pending.err = c.fetch(context.Background(), url)
+
+	c.mutex.Lock()
+	delete(c.inflight, url)
+	c.mutex.Unlock()
+	close(pending.done)
+}
+
+func (c *Cache) fetch(ctx context.Context, url string) (string, error) {
+	path := c.filePath(url)
+	m := c.readMeta(url)
+
+	_, statErr := os.Stat(path)
+	cached := statErr == nil
+	if cached {
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		if time.Since(m.Checked) < RevalidateAfter {
+			return path, nil
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	if cached && m.ETag != "" {
+		req.Header.Set("If-None-Match", m.ETag)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if cached {
+			// Offline, a stale image is better than none
+			return path, nil
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached:
+		m.Checked = time.Now()
+		c.writeMeta(url, m)
+		return path, nil
+	case resp.StatusCode != http.StatusOK:
+		if cached {
+			return path, nil
+		}
+		return "", fmt.Errorf("failed to download image: status %d", resp.StatusCode)
+	}
+
+	if err := c.writeFile(path, resp.Body); err != nil {
+		return "", err
+	}
+	c.memory.remove(url)
+	c.writeMeta(url, meta{URL: url, ETag: resp.Header.Get("ETag"), Checked: time.Now()})
+	c.afterWrite()
+	return path, nil
+}
+
+// writeFile writes to a temp file then renames it, readers never see a
+// partial image
+func (c *Cache) writeFile(path string, body io.Reader) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, "download-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *Cache) readMeta(url string) meta {
+	var m meta
+	data, err := os.ReadFile(c.metaPath(url))
+	if err == nil {
+		_ = json.Unmarshal(data, &m)
+	}
+	return m
+}
+
+func (c *Cache) writeMeta(url string, m meta) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(c.metaPath(url), data, 0644)
+}
+
+// afterWrite runs the eviction every few downloads instead of on each one
+func (c *Cache) afterWrite() {
+	c.mutex.Lock()
+	c.writes++
+	run := c.writes%16 == 1
+	c.mutex.Unlock()
+	if run {
+		go c.Evict()
+	}
+}
+
+// Evict removes the least recently used files until the cache fits in its
+// size limit
+func (c *Cache) Evict() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	type cachedFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []cachedFile
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jpg") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cachedFile{filepath.Join(c.dir, entry.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	if total <= c.maxBytes {
+		return nil
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, file := range files {
+		if total <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(file.path); err == nil {
+			os.Remove(strings.TrimSuffix(file.path, ".jpg") + ".meta")
+			total -= file.size
+		}
+	}
+	return nil
+}
+
+// Prefetch downloads and decodes the images in the background, two at a time
+func (c *Cache) Prefetch(urls ...string) {
+	go func() {
+		semaphore := make(chan struct{}, 2)
+		var wg sync.WaitGroup
+		for _, url := range urls {
+			if url == "" {
+				continue
+			}
+			if _, ok := c.memory.get(url); ok {
+				continue
+			}
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(url string) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				defer cancel()
+				_, _ = c.Image(ctx, url)
+			}(url)
+		}
+		wg.Wait()
+	}()
+}
+
+// Cached returns the path the image would have on disk and whether it is
+// there, without any network access
+func (c *Cache) Cached(url string) (string, bool) {
+	path := c.filePath(url)
+	_, err := os.Stat(path)
+	return path, err == nil
+}
@@ -0,0 +1,179 @@
+package imagecache
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// coverServer serves a 100 byte body per path with the ETag "v1"
+type coverServer struct {
+	*httptest.Server
+	hits        atomic.Int32
+	revalidated atomic.Int32
+}
+
+func newCoverServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) *coverServer {
+	t.Helper()
+	s := &coverServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		if handler != nil {
+			handler(w, r)
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			s.revalidated.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(bytes.Repeat([]byte(r.URL.Path[1:2]), 100))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// expire makes the cached file due for revalidation
+func expire(c *Cache, url string) {
+	m := c.readMeta(url)
+	m.Checked = time.Now().Add(-2 * RevalidateAfter)
+	c.writeMeta(url, m)
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	server := newCoverServer(t, nil)
+	c := New(t.TempDir(), 250, 4)
+	ctx := context.Background()
+
+	paths := map[string]string{}
+	for i, name := range []string{"a", "b", "c"} {
+		path, err := c.Path(ctx, server.URL+"/"+name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		used := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(path, used, used)
+		paths[name] = path
+	}
+	// Using a again makes b the least recently used
+	if _, err := c.Path(ctx, server.URL+"/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Evict(); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, err := os.Stat(paths[name]); (err == nil) != want {
+			t.Errorf("%s kept: %v, want %v", name, err == nil, want)
+		}
+	}
+	if _, err := os.Stat(c.metaPath(server.URL + "/b")); err == nil {
+		t.Error("meta of the evicted file kept")
+	}
+}
+
+func TestRevalidateWithETag(t *testing.T) {
+	server := newCoverServer(t, nil)
+	c := New(t.TempDir(), 1<<20, 4)
+	url := server.URL + "/a"
+
+	path, err := c.Path(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Path(context.Background(), url); err != nil || server.hits.Load() != 1 {
+		t.Fatalf("fresh file downloaded again: %d requests, %v", server.hits.Load(), err)
+	}
+
+	expire(c, url)
+	if got, err := c.Path(context.Background(), url); err != nil || got != path {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if server.revalidated.Load() != 1 {
+		t.Errorf("expired file not revalidated with its ETag")
+	}
+	if time.Since(c.readMeta(url).Checked) > time.Minute {
+		t.Error("check time not updated after 304")
+	}
+	if data, _ := os.ReadFile(path); len(data) != 100 {
+		t.Errorf("304 changed the file: %d bytes", len(data))
+	}
+}
+
+func TestConcurrentCallersShareDownload(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := newCoverServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+	})
+	c := New(t.TempDir(), 1<<20, 4)
+	url := server.URL + "/a"
+
+	// The first caller gives up while the download runs
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.Path(ctx, url)
+		first <- err
+	}()
+	<-started
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Path(context.Background(), url)
+			errs <- err
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := server.hits.Load(); n != 1 {
+		t.Errorf("%d downloads, want 1", n)
+	}
+}
+
+func TestStaleFileWhenOffline(t *testing.T) {
+	server := newCoverServer(t, nil)
+	c := New(t.TempDir(), 1<<20, 4)
+	url := server.URL + "/a"
+
+	path, err := c.Path(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	expire(c, url)
+
+	if got, err := c.Path(context.Background(), url); err != nil || got != path {
+		t.Errorf("got %q, %v, want the stale file", got, err)
+	}
+	if _, err := c.Path(context.Background(), server.URL+"/b"); err == nil {
+		t.Error("no error for an image never downloaded")
+	}
+}
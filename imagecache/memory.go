@@ -0,0 +1,65 @@
+package imagecache
+
+import (
+	"container/list"
+	"image"
+	"sync"
+)
+
+// memoryLRU keeps the most recently used decoded images
+type memoryLRU struct {
+	mutex   sync.Mutex
+	max     int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	url string
+	img image.Image
+}
+
+func newMemoryLRU(max int) *memoryLRU {
+	return &memoryLRU{
+		max:     max,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (m *memoryLRU) get(url string) (image.Image, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	element, ok := m.entries[url]
+	if !ok {
+		return nil, false
+	}
+	m.order.MoveToFront(element)
+	return element.Value.(*memoryEntry).img, true
+}
+
+func (m *memoryLRU) add(url string, img image.Image) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if element, ok := m.entries[url]; ok {
+		element.Value.(*memoryEntry).img = img
+		m.order.MoveToFront(element)
+		return
+	}
+
+	m.entries[url] = m.order.PushFront(&memoryEntry{url: url, img: img})
+	for m.order.Len() > m.max {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).url)
+	}
+}
+
+func (m *memoryLRU) remove(url string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if element, ok := m.entries[url]; ok {
+		m.order.Remove(element)
+		delete(m.entries, url)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"AnimeGUI/fuzzy"
+	"AnimeGUI/imagecache"
 	"AnimeGUI/src/anilist"
 	"AnimeGUI/verniy"
 	"fmt"
@@ -34,6 +36,9 @@ var displayCategories = []string{
 
 func setDialogAddAnime() {
 	var searchResult []verniy.Media
+	// shown maps the rows of the list to searchResult
+	var shown []int
+	var searched string
 	var selectedAnime *verniy.Media
 	isAnimeSelected := binding.NewBool()
 
@@ -59,8 +64,41 @@ func setDialogAddAnime() {
 
 	listContainer := container.NewPadded(&canvas.Rectangle{FillColor: color.RGBA{R: grayScaleList, G: grayScaleList, B: grayScaleList, A: 255}, CornerRadius: 10}, listAnimeDisplay)
 
+	// showResults lists the results matching the query, the order of Anilist
+	// for the query it was searched with and the fuzzy ranking while typing
+	showResults := func(query string) {
+		var names []string
+		shown = shown[:0]
+		indexes := fuzzy.Rank(query, len(searchResult), func(i int) []string {
+			return anilist.MediaTitles(&searchResult[i])
+		})
+		if query == searched {
+			indexes = nil
+			for i := range searchResult {
+				indexes = append(indexes, i)
+			}
+		}
+		for _, i := range indexes {
+			if name := anilist.AnimeToName(&searchResult[i]); name != nil {
+				names = append(names, *name)
+				shown = append(shown, i)
+			}
+		}
+		animesNames.Set(names)
+	}
+
 	inputSearch := widget.NewEntry()
 	inputSearch.SetPlaceHolder("Search")
+	inputSearch.OnChanged = func(s string) {
+		// Narrow the results already there, Enter searches Anilist again
+		if searchResult == nil {
+			return
+		}
+		isAnimeSelected.Set(false)
+		selectedAnime = nil
+		listAnimeDisplay.UnselectAll()
+		showResults(s)
+	}
 	inputSearch.OnSubmitted = func(s string) {
 		isAnimeSelected.Set(false)
 		selectedAnime = nil
@@ -74,13 +112,16 @@ func setDialogAddAnime() {
 			return
 		}
 		searchResult = result
-		animesNames.Set([]string{})
-		for i := 0; i < len(result); i++ {
-			name := anilist.AnimeToName(&result[i])
-			if name != nil {
-				animesNames.Append(*name)
+		searched = s
+		showResults(s)
+		var covers []string
+		for i := 0; i < len(result) && i < 5; i++ {
+			if result[i].CoverImage != nil && result[i].CoverImage.Large != nil {
+				covers = append(covers, *result[i].CoverImage.Large)
 			}
 		}
+		// The first results are the most likely to be picked
+		imagecache.Default.Prefetch(covers...)
 
 		fmt.Printf("Result: %+v\n", result)
 	}
@@ -95,12 +136,12 @@ func setDialogAddAnime() {
 		if selectedAnime == nil {
 			return
 		}
-		err := anilist.UpdateAnimeStatus(user.Token, selectedAnime.ID, displayToCategories[selectCategory.Selected])
+		err := anilist.UpdateAnimeStatus(selectedAnime.ID, displayToCategories[selectCategory.Selected])
 		if err != nil {
 			log.Error("Error updating anime status:", err)
 			return
 		}
-		log.Info("Anime added successfully")
+		log.Info("Anime queued for Anilist")
 		dialogAdd.Hide()
 	}
 
@@ -111,20 +152,17 @@ func setDialogAddAnime() {
 	dialogAdd.Resize(fyne.NewSize(850, 580))
 
 	listAnimeDisplay.OnSelected = func(id int) {
-
-		imageLink := searchResult[id].CoverImage.Large
+		if id >= len(shown) {
+			return
+		}
+		anime := &searchResult[shown[id]]
 		isAnimeSelected.Set(true)
-		selectedAnime = &searchResult[id]
-
-		if imageLink != nil {
-			imageFile := GetImageFromUrl(*imageLink)
-			if imageFile == nil {
-				log.Error("No image found")
-				return
-			}
+		selectedAnime = anime
 
-			*animeImageHolder = *getAnimeImageFromImage(imageFile, 220)
-			imageContainer.Refresh()
+		if anime.CoverImage != nil && anime.CoverImage.Large != nil {
+			imageLink := anime.CoverImage.Large
+			selected := selectedAnime
+			loadCoverAsync(animeImageHolder, imageContainer, *imageLink, 220, func() bool { return selectedAnime == selected })
 		}
 
 	}
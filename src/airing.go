@@ -0,0 +1,272 @@
+package main
+
+import (
+	"AnimeGUI/atomicfile"
+	curd "AnimeGUI/curdInteg"
+	"AnimeGUI/src/anilist"
+	"AnimeGUI/verniy"
+	"context"
+	"encoding/json"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/log"
+	"github.com/gen2brain/beeep"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	airingCheckInterval = time.Minute
+	scheduleRefresh     = time.Hour
+	// availabilityInterval is how often the provider is asked for an aired
+	// episode, it usually shows up within an hour
+	availabilityInterval = 10 * time.Minute
+	availabilityTimeout  = 24 * time.Hour
+	scheduleTimeout      = 30 * time.Second
+	calendarDays         = 7
+)
+
+// notifier raises the airing notifications of the profile in use
+var notifier *airingNotifier
+
+// airingNotifier watches the schedule of the anime being watched and tells
+// when a new episode aired, and optionally when the provider has it
+type airingNotifier struct {
+	cancel context.CancelFunc
+	// path keeps the last episode announced of each anime, nothing is
+	// announced twice across restarts
+	path string
+
+	mutex     sync.Mutex
+	schedule  []verniy.Media
+	announced map[int]int
+	// waiting are the aired episodes not on the provider yet
+	waiting map[int]waitingEpisode
+}
+
+type waitingEpisode struct {
+	media   *verniy.Media
+	episode int
+	since   time.Time
+}
+
+// startAiringNotifier replaces the notifier with the one of the profile in
+// use
+func startAiringNotifier() {
+	if notifier != nil {
+		notifier.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	notifier = &airingNotifier{
+		cancel:    cancel,
+		path:      filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "airing_notified.json"),
+		announced: make(map[int]int),
+		waiting:   make(map[int]waitingEpisode),
+	}
+	if data, err := os.ReadFile(notifier.path); err == nil {
+		_ = json.Unmarshal(data, &notifier.announced)
+	}
+	go notifier.run(ctx)
+}
+
+func (n *airingNotifier) run(ctx context.Context) {
+	check := time.NewTicker(airingCheckInterval)
+	defer check.Stop()
+	var lastRefresh, lastAvailability time.Time
+	for {
+		// The list is loaded in the background at start
+		if anilist.UserData != nil && time.Since(lastRefresh) >= scheduleRefresh {
+			n.refresh(ctx)
+			lastRefresh = time.Now()
+		}
+		n.announceAired(time.Now())
+		if time.Since(lastAvailability) >= availabilityInterval {
+			n.checkAvailability(ctx)
+			lastAvailability = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-check.C:
+		}
+	}
+}
+
+// refresh fetches the schedule, an anime seen for the first time only
+// remembers what already aired
+func (n *airingNotifier) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, scheduleTimeout)
+	defer cancel()
+	schedule, err := anilist.WatchingSchedule(ctx, calendarDays)
+	if err != nil {
+		log.Error("Can't fetch the airing schedule", "err", err)
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.schedule = schedule
+	changed := false
+	for _, media := range schedule {
+		if _, known := n.announced[media.ID]; !known {
+			n.announced[media.ID] = anilist.AiredEpisodes(&media)
+			changed = true
+		}
+	}
+	if changed {
+		n.saveLocked()
+	}
+}
+
+// Schedule returns the last schedule fetched
+func (n *airingNotifier) Schedule() []verniy.Media {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.schedule
+}
+
+// announceAired notifies the episodes aired since the last announce, those
+// aired while the app was closed included
+func (n *airingNotifier) announceAired(now time.Time) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	changed := false
+	for i := range n.schedule {
+		media := &n.schedule[i]
+		aired := anilist.AiredEpisodes(media)
+		for _, airing := range anilist.Airings(n.schedule[i:i+1], time.Time{}, now.Add(time.Second)) {
+			aired = max(aired, airing.Episode)
+		}
+		announced, known := n.announced[media.ID]
+		if !known || aired <= announced {
+			continue
+		}
+		n.announced[media.ID] = aired
+		changed = true
+
+		if userCurdConfig.AiringNotifications {
+			notify("New episode aired", fmt.Sprintf("%s episode %d is out", franchiseTitle(media), aired))
+		}
+		if userCurdConfig.NotifyWhenAvailable {
+			n.waiting[media.ID] = waitingEpisode{media: media, episode: aired, since: now}
+		}
+	}
+	if changed {
+		n.saveLocked()
+	}
+}
+
+// checkAvailability asks the provider for the aired episodes it didn't have
+// yet, anime never played aren't linked to the provider and are skipped
+func (n *airingNotifier) checkAvailability(ctx context.Context) {
+	n.mutex.Lock()
+	waiting := make([]waitingEpisode, 0, len(n.waiting))
+	for id, w := range n.waiting {
+		if time.Since(w.since) > availabilityTimeout {
+			delete(n.waiting, id)
+			continue
+		}
+		waiting = append(waiting, w)
+	}
+	n.mutex.Unlock()
+
+	for _, w := range waiting {
+		local := SearchFromLocalAniId(w.media.ID)
+		if local == nil {
+			n.mutex.Lock()
+			delete(n.waiting, w.media.ID)
+			n.mutex.Unlock()
+			continue
+		}
+		requestCtx, cancel := context.WithTimeout(ctx, scheduleTimeout)
+		episodes, err := curd.EpisodesList(requestCtx, local.AllanimeId, userCurdConfig.SubOrDub)
+		cancel()
+		if err != nil {
+			log.Error("Can't check the episodes of the provider", "err", err)
+			continue
+		}
+		if !slices.Contains(episodes, strconv.Itoa(w.episode)) {
+			continue
+		}
+		notify("New episode available", fmt.Sprintf("%s episode %d can be watched", franchiseTitle(w.media), w.episode))
+		n.mutex.Lock()
+		delete(n.waiting, w.media.ID)
+		n.mutex.Unlock()
+	}
+}
+
+func (n *airingNotifier) saveLocked() {
+	data, err := json.Marshal(n.announced)
+	if err != nil {
+		return
+	}
+	if err := atomicfile.Write(n.path, data); err != nil {
+		log.Error("Can't save the airing notifications", "err", err)
+	}
+}
+
+func notify(title, message string) {
+	if err := beeep.Notify(title, message, ""); err != nil {
+		log.Error("Can't send the notification", "err", err)
+	}
+}
+
+// openAiringCalendar shows the episodes of the anime being watched airing in
+// the coming week, one column per day
+func openAiringCalendar() {
+	days := container.NewGridWithColumns(calendarDays)
+	status := widget.NewLabel("Loading the schedule…")
+	calendar := dialog.NewCustom("Airing this week", "Close", container.NewBorder(status, nil, nil, nil, container.NewVScroll(days)), window)
+	calendar.Resize(fyne.NewSize(1100, 600))
+	calendar.Show()
+
+	go func() {
+		schedule := []verniy.Media(nil)
+		if notifier != nil {
+			schedule = notifier.Schedule()
+		}
+		if schedule == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), scheduleTimeout)
+			defer cancel()
+			var err error
+			schedule, err = anilist.WatchingSchedule(ctx, calendarDays)
+			if err != nil {
+				log.Error("Can't fetch the airing schedule", "err", err)
+			}
+		}
+
+		now := time.Now()
+		today := anilist.StartOfDay(now)
+		airings := anilist.Airings(schedule, today, today.AddDate(0, 0, calendarDays))
+		status.SetText(fmt.Sprintf("%d episodes of the %d anime you are watching", len(airings), len(schedule)))
+		if len(schedule) == 0 {
+			status.SetText("Nothing you are watching is airing")
+		}
+
+		for day := 0; day < calendarDays; day++ {
+			start := today.AddDate(0, 0, day)
+			column := container.NewVBox(widget.NewLabelWithStyle(start.Format("Mon 2 Jan"), fyne.TextAlignCenter, fyne.TextStyle{Bold: true}))
+			for _, airing := range airings {
+				if airing.AiringAt.Before(start) || !airing.AiringAt.Before(start.AddDate(0, 0, 1)) {
+					continue
+				}
+				text := fmt.Sprintf("%s\n%s\nEpisode %d", airing.AiringAt.Format("15:04"), franchiseTitle(airing.Media), airing.Episode)
+				card := widget.NewLabel(text)
+				card.Wrapping = fyne.TextWrapWord
+				if airing.AiringAt.Before(now) {
+					card.Importance = widget.LowImportance
+				}
+				column.Add(widget.NewCard("", "", card))
+			}
+			days.Add(column)
+		}
+		days.Refresh()
+	}()
+}
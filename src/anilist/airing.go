@@ -0,0 +1,157 @@
+package anilist
+
+import (
+	"AnimeGUI/verniy"
+	"context"
+	"sort"
+	"time"
+)
+
+// schedulePerPage is the most anime a single schedule request asks for
+const schedulePerPage = 50
+
+// Airing is an episode of an anime and when it airs
+type Airing struct {
+	Media    *verniy.Media
+	Episode  int
+	AiringAt time.Time
+}
+
+var scheduleFields = []verniy.MediaField{
+	verniy.MediaFieldID,
+	verniy.MediaFieldTitle(
+		verniy.MediaTitleFieldRomaji,
+		verniy.MediaTitleFieldEnglish),
+	verniy.MediaFieldStatusV2,
+	verniy.MediaFieldEpisodes,
+	verniy.MediaFieldNextAiringEpisode(
+		verniy.AiringScheduleFieldEpisode,
+		verniy.AiringScheduleFieldAiringAt),
+}
+
+// WatchingMedia returns the anime being watched or rewatched as the list
+// knows them
+func WatchingMedia() []verniy.Media {
+	entries := watchingEntries()
+	media := make([]verniy.Media, len(entries))
+	for i, entry := range entries {
+		media[i] = *entry.Media
+	}
+	return media
+}
+
+// watchingEntries returns the entries being watched or rewatched, a queued
+// status change included
+func watchingEntries() []*verniy.MediaList {
+	var entries []*verniy.MediaList
+	seen := make(map[int]bool)
+	for i := range UserData {
+		for j := range UserData[i].Entries {
+			entry := &UserData[i].Entries[j]
+			if entry.Media == nil || seen[entry.Media.ID] {
+				continue
+			}
+			status := ListStatus(entry.Media.ID)
+			if status != verniy.MediaListStatusCurrent && status != verniy.MediaListStatusRepeating {
+				continue
+			}
+			seen[entry.Media.ID] = true
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// WatchingSchedule fetches the anime being watched and their episodes airing
+// from the start of today for the given days, those already out today
+// included. When Anilist can't be reached the next episodes known by the
+// list are returned along with the error.
+func WatchingSchedule(ctx context.Context, days int) ([]verniy.Media, error) {
+	watching := WatchingMedia()
+	ids := make([]int, len(watching))
+	for i, media := range watching {
+		ids[i] = media.ID
+	}
+
+	var fetched []verniy.Media
+	for start := 0; start < len(ids); start += schedulePerPage {
+		end := min(start+schedulePerPage, len(ids))
+		page, err := Client.SearchAnimeWithContext(ctx, verniy.PageParamMedia{IDIn: ids[start:end]}, 1, schedulePerPage, scheduleFields...)
+		if err != nil {
+			return watching, err
+		}
+		fetched = append(fetched, page.Media...)
+	}
+
+	today := StartOfDay(time.Now())
+	schedules, err := airingSchedules(ctx, ids, today, today.AddDate(0, 0, days))
+	if err != nil {
+		return fetched, err
+	}
+	for i := range fetched {
+		fetched[i].AiringSchedule = &verniy.AiringScheduleConnection{Nodes: schedules[fetched[i].ID]}
+	}
+	return fetched, nil
+}
+
+// airingSchedules fetches the episodes of the anime airing in [from, to) by
+// anime
+func airingSchedules(ctx context.Context, ids []int, from, to time.Time) (map[int][]verniy.AiringSchedule, error) {
+	schedules := make(map[int][]verniy.AiringSchedule)
+	for start := 0; start < len(ids); start += schedulePerPage {
+		query := verniy.PageParamAiringSchedules{
+			MediaIDIn: ids[start:min(start+schedulePerPage, len(ids))],
+			// Both bounds are exclusive
+			AiringAtGreater: int(from.Unix()) - 1,
+			AiringAtLesser:  int(to.Unix()),
+		}
+		for page := 1; ; page++ {
+			result, err := Client.SearchAiringSchedulesWithContext(ctx, query, page, schedulePerPage)
+			if err != nil {
+				return schedules, err
+			}
+			for _, schedule := range result.AiringSchedules {
+				schedules[schedule.MediaID] = append(schedules[schedule.MediaID], schedule)
+			}
+			if next := result.PageInfo.HasNextPage; next == nil || !*next {
+				break
+			}
+		}
+	}
+	return schedules, nil
+}
+
+// StartOfDay is midnight of the day of t, in its location
+func StartOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Airings lists the episodes of the anime airing in [from, to), the next
+// episode and the schedule are merged
+func Airings(media []verniy.Media, from, to time.Time) []Airing {
+	var airings []Airing
+	for i := range media {
+		m := &media[i]
+		episodes := make(map[int]bool)
+		add := func(schedule verniy.AiringSchedule) {
+			at := time.Unix(int64(schedule.AiringAt), 0)
+			if episodes[schedule.Episode] || at.Before(from) || !at.Before(to) {
+				return
+			}
+			episodes[schedule.Episode] = true
+			airings = append(airings, Airing{Media: m, Episode: schedule.Episode, AiringAt: at})
+		}
+		if m.NextAiringEpisode != nil {
+			add(*m.NextAiringEpisode)
+		}
+		if m.AiringSchedule != nil {
+			for _, node := range m.AiringSchedule.Nodes {
+				add(node)
+			}
+		}
+	}
+	sort.SliceStable(airings, func(i, j int) bool {
+		return airings[i].AiringAt.Before(airings[j].AiringAt)
+	})
+	return airings
+}
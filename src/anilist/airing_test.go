@@ -0,0 +1,79 @@
+package anilist
+
+import (
+	"AnimeGUI/verniy"
+	"AnimeGUI/verniy/limiter"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchingScheduleFromStartOfToday(t *testing.T) {
+	today := StartOfDay(time.Now())
+	earlyToday := today.Add(time.Minute)
+	nextWeek := today.AddDate(0, 0, 7)
+	airingAt := regexp.MustCompile(`airingAt_greater:(\d+)`)
+
+	var greater int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query string `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !strings.Contains(request.Query, "airingSchedules") {
+			fmt.Fprintf(w, `{"data":{"Page":{"pageInfo":{"hasNextPage":false},"media":[{"id":1,"episodes":12,"nextAiringEpisode":{"episode":6,"airingAt":%d}}]}}}`, nextWeek.Unix())
+			return
+		}
+		if match := airingAt.FindStringSubmatch(request.Query); match != nil {
+			greater, _ = strconv.Atoi(match[1])
+		}
+		if !strings.Contains(request.Query, "mediaId_in:[1]") {
+			t.Errorf("schedule asked for other anime: %s", request.Query)
+		}
+		fmt.Fprintf(w, `{"data":{"Page":{"pageInfo":{"hasNextPage":false},"airingSchedules":[{"mediaId":1,"episode":5,"airingAt":%d}]}}}`, earlyToday.Unix())
+	}))
+	defer server.Close()
+
+	previous := Client
+	Client = verniy.New()
+	Client.Host = server.URL
+	Client.Limiter = limiter.New(1000, time.Second)
+	defer func() { Client = previous }()
+
+	entry := testEntry("Dandadan", 4, 12, 0, verniy.MediaStatusReleasing)
+	entry.Media.ID = 1
+	entry.Status = ptr(verniy.MediaListStatusCurrent)
+	useList(t, "Watching", []verniy.MediaList{entry})
+
+	schedule, err := WatchingSchedule(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(greater) != today.Unix()-1 {
+		t.Errorf("schedule starts at %v, want the start of today", time.Unix(int64(greater), 0))
+	}
+
+	// The episode aired this morning is still in today's column
+	airings := Airings(schedule, today, today.AddDate(0, 0, 7))
+	if len(airings) != 1 || airings[0].Episode != 5 || !airings[0].AiringAt.Equal(earlyToday) {
+		t.Errorf("got %+v", airings)
+	}
+}
+
+func TestStartOfDay(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	got := StartOfDay(time.Date(2026, 10, 16, 23, 59, 0, 0, tokyo))
+	if want := time.Date(2026, 10, 16, 0, 0, 0, 0, tokyo); !got.Equal(want) || got.Location() != tokyo {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -3,19 +3,29 @@ package anilist
 import (
 	"AnimeGUI/verniy"
 	"fmt"
+	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/widget"
 	"github.com/charmbracelet/log"
 	"net/url"
+	"os"
 	"strings"
 )
 
 var fields = []verniy.MediaListGroupField{
 	verniy.MediaListGroupFieldName,
+	verniy.MediaListGroupFieldIsCustomList,
 	verniy.MediaListGroupFieldEntries(
 		verniy.MediaListFieldID,
 		verniy.MediaListFieldStatus,
 		verniy.MediaListFieldScore,
 		verniy.MediaListFieldProgress,
+		verniy.MediaListFieldCustomLists,
+		verniy.MediaListFieldRepeat,
+		verniy.MediaListFieldPrivate,
+		verniy.MediaListFieldNotes,
+		verniy.MediaListFieldStartedAt,
+		verniy.MediaListFieldCompletedAt,
+		verniy.MediaListFieldUpdatedAt,
 		verniy.MediaListFieldMedia(
 			verniy.MediaFieldID,
 			verniy.MediaFieldNextAiringEpisode(
@@ -34,11 +44,19 @@ var fields = []verniy.MediaListGroupField{
 			verniy.MediaFieldAverageScore,
 			verniy.MediaFieldPopularity,
 			verniy.MediaFieldIsAdult,
+			verniy.MediaFieldGenres,
+			verniy.MediaFieldSynonyms,
+			verniy.MediaFieldSeasonYear,
+			verniy.MediaFieldEndDate,
 			verniy.MediaFieldEpisodes)),
 }
 
 var UserData []verniy.MediaListGroup
 
+// Offline is true while the list shown comes from the snapshot because
+// Anilist can't be reached
+var Offline = binding.NewBool()
+
 var categoriesToInt = make(map[string]int)
 
 /*var categoriesToInt = map[string]int{
@@ -50,36 +68,83 @@ var categoriesToInt = make(map[string]int)
 
 var Client *verniy.Client = verniy.New()
 
-func GetData(radio *widget.RadioGroup, username string, delete func()) {
-	typeAnime, err := Client.GetUserAnimeListSort(username, verniy.MediaListSortUpdatedTimeDesc, fields...)
+// GetData shows the snapshot of the list right away when there is one, then
+// fetches it from Anilist. When Anilist can't be reached the snapshot stays
+// in use and Offline is set.
+func GetData(radio *widget.RadioGroup, owner Owner, delete func()) error {
+	if UserData == nil {
+		snapshot, err := LoadSnapshot()
+		if err == nil && snapshot.Owner.ID == owner.ID {
+			if ScoreFormat == "" {
+				ScoreFormat = snapshot.ScoreFormat
+			}
+			setUserData(snapshot.Groups, radio)
+		} else if err != nil && !os.IsNotExist(err) {
+			log.Error(err)
+		}
+	}
+
+	typeAnime, err := Client.GetUserAnimeListSort(owner.Username, verniy.MediaListSortUpdatedTimeDesc, fields...)
 	if err != nil {
-		typeAnime = make([]verniy.MediaListGroup, 4)
-		log.Error("Invalid token")
 		log.Error(err)
-		delete()
+		// A network error doesn't mean the token is bad, only forget it when
+		// Anilist refused it
+		if verniy.IsUnauthorized(err) {
+			log.Error("Invalid token")
+			delete()
+			setUserData(make([]verniy.MediaListGroup, 4), radio)
+			return err
+		}
+		_ = Offline.Set(true)
+		if UserData == nil {
+			setUserData(make([]verniy.MediaListGroup, 4), radio)
+		}
+		return err
 	}
 
-	categoriesToInt = make(map[string]int)
+	_ = Offline.Set(false)
+	if err := fetchScoreFormat(); err != nil {
+		log.Error(err)
+	}
+	if Updates != nil {
+		// Anilist doesn't have the queued changes yet, they are replayed now
+		// instead of at the end of their backoff
+		typeAnime = Updates.applyTo(typeAnime)
+		Updates.SendNow()
+	}
+	setUserData(typeAnime, radio)
+	if err := saveSnapshot(owner, typeAnime); err != nil {
+		log.Error(err)
+	}
+	return nil
+}
+
+// setUserData replaces the list and refreshes the selected category
+func setUserData(typeAnime []verniy.MediaListGroup, radio *widget.RadioGroup) {
+	newCategoriesToInt := make(map[string]int)
 	for i := 0; i < len(typeAnime); i++ {
 		if typeAnime[i].Name != nil {
-			categoriesToInt[*typeAnime[i].Name] = i
-			//typeAnime[i].Entries =
+			newCategoriesToInt[*typeAnime[i].Name] = i
 		}
 	}
 
+	categoriesToInt = newCategoriesToInt
 	UserData = typeAnime
 	if radio != nil {
-		if radio.Selected == "" {
-			radio.SetSelected("Watching")
-		}
+		updateCategories(radio)
 	}
 }
 
+// FindList returns the entries of the group, by name or by its label in the
+// category selector
 func FindList(categoryName string) *[]verniy.MediaList {
 	if UserData == nil {
 		log.Error("No data found")
 		return &[]verniy.MediaList{}
 	}
+	if name, exists := labelsToCategories[categoryName]; exists {
+		categoryName = name
+	}
 	categoryIndex, exists := categoriesToInt[categoryName]
 	if !exists {
 		log.Error("Category not found in user")
@@ -88,28 +153,18 @@ func FindList(categoryName string) *[]verniy.MediaList {
 	return &UserData[categoryIndex].Entries
 }
 
-func FindListWithQuery(categoryName string, query string) *[]verniy.MediaList {
-	fullList := FindList(categoryName)
-	if fullList == nil {
-		return &[]verniy.MediaList{}
-	}
-	if len(*fullList) == 0 {
-		return &[]verniy.MediaList{}
-	}
-	tempMediaList := make([]verniy.MediaList, 0, 25)
-	for _, anime := range *fullList {
-		englishName := AnimeToName(anime.Media)
-		if englishName == nil {
-			continue
-		}
-		if strings.Contains(strings.ToLower(*englishName), strings.ToLower(query)) {
-			tempMediaList = append(tempMediaList, anime)
-		} else if strings.Contains(strings.ToLower(AnimeToRomaji(anime.Media)), strings.ToLower(query)) {
-			tempMediaList = append(tempMediaList, anime)
+// FindEntry returns the entry of the anime in any category, nil when it isn't
+// in the list
+func FindEntry(mediaID int) *verniy.MediaList {
+	for i := range UserData {
+		for j := range UserData[i].Entries {
+			entry := &UserData[i].Entries[j]
+			if entry.Media != nil && entry.Media.ID == mediaID {
+				return entry
+			}
 		}
 	}
-
-	return &tempMediaList
+	return nil
 }
 
 func AnimeToName(anime *verniy.Media) *string {
@@ -125,6 +180,23 @@ func AnimeToName(anime *verniy.Media) *string {
 	return anime.Title.Romaji
 }
 
+// MediaTitles returns every title of the anime, synonyms included, for the
+// fuzzy search
+func MediaTitles(anime *verniy.Media) []string {
+	if anime == nil {
+		return nil
+	}
+	titles := make([]string, 0, 3+len(anime.Synonyms))
+	if anime.Title != nil {
+		for _, title := range []*string{anime.Title.English, anime.Title.Romaji, anime.Title.Native} {
+			if title != nil {
+				titles = append(titles, *title)
+			}
+		}
+	}
+	return append(titles, anime.Synonyms...)
+}
+
 func AnimeToRomaji(anime *verniy.Media) string {
 	if anime == nil {
 		return ""
@@ -0,0 +1,250 @@
+package anilist
+
+import (
+	"AnimeGUI/verniy"
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2/widget"
+	"sort"
+	"time"
+)
+
+// StatusNames are the names Anilist gives to the status lists
+var StatusNames = map[verniy.MediaListStatus]string{
+	verniy.MediaListStatusCurrent:   "Watching",
+	verniy.MediaListStatusRepeating: "Rewatching",
+	verniy.MediaListStatusPlanning:  "Planning",
+	verniy.MediaListStatusCompleted: "Completed",
+	verniy.MediaListStatusPaused:    "Paused",
+	verniy.MediaListStatusDropped:   "Dropped",
+}
+
+// StatusOrder is the order of the status lists in the category selector
+var StatusOrder = []verniy.MediaListStatus{
+	verniy.MediaListStatusCurrent,
+	verniy.MediaListStatusRepeating,
+	verniy.MediaListStatusPlanning,
+	verniy.MediaListStatusCompleted,
+	verniy.MediaListStatusPaused,
+	verniy.MediaListStatusDropped,
+}
+
+// alwaysShown are the status lists shown even when Anilist has no entry in
+// them
+var alwaysShown = map[verniy.MediaListStatus]bool{
+	verniy.MediaListStatusCurrent:   true,
+	verniy.MediaListStatusPlanning:  true,
+	verniy.MediaListStatusCompleted: true,
+	verniy.MediaListStatusDropped:   true,
+}
+
+// labelsToCategories maps the labels of the category selector to the group
+// names
+var labelsToCategories = make(map[string]string)
+
+// Category is a group of the list as shown in the category selector
+type Category struct {
+	Name   string
+	Count  int
+	Custom bool
+}
+
+func (c Category) Label() string {
+	return fmt.Sprintf("%s (%d)", c.Name, c.Count)
+}
+
+// Categories returns the status lists in StatusOrder then the custom lists
+func Categories() []Category {
+	var categories []Category
+	seen := make(map[string]bool)
+	add := func(group verniy.MediaListGroup) {
+		if group.Name == nil || seen[*group.Name] {
+			return
+		}
+		seen[*group.Name] = true
+		categories = append(categories, Category{Name: *group.Name, Count: len(group.Entries), Custom: isCustomGroup(group)})
+	}
+
+	for _, status := range StatusOrder {
+		for _, group := range UserData {
+			if !isCustomGroup(group) && group.Status != nil && *group.Status == status {
+				add(group)
+			}
+		}
+		if name := StatusNames[status]; alwaysShown[status] && !seen[name] {
+			seen[name] = true
+			categories = append(categories, Category{Name: name})
+		}
+	}
+	for _, group := range UserData {
+		add(group)
+	}
+	return categories
+}
+
+// CustomListNames returns every custom list of the user, even empty ones
+func CustomListNames() []string {
+	names := make(map[string]bool)
+	for _, group := range UserData {
+		if isCustomGroup(group) && group.Name != nil {
+			names[*group.Name] = true
+		}
+		for _, entry := range group.Entries {
+			for name := range entry.CustomLists {
+				names[name] = true
+			}
+		}
+	}
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func isCustomGroup(group verniy.MediaListGroup) bool {
+	return group.IsCustomList != nil && *group.IsCustomList
+}
+
+// updateCategories refreshes the options of the category selector, keeping
+// the selected category
+func updateCategories(radio *widget.RadioGroup) {
+	previous, exists := labelsToCategories[radio.Selected]
+	if !exists {
+		previous = StatusNames[verniy.MediaListStatusCurrent]
+	}
+
+	categories := Categories()
+	labels := make([]string, len(categories))
+	newLabelsToCategories := make(map[string]string)
+	selected := ""
+	for i, category := range categories {
+		labels[i] = category.Label()
+		newLabelsToCategories[labels[i]] = category.Name
+		if category.Name == previous {
+			selected = labels[i]
+		}
+	}
+	if selected == "" && len(labels) > 0 {
+		selected = labels[0]
+	}
+	labelsToCategories = newLabelsToCategories
+
+	radio.Options = labels
+	if radio.Selected != selected {
+		radio.SetSelected(selected)
+		return
+	}
+	radio.Refresh()
+	if radio.OnChanged != nil {
+		radio.OnChanged(selected)
+	}
+}
+
+// MoveEntry moves the anime to another status and custom lists, right away
+// in the list shown and through the outbox on Anilist. An empty status or a
+// nil customLists keeps the current one.
+func MoveEntry(radio *widget.RadioGroup, mediaID int, status verniy.MediaListStatus, customLists map[string]bool) error {
+	if Updates == nil {
+		return errors.New("not logged in")
+	}
+	if status != "" {
+		Updates.QueueStatus(mediaID, status)
+	}
+	if customLists != nil {
+		Updates.QueueCustomLists(mediaID, customLists)
+	}
+	setUserData(moveInGroups(UserData, mediaID, status, customLists), radio)
+	return nil
+}
+
+// moveInGroups returns new groups with the entry moved, the given groups
+// are left untouched as the list shown still uses them
+func moveInGroups(groups []verniy.MediaListGroup, mediaID int, status verniy.MediaListStatus, customLists map[string]bool) []verniy.MediaListGroup {
+	var moved *verniy.MediaList
+	for i := range groups {
+		for j := range groups[i].Entries {
+			if entry := groups[i].Entries[j]; entry.Media != nil && entry.Media.ID == mediaID {
+				moved = &entry
+				break
+			}
+		}
+		if moved != nil {
+			break
+		}
+	}
+	if moved == nil {
+		// Not in the list yet, it shows up with the next refresh
+		return groups
+	}
+	if status != "" {
+		moved.Status = &status
+	}
+	if customLists != nil {
+		moved.CustomLists = customLists
+	}
+	now := int(time.Now().Unix())
+	moved.UpdatedAt = &now
+
+	result := make([]verniy.MediaListGroup, 0, len(groups)+1)
+	inStatusList := false
+	inCustomList := make(map[string]bool)
+	for _, group := range groups {
+		entries := make([]verniy.MediaList, 0, len(group.Entries)+1)
+		var belongs bool
+		if isCustomGroup(group) {
+			belongs = group.Name != nil && moved.CustomLists[*group.Name]
+			if belongs {
+				inCustomList[*group.Name] = true
+			}
+		} else {
+			belongs = !inStatusList && group.Status != nil && moved.Status != nil && *group.Status == *moved.Status
+			inStatusList = inStatusList || belongs
+		}
+		if belongs {
+			entries = append(entries, *moved)
+		}
+		for _, entry := range group.Entries {
+			if entry.Media == nil || entry.Media.ID != mediaID {
+				entries = append(entries, entry)
+			}
+		}
+		group.Entries = entries
+		result = append(result, group)
+	}
+
+	if !inStatusList && moved.Status != nil {
+		name := StatusNames[*moved.Status]
+		result = append(result, verniy.MediaListGroup{Name: &name, Status: moved.Status, Entries: []verniy.MediaList{*moved}})
+	}
+	var newLists []string
+	for name, in := range moved.CustomLists {
+		if in && !inCustomList[name] {
+			newLists = append(newLists, name)
+		}
+	}
+	sort.Strings(newLists)
+	custom := true
+	for _, name := range newLists {
+		name := name
+		result = append(result, verniy.MediaListGroup{Name: &name, IsCustomList: &custom, Entries: []verniy.MediaList{*moved}})
+	}
+	return result
+}
+
+// ListStatus returns the status of the anime in the list, a queued change
+// included, empty when it isn't in the list
+func ListStatus(mediaID int) verniy.MediaListStatus {
+	if Updates != nil {
+		for _, m := range Updates.Pending() {
+			if m.MediaID == mediaID && m.Status != "" {
+				return m.Status
+			}
+		}
+	}
+	if entry := FindEntry(mediaID); entry != nil && entry.Status != nil {
+		return *entry.Status
+	}
+	return ""
+}
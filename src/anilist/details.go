@@ -0,0 +1,119 @@
+package anilist
+
+import (
+	"AnimeGUI/verniy"
+	"context"
+	"sync"
+)
+
+// detailFields are the fields of the detail view, relations keep what is
+// needed to list them and open them in turn
+var detailFields = []verniy.MediaField{
+	verniy.MediaFieldID,
+	verniy.MediaFieldTitle(
+		verniy.MediaTitleFieldRomaji,
+		verniy.MediaTitleFieldEnglish,
+		verniy.MediaTitleFieldNative),
+	verniy.MediaFieldFormat,
+	verniy.MediaFieldStatusV2,
+	verniy.MediaFieldDescription,
+	verniy.MediaFieldStartDate,
+	verniy.MediaFieldSeason,
+	verniy.MediaFieldSeasonYear,
+	verniy.MediaFieldEpisodes,
+	verniy.MediaFieldDuration,
+	verniy.MediaFieldSourceV2,
+	verniy.MediaFieldTrailer(verniy.MediaTrailerFieldID, verniy.MediaTrailerFieldSite),
+	verniy.MediaFieldCoverImage(verniy.MediaCoverImageFieldLarge),
+	verniy.MediaFieldGenres,
+	verniy.MediaFieldAverageScore,
+	verniy.MediaFieldPopularity,
+	verniy.MediaFieldTags(
+		verniy.MediaTagFieldName,
+		verniy.MediaTagFieldRank,
+		verniy.MediaTagFieldIsGeneralSpoiler,
+		verniy.MediaTagFieldIsMediaSpoiler),
+	verniy.MediaFieldStudios(
+		verniy.MediaParamStudios{},
+		verniy.StudioConnectionFieldEdges(
+			verniy.StudioEdgeFieldIsMain,
+			verniy.StudioEdgeFieldNode(
+				verniy.StudioFieldID,
+				verniy.StudioFieldName,
+				verniy.StudioFieldIsAnimationStudio))),
+	verniy.MediaFieldRelations(
+		verniy.MediaConnectionFieldEdges(
+			verniy.MediaEdgeFieldRelationTypeV2,
+			verniy.MediaEdgeFieldNode(
+				verniy.MediaFieldID,
+				verniy.MediaFieldTitle(
+					verniy.MediaTitleFieldRomaji,
+					verniy.MediaTitleFieldEnglish),
+				verniy.MediaFieldType,
+				verniy.MediaFieldFormat,
+				verniy.MediaFieldStatusV2,
+				verniy.MediaFieldStartDate,
+				verniy.MediaFieldEpisodes))),
+	verniy.MediaFieldExternalLinks(verniy.MediaExternalLinkFieldURL, verniy.MediaExternalLinkFieldSite),
+}
+
+// detailsPerPage is the most anime Anilist returns in a page
+const detailsPerPage = 50
+
+var (
+	detailsMutex sync.Mutex
+	detailsCache = make(map[int]*verniy.Media)
+)
+
+// Details returns everything the detail view shows about the anime, each
+// anime is only fetched once
+func Details(ctx context.Context, mediaID int) (*verniy.Media, error) {
+	if media := CachedDetails(mediaID); media != nil {
+		return media, nil
+	}
+	media, err := Client.GetAnimeWithContext(ctx, mediaID, detailFields...)
+	if err != nil {
+		return nil, err
+	}
+	detailsMutex.Lock()
+	detailsCache[mediaID] = media
+	detailsMutex.Unlock()
+	return media, nil
+}
+
+// CachedDetails returns the details already fetched, nil when they weren't
+func CachedDetails(mediaID int) *verniy.Media {
+	detailsMutex.Lock()
+	defer detailsMutex.Unlock()
+	return detailsCache[mediaID]
+}
+
+// detailsOf returns the details of the anime by id, those not fetched yet are
+// asked together with one query per page
+func detailsOf(ctx context.Context, ids []int) (map[int]*verniy.Media, error) {
+	found := make(map[int]*verniy.Media, len(ids))
+	var missing []int
+	for _, id := range ids {
+		if media := CachedDetails(id); media != nil {
+			found[id] = media
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	for start := 0; start < len(missing); start += detailsPerPage {
+		end := min(start+detailsPerPage, len(missing))
+		page, err := Client.SearchAnimeWithContext(ctx, verniy.PageParamMedia{IDIn: missing[start:end]}, 1, detailsPerPage, detailFields...)
+		if err != nil {
+			return found, err
+		}
+		detailsMutex.Lock()
+		for i := range page.Media {
+			media := &page.Media[i]
+			detailsCache[media.ID] = media
+			found[media.ID] = media
+		}
+		detailsMutex.Unlock()
+	}
+	return found, nil
+}
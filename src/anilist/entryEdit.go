@@ -0,0 +1,119 @@
+package anilist
+
+import (
+	"AnimeGUI/verniy"
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2/widget"
+	"time"
+)
+
+// ScoreFormat is the score format of the user, the scores of the list are
+// read and saved in it
+var ScoreFormat verniy.ScoreFormat
+
+func fetchScoreFormat() error {
+	viewer, err := Client.GetViewer(verniy.UserFieldMediaListOptions(verniy.MediaListOptionsFieldScoreFormat))
+	if err != nil {
+		return fmt.Errorf("failed to get the score format: %w", err)
+	}
+	if viewer.MediaListOptions != nil && viewer.MediaListOptions.ScoreFormat != nil {
+		ScoreFormat = *viewer.MediaListOptions.ScoreFormat
+	}
+	return nil
+}
+
+// EntryEdit are the fields of the entry editor, nil ones are left as they are
+type EntryEdit struct {
+	Score       *float64          `json:"score,omitempty"`
+	Repeat      *int              `json:"repeat,omitempty"`
+	Private     *bool             `json:"private,omitempty"`
+	Notes       *string           `json:"notes,omitempty"`
+	StartedAt   *verniy.FuzzyDate `json:"started_at,omitempty"`
+	CompletedAt *verniy.FuzzyDate `json:"completed_at,omitempty"`
+}
+
+// merge sets the fields changed by edit
+func (e *EntryEdit) merge(edit EntryEdit) {
+	if edit.Score != nil {
+		e.Score = edit.Score
+	}
+	if edit.Repeat != nil {
+		e.Repeat = edit.Repeat
+	}
+	if edit.Private != nil {
+		e.Private = edit.Private
+	}
+	if edit.Notes != nil {
+		e.Notes = edit.Notes
+	}
+	if edit.StartedAt != nil {
+		e.StartedAt = edit.StartedAt
+	}
+	if edit.CompletedAt != nil {
+		e.CompletedAt = edit.CompletedAt
+	}
+}
+
+// applyTo shows the edit in a list entry
+func (e EntryEdit) applyTo(entry *verniy.MediaList) {
+	if e.Score != nil {
+		entry.Score = e.Score
+	}
+	if e.Repeat != nil {
+		entry.Repeat = e.Repeat
+	}
+	if e.Private != nil {
+		entry.Private = e.Private
+	}
+	if e.Notes != nil {
+		entry.Notes = e.Notes
+	}
+	if e.StartedAt != nil {
+		entry.StartedAt = e.StartedAt
+	}
+	if e.CompletedAt != nil {
+		entry.CompletedAt = e.CompletedAt
+	}
+}
+
+// EditEntry saves the editor changes, right away in the list shown and
+// through the outbox on Anilist. An empty status keeps the current one.
+func EditEntry(radio *widget.RadioGroup, mediaID int, status verniy.MediaListStatus, edit EntryEdit) error {
+	if Updates == nil {
+		return errors.New("not logged in")
+	}
+	Updates.QueueEdit(mediaID, status, edit)
+
+	groups := moveInGroups(UserData, mediaID, status, nil)
+	applyEdit(groups, mediaID, edit)
+	setUserData(groups, radio)
+	return nil
+}
+
+// applyEdit changes every entry of the anime, it is in more than one group
+// when it is in custom lists
+func applyEdit(groups []verniy.MediaListGroup, mediaID int, edit EntryEdit) {
+	for i := range groups {
+		for j := range groups[i].Entries {
+			if entry := &groups[i].Entries[j]; entry.Media != nil && entry.Media.ID == mediaID {
+				edit.applyTo(entry)
+			}
+		}
+	}
+}
+
+// SetLocalProgress shows the progress in the list right away, Anilist gets it
+// through the outbox
+func SetLocalProgress(mediaID, progress int) {
+	now := int(time.Now().Unix())
+	for i := range UserData {
+		for j := range UserData[i].Entries {
+			if entry := &UserData[i].Entries[j]; entry.Media != nil && entry.Media.ID == mediaID {
+				value := progress
+				entry.Progress = &value
+				entry.UpdatedAt = &now
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package anilist
+
+import (
+	"AnimeGUI/verniy"
+	"context"
+	"slices"
+	"sort"
+)
+
+// franchiseLimit bounds the anime fetched for a franchise, long running ones
+// are linked to dozens of specials
+const franchiseLimit = 40
+
+// franchiseRelations are the relations followed to gather a franchise,
+// adaptations and characters lead to other stories
+var franchiseRelations = []verniy.MediaRelation{
+	verniy.MediaRelationPrequel,
+	verniy.MediaRelationSequel,
+	verniy.MediaRelationSideStory,
+	verniy.MediaRelationSpinOff,
+}
+
+// FranchiseEntry is an anime of the franchise and where it is in the list
+type FranchiseEntry struct {
+	Media *verniy.Media
+	// Status is the status in the list, a queued change included, empty when
+	// the anime isn't in the list
+	Status verniy.MediaListStatus
+	// Relation is how the anime relates to the one the franchise was opened
+	// from, empty for that one and the ones further away
+	Relation verniy.MediaRelation
+}
+
+// Franchise walks the relations of the anime and returns every anime reached,
+// in release order. The anime found at each step of the walk are fetched with
+// a single query. found is called with the number of anime fetched so far.
+func Franchise(ctx context.Context, mediaID int, found func(int)) ([]FranchiseEntry, error) {
+	// Without the anime it was opened from there is nothing to show
+	first, err := Details(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	entries := []FranchiseEntry{{Media: first}}
+	if found != nil {
+		found(len(entries))
+	}
+
+	seen := map[int]bool{mediaID: true}
+	relations := make(map[int]verniy.MediaRelation)
+	step := []*verniy.Media{first}
+	for len(step) > 0 && len(entries) < franchiseLimit {
+		var ids []int
+		for _, media := range step {
+			if media.Relations == nil {
+				continue
+			}
+			for _, edge := range media.Relations.Edges {
+				node := edge.Node
+				if node == nil || edge.RelationType == nil || seen[node.ID] {
+					continue
+				}
+				if node.Type != nil && *node.Type != verniy.MediaTypeAnime {
+					continue
+				}
+				if !slices.Contains(franchiseRelations, *edge.RelationType) {
+					continue
+				}
+				seen[node.ID] = true
+				ids = append(ids, node.ID)
+				if media.ID == mediaID {
+					relations[node.ID] = *edge.RelationType
+				}
+			}
+		}
+		ids = ids[:min(len(ids), franchiseLimit-len(entries))]
+		if len(ids) == 0 {
+			break
+		}
+
+		fetched, err := detailsOf(ctx, ids)
+		step = nil
+		for _, id := range ids {
+			if media := fetched[id]; media != nil {
+				entries = append(entries, FranchiseEntry{Media: media, Relation: relations[id]})
+				step = append(step, media)
+			}
+		}
+		if found != nil {
+			found(len(entries))
+		}
+		if err != nil {
+			// Keep what was gathered so far
+			break
+		}
+	}
+
+	for i := range entries {
+		entries[i].Status = ListStatus(entries[i].Media.ID)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return releaseKey(entries[i].Media) < releaseKey(entries[j].Media)
+	})
+	return entries, nil
+}
+
+// NextInFranchise suggests the first anime in release order the user hasn't
+// watched, started or dropped, nil when there is none. Music videos and
+// anime not released yet are skipped.
+func NextInFranchise(entries []FranchiseEntry) *FranchiseEntry {
+	for i, entry := range entries {
+		media := entry.Media
+		if media.Format != nil && *media.Format == verniy.MediaFormatMusic {
+			continue
+		}
+		if media.Status != nil && (*media.Status == verniy.MediaStatusNotYetReleased || *media.Status == verniy.MediaStatusCancelled) {
+			continue
+		}
+		if entry.Status == "" || entry.Status == verniy.MediaListStatusPlanning {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+// releaseKey orders by start date, anime without one go last
+func releaseKey(media *verniy.Media) int {
+	date := media.StartDate
+	if date == nil || date.Year == nil {
+		return 99999999
+	}
+	key := *date.Year * 10000
+	if date.Month != nil {
+		key += *date.Month * 100
+	} else {
+		key += 1300
+	}
+	if date.Day != nil {
+		key += *date.Day
+	} else {
+		key += 32
+	}
+	return key
+}
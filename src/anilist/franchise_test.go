@@ -0,0 +1,241 @@
+package anilist
+
+import (
+	"AnimeGUI/verniy"
+	"AnimeGUI/verniy/limiter"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// franchiseMedia is an anime of the test franchise, related lists its
+// relations by id
+func franchiseMedia(id int, year, month int, related map[int]verniy.MediaRelation) verniy.Media {
+	media := verniy.Media{ID: id, Title: &verniy.MediaTitle{English: ptr(strconv.Itoa(id))}}
+	if year > 0 {
+		media.StartDate = &verniy.FuzzyDate{Year: ptr(year), Month: ptr(month)}
+	}
+	media.Relations = &verniy.MediaConnection{}
+	for relatedID, relation := range related {
+		mediaType := verniy.MediaTypeAnime
+		if relatedID >= 100 {
+			mediaType = verniy.MediaTypeManga
+		}
+		media.Relations.Edges = append(media.Relations.Edges, verniy.MediaEdge{
+			RelationType: ptr(relation),
+			Node:         &verniy.Media{ID: relatedID, Type: &mediaType},
+		})
+	}
+	return media
+}
+
+func TestFranchiseFetchesEachStepTogether(t *testing.T) {
+	franchise := map[int]verniy.Media{
+		1: franchiseMedia(1, 2020, 4, map[int]verniy.MediaRelation{
+			2:   verniy.MediaRelationSequel,
+			3:   verniy.MediaRelationPrequel,
+			9:   verniy.MediaRelationCharacter,
+			100: verniy.MediaRelationSideStory,
+		}),
+		2: franchiseMedia(2, 2021, 10, map[int]verniy.MediaRelation{1: verniy.MediaRelationPrequel, 4: verniy.MediaRelationSequel}),
+		3: franchiseMedia(3, 2018, 1, map[int]verniy.MediaRelation{1: verniy.MediaRelationSequel, 5: verniy.MediaRelationSpinOff}),
+		4: franchiseMedia(4, 0, 0, map[int]verniy.MediaRelation{2: verniy.MediaRelationPrequel}),
+		5: franchiseMedia(5, 2019, 7, nil),
+	}
+
+	batch := regexp.MustCompile(`id_in:\[([\d,]+)\]`)
+	var mutex sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query     string `json:"query"`
+			Variables struct {
+				ID int `json:"id"`
+			} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var response struct {
+			Data struct {
+				Media *verniy.Media `json:"Media,omitempty"`
+				Page  *verniy.Page  `json:"Page,omitempty"`
+			} `json:"data"`
+		}
+		if match := batch.FindStringSubmatch(request.Query); match != nil {
+			mutex.Lock()
+			requests = append(requests, match[1])
+			mutex.Unlock()
+			response.Data.Page = &verniy.Page{}
+			for _, id := range strings.Split(match[1], ",") {
+				n, _ := strconv.Atoi(id)
+				if media, ok := franchise[n]; ok {
+					response.Data.Page.Media = append(response.Data.Page.Media, media)
+				}
+			}
+		} else if request.Variables.ID != 0 {
+			mutex.Lock()
+			requests = append(requests, strconv.Itoa(request.Variables.ID))
+			mutex.Unlock()
+			media := franchise[request.Variables.ID]
+			response.Data.Media = &media
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	previous := Client
+	Client = verniy.New()
+	Client.Host = server.URL
+	Client.Limiter = limiter.New(1000, time.Second)
+	defer func() { Client = previous }()
+
+	detailsMutex.Lock()
+	previousCache := detailsCache
+	detailsCache = make(map[int]*verniy.Media)
+	detailsMutex.Unlock()
+	defer func() {
+		detailsMutex.Lock()
+		detailsCache = previousCache
+		detailsMutex.Unlock()
+	}()
+	useList(t, "Watching", nil)
+
+	var progress []int
+	entries, err := Franchise(context.Background(), 1, func(n int) { progress = append(progress, n) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The related ids can come in any order within a step
+	for i, request := range requests {
+		ids := strings.Split(request, ",")
+		slices.Sort(ids)
+		requests[i] = strings.Join(ids, ",")
+	}
+	if want := []string{"1", "2,3", "4,5"}; !slices.Equal(requests, want) {
+		t.Errorf("got requests %v, want %v", requests, want)
+	}
+	if want := []int{1, 3, 5}; !slices.Equal(progress, want) {
+		t.Errorf("got progress %v, want %v", progress, want)
+	}
+
+	var order []int
+	for _, entry := range entries {
+		order = append(order, entry.Media.ID)
+	}
+	if want := []int{3, 5, 1, 2, 4}; !slices.Equal(order, want) {
+		t.Errorf("got %v, want %v in release order", order, want)
+	}
+	want := map[int]verniy.MediaRelation{2: verniy.MediaRelationSequel, 3: verniy.MediaRelationPrequel}
+	for _, entry := range entries {
+		if entry.Relation != want[entry.Media.ID] {
+			t.Errorf("%d: relation %q, want %q", entry.Media.ID, entry.Relation, want[entry.Media.ID])
+		}
+	}
+}
+
+func TestNextInFranchise(t *testing.T) {
+	entry := func(id int, status verniy.MediaListStatus, format verniy.MediaFormat, mediaStatus verniy.MediaStatus) FranchiseEntry {
+		return FranchiseEntry{
+			Media:  &verniy.Media{ID: id, Format: &format, Status: &mediaStatus},
+			Status: status,
+		}
+	}
+	tv, music := verniy.MediaFormatTv, verniy.MediaFormatMusic
+	finished, upcoming := verniy.MediaStatusFinished, verniy.MediaStatusNotYetReleased
+
+	tests := []struct {
+		name    string
+		entries []FranchiseEntry
+		want    int
+	}{
+		{
+			name:    "first not in the list",
+			entries: []FranchiseEntry{entry(1, verniy.MediaListStatusCompleted, tv, finished), entry(2, "", tv, finished), entry(3, "", tv, finished)},
+			want:    2,
+		},
+		{
+			name:    "planning",
+			entries: []FranchiseEntry{entry(1, verniy.MediaListStatusCompleted, tv, finished), entry(2, verniy.MediaListStatusPlanning, tv, finished)},
+			want:    2,
+		},
+		{
+			name:    "dropped and watching skipped",
+			entries: []FranchiseEntry{entry(1, verniy.MediaListStatusDropped, tv, finished), entry(2, verniy.MediaListStatusCurrent, tv, finished), entry(3, "", tv, finished)},
+			want:    3,
+		},
+		{
+			name:    "music video skipped",
+			entries: []FranchiseEntry{entry(1, "", music, finished), entry(2, "", tv, finished)},
+			want:    2,
+		},
+		{
+			name:    "not released skipped",
+			entries: []FranchiseEntry{entry(1, verniy.MediaListStatusCompleted, tv, finished), entry(2, "", tv, upcoming)},
+			want:    0,
+		},
+		{
+			name:    "missing format and status",
+			entries: []FranchiseEntry{{Media: &verniy.Media{ID: 1}}},
+			want:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NextInFranchise(tt.entries)
+			switch {
+			case tt.want == 0 && got != nil:
+				t.Errorf("got %d, want none", got.Media.ID)
+			case tt.want != 0 && (got == nil || got.Media.ID != tt.want):
+				t.Errorf("got %+v, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseKey(t *testing.T) {
+	date := func(year, month, day int) *verniy.Media {
+		d := &verniy.FuzzyDate{}
+		if year > 0 {
+			d.Year = ptr(year)
+		}
+		if month > 0 {
+			d.Month = ptr(month)
+		}
+		if day > 0 {
+			d.Day = ptr(day)
+		}
+		return &verniy.Media{StartDate: d}
+	}
+
+	// Each anime is released before the next one
+	ordered := []*verniy.Media{
+		date(2019, 12, 31),
+		date(2020, 4, 1),
+		date(2020, 4, 30),
+		date(2020, 4, 0),
+		date(2020, 5, 2),
+		date(2020, 0, 0),
+		date(2021, 1, 1),
+		date(0, 0, 0),
+	}
+	for i := 1; i < len(ordered); i++ {
+		if releaseKey(ordered[i-1]) >= releaseKey(ordered[i]) {
+			t.Errorf("%d: key %d not before %d", i, releaseKey(ordered[i-1]), releaseKey(ordered[i]))
+		}
+	}
+	if releaseKey(&verniy.Media{}) != releaseKey(date(0, 0, 0)) {
+		t.Error("anime without a start date not last")
+	}
+}
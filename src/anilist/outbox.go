@@ -0,0 +1,470 @@
+package anilist
+
+import (
+	"AnimeGUI/atomicfile"
+	"AnimeGUI/verniy"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/charmbracelet/log"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	// coalesceDelay lets quick +/- clicks end up in a single mutation
+	coalesceDelay   = 2 * time.Second
+	firstRetryDelay = 5 * time.Second
+	maxRetryDelay   = 10 * time.Minute
+	sendTimeout     = 30 * time.Second
+)
+
+// Updates is the outbox of the profile in use, nil until logged in
+var Updates *Outbox
+
+// Mutation is a change of a list entry waiting to be sent to Anilist, later
+// changes of the same anime are merged into it
+type Mutation struct {
+	MediaID  int                    `json:"media_id"`
+	Progress *int                   `json:"progress,omitempty"`
+	Status   verniy.MediaListStatus `json:"status,omitempty"`
+	// CustomLists has every custom list of the user, nil when unchanged
+	CustomLists map[string]bool `json:"custom_lists,omitempty"`
+	EntryEdit
+	// BaseProgress is the progress the first merged change started from, a
+	// different one on Anilist means it was changed elsewhere meanwhile
+	BaseProgress *int `json:"base_progress,omitempty"`
+	// Force skips the conflict check, set when a failed mutation is retried
+	// by hand
+	Force    bool      `json:"force,omitempty"`
+	Attempts int       `json:"attempts"`
+	NextTry  time.Time `json:"next_try"`
+	Failed   bool      `json:"failed,omitempty"`
+	Error    string    `json:"error,omitempty"`
+
+	// revision tells if the mutation changed while it was being sent
+	revision int
+}
+
+// ConflictError is returned when Anilist is further than the progress to
+// save, the change was made from somewhere else
+type ConflictError struct {
+	Remote int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("Anilist is already at episode %d", e.Remote)
+}
+
+// IsRetryable is true when the request can succeed later as it is: Anilist
+// wasn't reached, was rate limiting or failed on its side
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		return false
+	}
+	var e *verniy.Error
+	if !errors.As(err, &e) {
+		return true
+	}
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// Outbox keeps the list changes on disk until Anilist accepted them, retrying
+// with backoff. Requests go through the limiter of Client.
+type Outbox struct {
+	// OnChange is called after each change of the queue, from any goroutine
+	OnChange func()
+
+	path   string
+	client *verniy.Client
+	mutex  sync.Mutex
+	queue  []*Mutation
+	wake   chan struct{}
+	stop   context.CancelFunc
+}
+
+// NewOutbox loads the mutations left in path, they are sent with token. The
+// returned outbox is usable even with an error.
+func NewOutbox(path, token string) (*Outbox, error) {
+	client := *Client
+	client.AccessToken = token
+	o := &Outbox{path: path, client: &client, wake: make(chan struct{}, 1)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return o, nil
+	}
+	if err != nil {
+		return o, fmt.Errorf("failed to read outbox: %w", err)
+	}
+	if err := json.Unmarshal(data, &o.queue); err != nil {
+		return o, fmt.Errorf("failed to parse outbox: %w", err)
+	}
+	return o, nil
+}
+
+// Start sends the queued mutations in the background until Stop
+func (o *Outbox) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	o.mutex.Lock()
+	o.stop = cancel
+	o.mutex.Unlock()
+	go o.run(ctx)
+}
+
+// Stop interrupts the sending, the mutations stay on disk
+func (o *Outbox) Stop() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.stop != nil {
+		o.stop()
+	}
+}
+
+// QueueProgress saves progress for the anime, from is the progress shown
+// before the change
+func (o *Outbox) QueueProgress(mediaID, from, progress int) {
+	o.mutex.Lock()
+	m := o.mutationLocked(mediaID, from)
+	m.Progress = &progress
+	if m.BaseProgress != nil && *m.BaseProgress == progress && m.onlyProgress() {
+		// Back where it started, nothing to send
+		o.removeLocked(m)
+	}
+	o.saveLocked()
+	o.mutex.Unlock()
+	o.changed()
+}
+
+// QueueStatus moves the anime to another list, adding it when needed
+func (o *Outbox) QueueStatus(mediaID int, status verniy.MediaListStatus) {
+	o.mutex.Lock()
+	m := o.mutationLocked(mediaID, -1)
+	m.Status = status
+	o.saveLocked()
+	o.mutex.Unlock()
+	o.changed()
+}
+
+// QueueCustomLists sets the custom lists the anime is in
+func (o *Outbox) QueueCustomLists(mediaID int, customLists map[string]bool) {
+	o.mutex.Lock()
+	m := o.mutationLocked(mediaID, -1)
+	m.CustomLists = customLists
+	o.saveLocked()
+	o.mutex.Unlock()
+	o.changed()
+}
+
+// QueueEdit saves the changes of the entry editor, an empty status keeps
+// the current one
+func (o *Outbox) QueueEdit(mediaID int, status verniy.MediaListStatus, edit EntryEdit) {
+	o.mutex.Lock()
+	m := o.mutationLocked(mediaID, -1)
+	if status != "" {
+		m.Status = status
+	}
+	m.EntryEdit.merge(edit)
+	o.saveLocked()
+	o.mutex.Unlock()
+	o.changed()
+}
+
+// mutationLocked returns the mutation of the anime ready for a new change,
+// from is its current progress or -1 when unknown
+func (o *Outbox) mutationLocked(mediaID, from int) *Mutation {
+	m := o.findLocked(mediaID)
+	if m == nil {
+		m = &Mutation{MediaID: mediaID}
+		o.queue = append(o.queue, m)
+	}
+	if m.Failed {
+		// What it was based on is outdated, start over from what is shown
+		m.Failed, m.Error, m.Attempts, m.BaseProgress = false, "", 0, nil
+	}
+	if m.BaseProgress == nil && m.Progress == nil && from >= 0 {
+		m.BaseProgress = &from
+	}
+	m.Force = false
+	if m.Attempts == 0 {
+		m.NextTry = time.Now().Add(coalesceDelay)
+	}
+	m.revision++
+	return m
+}
+
+// onlyProgress is true when nothing but the progress changes
+func (m *Mutation) onlyProgress() bool {
+	return m.Status == "" && m.CustomLists == nil && m.EntryEdit == EntryEdit{}
+}
+
+// Pending returns a copy of the queued mutations, oldest first
+func (o *Outbox) Pending() []Mutation {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	pending := make([]Mutation, len(o.queue))
+	for i, m := range o.queue {
+		pending[i] = *m
+	}
+	return pending
+}
+
+// Retry sends a failed mutation again without checking for conflicts
+func (o *Outbox) Retry(mediaID int) {
+	o.mutex.Lock()
+	if m := o.findLocked(mediaID); m != nil {
+		m.Failed, m.Force, m.Attempts, m.NextTry = false, true, 0, time.Now()
+		m.revision++
+		o.saveLocked()
+	}
+	o.mutex.Unlock()
+	o.changed()
+}
+
+// SendNow sends the mutations waiting out a backoff right away, called once
+// Anilist answers again
+func (o *Outbox) SendNow() {
+	o.mutex.Lock()
+	now := time.Now()
+	for _, m := range o.queue {
+		if !m.Failed && m.Attempts > 0 && m.NextTry.After(now) {
+			m.NextTry = now
+		}
+	}
+	o.mutex.Unlock()
+	o.changed()
+}
+
+// Discard forgets the mutation, Anilist keeps what it has
+func (o *Outbox) Discard(mediaID int) {
+	o.mutex.Lock()
+	if m := o.findLocked(mediaID); m != nil {
+		o.removeLocked(m)
+		o.saveLocked()
+	}
+	o.mutex.Unlock()
+	o.changed()
+}
+
+// applyTo shows the queued changes in a list fetched from Anilist
+func (o *Outbox) applyTo(groups []verniy.MediaListGroup) []verniy.MediaListGroup {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	for _, m := range o.queue {
+		if m.Failed {
+			continue
+		}
+		if m.Status != "" || m.CustomLists != nil {
+			groups = moveInGroups(groups, m.MediaID, m.Status, m.CustomLists)
+		}
+		applyEdit(groups, m.MediaID, m.EntryEdit)
+		if m.Progress == nil {
+			continue
+		}
+		for i := range groups {
+			for j := range groups[i].Entries {
+				entry := &groups[i].Entries[j]
+				if entry.Media != nil && entry.Media.ID == m.MediaID {
+					progress := *m.Progress
+					entry.Progress = &progress
+				}
+			}
+		}
+	}
+	return groups
+}
+
+func (o *Outbox) run(ctx context.Context) {
+	for {
+		o.mutex.Lock()
+		var next *Mutation
+		for _, m := range o.queue {
+			if !m.Failed && (next == nil || m.NextTry.Before(next.NextTry)) {
+				next = m
+			}
+		}
+		var timer *time.Timer
+		var due <-chan time.Time
+		if next != nil {
+			timer = time.NewTimer(time.Until(next.NextTry))
+			due = timer.C
+		}
+		o.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-o.wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-due:
+			o.send(ctx, next)
+		}
+	}
+}
+
+// send tries the mutation once and reschedules it when it failed
+func (o *Outbox) send(ctx context.Context, m *Mutation) {
+	o.mutex.Lock()
+	if o.findLocked(m.MediaID) != m || time.Now().Before(m.NextTry) {
+		// Discarded or changed since it was picked
+		o.mutex.Unlock()
+		return
+	}
+	sent := *m
+	o.mutex.Unlock()
+
+	err := o.apply(ctx, sent)
+	if ctx.Err() != nil {
+		return
+	}
+
+	o.mutex.Lock()
+	if o.findLocked(m.MediaID) != m {
+		o.mutex.Unlock()
+		return
+	}
+	if err == nil {
+		// Anilist answered, the list itself is fetched again by the reconnect
+		_ = Offline.Set(false)
+	}
+	switch {
+	case err == nil && m.revision == sent.revision:
+		o.removeLocked(m)
+	case err == nil:
+		// Changed while it was sent, what was sent is the new base
+		m.BaseProgress, m.Attempts, m.Error = sent.Progress, 0, ""
+		if m.Status == sent.Status {
+			m.Status = ""
+		}
+		if reflect.DeepEqual(m.CustomLists, sent.CustomLists) {
+			m.CustomLists = nil
+		}
+		if m.EntryEdit == sent.EntryEdit {
+			m.EntryEdit = EntryEdit{}
+		}
+	case IsRetryable(err):
+		log.Error("Anilist update failed, retrying later", "media", m.MediaID, "err", err)
+		m.Attempts++
+		m.NextTry = time.Now().Add(retryDelay(m.Attempts))
+		m.Error = err.Error()
+		_ = Offline.Set(true)
+	default:
+		log.Error("Anilist update failed", "media", m.MediaID, "err", err)
+		m.Failed = true
+		m.Error = err.Error()
+	}
+	o.saveLocked()
+	o.mutex.Unlock()
+	o.changed()
+}
+
+func (o *Outbox) apply(ctx context.Context, m Mutation) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	if m.Progress != nil && !m.Force {
+		media, err := o.client.GetAnimeWithContext(ctx, m.MediaID,
+			verniy.MediaFieldID,
+			verniy.MediaFieldMediaListEntry(verniy.MediaListFieldID, verniy.MediaListFieldProgress))
+		if err != nil {
+			return err
+		}
+		if entry := media.MediaListEntry; entry != nil && entry.Progress != nil {
+			remote := *entry.Progress
+			// Never move Anilist back over a change made somewhere else
+			if remote > *m.Progress && (m.BaseProgress == nil || remote != *m.BaseProgress) {
+				return &ConflictError{Remote: remote}
+			}
+			if remote == *m.Progress && m.onlyProgress() {
+				return nil
+			}
+		}
+	}
+
+	input := verniy.MediaListInput{
+		MediaID:     m.MediaID,
+		Progress:    m.Progress,
+		Status:      m.Status,
+		Score:       m.Score,
+		Repeat:      m.Repeat,
+		Private:     m.Private,
+		Notes:       m.Notes,
+		StartedAt:   m.StartedAt,
+		CompletedAt: m.CompletedAt,
+	}
+	if m.CustomLists != nil {
+		input.CustomLists = []string{}
+		for name, in := range m.CustomLists {
+			if in {
+				input.CustomLists = append(input.CustomLists, name)
+			}
+		}
+	}
+	_, err := o.client.SaveMediaListEntryWithContext(ctx, input)
+	return err
+}
+
+func retryDelay(attempts int) time.Duration {
+	delay := firstRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+func (o *Outbox) findLocked(mediaID int) *Mutation {
+	for _, m := range o.queue {
+		if m.MediaID == mediaID {
+			return m
+		}
+	}
+	return nil
+}
+
+func (o *Outbox) removeLocked(m *Mutation) {
+	for i := range o.queue {
+		if o.queue[i] == m {
+			o.queue = append(o.queue[:i], o.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+func (o *Outbox) saveLocked() {
+	if len(o.queue) == 0 {
+		if err := os.Remove(o.path); err != nil && !os.IsNotExist(err) {
+			log.Error(err)
+		}
+		return
+	}
+	data, err := json.Marshal(o.queue)
+	if err == nil {
+		err = atomicfile.Write(o.path, data)
+	}
+	if err != nil {
+		log.Error("Failed to save the outbox", "err", err)
+	}
+}
+
+func (o *Outbox) changed() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	if o.OnChange != nil {
+		o.OnChange()
+	}
+}
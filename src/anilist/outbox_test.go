@@ -0,0 +1,292 @@
+package anilist
+
+import (
+	"AnimeGUI/verniy"
+	"AnimeGUI/verniy/limiter"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAnilist answers the progress check and the save of the outbox
+type fakeAnilist struct {
+	mutex sync.Mutex
+	// remote is the progress on Anilist, -1 when the anime isn't on the list
+	remote int
+	// status is returned instead of an answer when not 0
+	status int
+	saves  []map[string]interface{}
+}
+
+func newFakeAnilist(t *testing.T, remote int) *fakeAnilist {
+	t.Helper()
+	fake := &fakeAnilist{remote: remote}
+	server := httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(server.Close)
+
+	previous := Client
+	Client = verniy.New()
+	Client.Host = server.URL
+	Client.Limiter = limiter.New(1000, time.Second)
+	t.Cleanup(func() { Client = previous })
+	return fake
+}
+
+func (f *fakeAnilist) serve(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		fmt.Fprintf(w, `{"errors":[{"message":"%s","status":%d}]}`, http.StatusText(f.status), f.status)
+		return
+	}
+	if strings.Contains(request.Query, "SaveMediaListEntry") {
+		f.saves = append(f.saves, request.Variables)
+		if progress, ok := request.Variables["progress"].(float64); ok {
+			f.remote = int(progress)
+		}
+		fmt.Fprint(w, `{"data":{"SaveMediaListEntry":{"id":1}}}`)
+		return
+	}
+	if f.remote < 0 {
+		fmt.Fprint(w, `{"data":{"Media":{"id":1,"mediaListEntry":null}}}`)
+		return
+	}
+	fmt.Fprintf(w, `{"data":{"Media":{"id":1,"mediaListEntry":{"id":1,"progress":%d}}}}`, f.remote)
+}
+
+func (f *fakeAnilist) saved() []map[string]interface{} {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.saves
+}
+
+func newTestOutbox(t *testing.T) *Outbox {
+	t.Helper()
+	o, err := NewOutbox(filepath.Join(t.TempDir(), "anilist_outbox.json"), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = Offline.Set(false)
+	return o
+}
+
+// sendNow sends the mutation of the anime without waiting for its delay
+func sendNow(t *testing.T, o *Outbox, mediaID int) {
+	t.Helper()
+	o.mutex.Lock()
+	m := o.findLocked(mediaID)
+	if m != nil {
+		m.NextTry = time.Now()
+	}
+	o.mutex.Unlock()
+	if m == nil {
+		t.Fatalf("no mutation for %d", mediaID)
+	}
+	o.send(context.Background(), m)
+}
+
+func TestOutboxCoalescesChanges(t *testing.T) {
+	o := newTestOutbox(t)
+	o.QueueProgress(1, 3, 4)
+	o.QueueProgress(1, 4, 5)
+	o.QueueStatus(1, verniy.MediaListStatusCurrent)
+	o.QueueProgress(2, 7, 8)
+
+	pending := o.Pending()
+	if len(pending) != 2 {
+		t.Fatalf("got %d mutations, want 2", len(pending))
+	}
+	m := pending[0]
+	if *m.Progress != 5 || *m.BaseProgress != 3 || m.Status != verniy.MediaListStatusCurrent {
+		t.Errorf("merged into progress %d from %d, status %q", *m.Progress, *m.BaseProgress, m.Status)
+	}
+	if m.NextTry.Before(time.Now()) {
+		t.Error("sent without waiting for more clicks")
+	}
+
+	// Back where it started, nothing to send
+	o.QueueProgress(2, 8, 7)
+	if pending := o.Pending(); len(pending) != 1 || pending[0].MediaID != 1 {
+		t.Errorf("undone change still queued: %+v", pending)
+	}
+
+	reloaded, err := NewOutbox(o.path, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending := reloaded.Pending(); len(pending) != 1 || *pending[0].Progress != 5 {
+		t.Errorf("reloaded %+v", pending)
+	}
+}
+
+func TestOutboxSends(t *testing.T) {
+	fake := newFakeAnilist(t, 3)
+	o := newTestOutbox(t)
+	o.QueueProgress(1, 3, 4)
+	sendNow(t, o, 1)
+
+	if len(o.Pending()) != 0 {
+		t.Errorf("sent mutation still queued: %+v", o.Pending())
+	}
+	saves := fake.saved()
+	if len(saves) != 1 || saves[0]["progress"] != float64(4) || saves[0]["mediaId"] != float64(1) {
+		t.Errorf("saved %v", saves)
+	}
+}
+
+func TestOutboxConflict(t *testing.T) {
+	// Watched further from another device meanwhile
+	fake := newFakeAnilist(t, 10)
+	o := newTestOutbox(t)
+	o.QueueProgress(1, 3, 4)
+	sendNow(t, o, 1)
+
+	pending := o.Pending()
+	if len(pending) != 1 || !pending[0].Failed || !strings.Contains(pending[0].Error, "episode 10") {
+		t.Fatalf("got %+v, want a failed conflict", pending)
+	}
+	if len(fake.saved()) != 0 {
+		t.Fatal("Anilist moved backwards")
+	}
+
+	// Retrying by hand skips the check
+	o.Retry(1)
+	sendNow(t, o, 1)
+	if len(o.Pending()) != 0 || len(fake.saved()) != 1 {
+		t.Errorf("forced retry not sent: %+v", o.Pending())
+	}
+}
+
+func TestOutboxNoConflictFromBase(t *testing.T) {
+	tests := []struct {
+		name      string
+		remote    int
+		from, to  int
+		wantSaves int
+	}{
+		{name: "remote at the base", remote: 10, from: 10, to: 3, wantSaves: 1},
+		{name: "remote behind", remote: 2, from: 3, to: 4, wantSaves: 1},
+		{name: "already there", remote: 4, from: 3, to: 4, wantSaves: 0},
+		{name: "not on the list", remote: -1, from: 0, to: 1, wantSaves: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeAnilist(t, tt.remote)
+			o := newTestOutbox(t)
+			o.QueueProgress(1, tt.from, tt.to)
+			sendNow(t, o, 1)
+			if len(o.Pending()) != 0 {
+				t.Errorf("still queued: %+v", o.Pending())
+			}
+			if got := len(fake.saved()); got != tt.wantSaves {
+				t.Errorf("got %d saves, want %d", got, tt.wantSaves)
+			}
+		})
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	fake := newFakeAnilist(t, 3)
+	fake.status = http.StatusServiceUnavailable
+	o := newTestOutbox(t)
+	o.QueueProgress(1, 3, 4)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		before := time.Now()
+		sendNow(t, o, 1)
+		m := o.Pending()[0]
+		if m.Failed || m.Attempts != attempt {
+			t.Fatalf("attempt %d: %+v", attempt, m)
+		}
+		if wait := m.NextTry.Sub(before); wait < retryDelay(attempt) || wait > retryDelay(attempt)+time.Second {
+			t.Errorf("attempt %d waits %v, want %v", attempt, wait, retryDelay(attempt))
+		}
+	}
+	if offline, _ := Offline.Get(); !offline {
+		t.Error("not offline after a failed send")
+	}
+
+	// Anilist is back, the backoff isn't waited out
+	fake.mutex.Lock()
+	fake.status = 0
+	fake.mutex.Unlock()
+	o.SendNow()
+	if m := o.Pending()[0]; m.NextTry.After(time.Now()) {
+		t.Fatalf("still waiting until %v", m.NextTry)
+	}
+	o.send(context.Background(), o.queue[0])
+	if len(o.Pending()) != 0 {
+		t.Errorf("not sent: %+v", o.Pending())
+	}
+	if offline, _ := Offline.Get(); offline {
+		t.Error("still offline after a successful send")
+	}
+}
+
+func TestOutboxNotRetryable(t *testing.T) {
+	fake := newFakeAnilist(t, 3)
+	fake.status = http.StatusBadRequest
+	o := newTestOutbox(t)
+	o.QueueStatus(1, verniy.MediaListStatusDropped)
+	sendNow(t, o, 1)
+	if m := o.Pending()[0]; !m.Failed || m.Attempts != 0 {
+		t.Errorf("got %+v, want failed", m)
+	}
+
+	// A new change starts over from what is shown
+	o.QueueProgress(1, 3, 4)
+	if m := o.Pending()[0]; m.Failed || *m.BaseProgress != 3 {
+		t.Errorf("got %+v", m)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	want := []time.Duration{firstRetryDelay, 2 * firstRetryDelay, 4 * firstRetryDelay}
+	for i, delay := range want {
+		if got := retryDelay(i + 1); got != delay {
+			t.Errorf("attempt %d: got %v, want %v", i+1, got, delay)
+		}
+	}
+	if got := retryDelay(100); got != maxRetryDelay {
+		t.Errorf("got %v, want the maximum", got)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{context.Canceled, false},
+		{errors.New("connection refused"), true},
+		{&verniy.Error{StatusCode: http.StatusTooManyRequests}, true},
+		{&verniy.Error{StatusCode: http.StatusBadGateway}, true},
+		{&verniy.Error{StatusCode: http.StatusBadRequest}, false},
+		{&verniy.Error{StatusCode: http.StatusUnauthorized}, false},
+		{&ConflictError{Remote: 3}, false},
+		{fmt.Errorf("wrapped: %w", &ConflictError{Remote: 3}), false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
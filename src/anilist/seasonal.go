@@ -0,0 +1,142 @@
+package anilist
+
+import (
+	"AnimeGUI/verniy"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	seasonalPerPage = 50
+	// seasonalPages bounds a season to the most popular shows, the rest are
+	// mostly shorts and ads
+	seasonalPages = 4
+)
+
+var seasonOrder = []verniy.MediaSeason{
+	verniy.MediaSeasonWinter,
+	verniy.MediaSeasonSpring,
+	verniy.MediaSeasonSummer,
+	verniy.MediaSeasonFall,
+}
+
+// Season is an anime season, December belongs to the winter of the next year
+// like on Anilist
+type Season struct {
+	Season verniy.MediaSeason
+	Year   int
+}
+
+// SeasonOf returns the season airing at the date
+func SeasonOf(date time.Time) Season {
+	month := int(date.Month())
+	if month == 12 {
+		return Season{Season: verniy.MediaSeasonWinter, Year: date.Year() + 1}
+	}
+	return Season{Season: seasonOrder[month/3], Year: date.Year()}
+}
+
+// Next returns the season after this one
+func (s Season) Next() Season {
+	return s.shift(1)
+}
+
+// Previous returns the season before this one
+func (s Season) Previous() Season {
+	return s.shift(-1)
+}
+
+func (s Season) shift(by int) Season {
+	index := s.Year*len(seasonOrder) + max(slices.Index(seasonOrder, s.Season), 0) + by
+	return Season{Season: seasonOrder[index%len(seasonOrder)], Year: index / len(seasonOrder)}
+}
+
+func (s Season) String() string {
+	name := string(s.Season)
+	if name == "" {
+		return fmt.Sprint(s.Year)
+	}
+	return name[:1] + strings.ToLower(name[1:]) + fmt.Sprintf(" %d", s.Year)
+}
+
+// SeasonalQuery filters and sorts a season, empty fields keep everything
+type SeasonalQuery struct {
+	Season  Season
+	Formats []verniy.MediaFormat
+	Genre   string
+	Sort    verniy.MediaSort
+}
+
+// seasonalFields are what the cover grid and the add button need
+var seasonalFields = []verniy.MediaField{
+	verniy.MediaFieldID,
+	verniy.MediaFieldTitle(
+		verniy.MediaTitleFieldRomaji,
+		verniy.MediaTitleFieldEnglish,
+		verniy.MediaTitleFieldNative),
+	verniy.MediaFieldSynonyms,
+	verniy.MediaFieldCoverImage(verniy.MediaCoverImageFieldLarge),
+	verniy.MediaFieldFormat,
+	verniy.MediaFieldStatusV2,
+	verniy.MediaFieldEpisodes,
+	verniy.MediaFieldGenres,
+	verniy.MediaFieldAverageScore,
+	verniy.MediaFieldPopularity,
+}
+
+// SeasonalAnime returns the anime of the season matching the query, adult
+// ones are left out
+func SeasonalAnime(ctx context.Context, query SeasonalQuery) ([]verniy.Media, error) {
+	isAdult := false
+	param := verniy.PageParamMedia{
+		Season:     query.Season.Season,
+		SeasonYear: query.Season.Year,
+		FormatIn:   query.Formats,
+		Genre:      query.Genre,
+		IsAdult:    &isAdult,
+	}
+	if query.Sort != "" {
+		param.Sort = []verniy.MediaSort{query.Sort}
+	}
+
+	var media []verniy.Media
+	for page := 1; page <= seasonalPages; page++ {
+		result, err := Client.SearchAnimeWithContext(ctx, param, page, seasonalPerPage, seasonalFields...)
+		if err != nil {
+			if len(media) > 0 {
+				// The first pages are the ones that matter
+				return media, nil
+			}
+			return nil, err
+		}
+		media = append(media, result.Media...)
+		if result.PageInfo.HasNextPage == nil || !*result.PageInfo.HasNextPage {
+			break
+		}
+	}
+	return media, nil
+}
+
+var (
+	genresMutex sync.Mutex
+	genres      []string
+)
+
+// Genres returns every genre of Anilist, fetched once
+func Genres(ctx context.Context) ([]string, error) {
+	genresMutex.Lock()
+	defer genresMutex.Unlock()
+	if genres != nil {
+		return genres, nil
+	}
+	result, err := Client.GetGenresWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	genres = result
+	return genres, nil
+}
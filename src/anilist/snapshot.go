@@ -0,0 +1,72 @@
+package anilist
+
+import (
+	"AnimeGUI/atomicfile"
+	"AnimeGUI/verniy"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const snapshotVersion = 1
+
+// SnapshotFile is where the last list fetched from Anilist is kept, each
+// profile sets its own. Empty disables the snapshot.
+var SnapshotFile string
+
+// Owner is the account a list belongs to
+type Owner struct {
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// Snapshot is the list as it was the last time Anilist was reachable
+type Snapshot struct {
+	Version int                     `json:"version"`
+	SavedAt time.Time               `json:"saved_at"`
+	Owner   Owner                   `json:"owner"`
+	Groups  []verniy.MediaListGroup `json:"groups"`
+	// ScoreFormat is the one of the owner, scores of Groups are in it
+	ScoreFormat verniy.ScoreFormat `json:"score_format,omitempty"`
+}
+
+// LoadSnapshot reads the snapshot of the current profile
+func LoadSnapshot() (*Snapshot, error) {
+	if SnapshotFile == "" {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(SnapshotFile)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse list snapshot: %w", err)
+	}
+	if snapshot.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported list snapshot version %d", snapshot.Version)
+	}
+	return &snapshot, nil
+}
+
+// saveSnapshot replaces the snapshot of the current profile
+func saveSnapshot(owner Owner, groups []verniy.MediaListGroup) error {
+	if SnapshotFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(Snapshot{
+		Version: snapshotVersion,
+		SavedAt: time.Now(),
+		Owner:   owner,
+		Groups:  groups,
+
+		ScoreFormat: ScoreFormat,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode list snapshot: %w", err)
+	}
+
+	return atomicfile.Write(SnapshotFile, data)
+}
@@ -1 +1,298 @@
 package anilist
+
+import (
+	"AnimeGUI/atomicfile"
+	"AnimeGUI/fuzzy"
+	"AnimeGUI/verniy"
+	"encoding/json"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SortKey is an order of the anime list
+type SortKey string
+
+const (
+	SortUpdated      SortKey = "Last updated"
+	SortTitle        SortKey = "Title"
+	SortScore        SortKey = "Score"
+	SortRemaining    SortKey = "Episodes left"
+	SortNextAiring   SortKey = "Next airing"
+	SortAverageScore SortKey = "Average score"
+)
+
+// SortKeys are the orders shown in the sort selector
+var SortKeys = []SortKey{SortUpdated, SortTitle, SortScore, SortRemaining, SortNextAiring, SortAverageScore}
+
+// SortOrder is the order of a category
+type SortOrder struct {
+	Key SortKey `json:"key"`
+	// Reversed inverts the natural order of the key: newest, A to Z, best
+	// score, fewest episodes left, soonest airing
+	Reversed bool `json:"reversed"`
+}
+
+// SortFile is where the sort order of each category is kept, each profile
+// sets its own. Empty keeps them in memory only.
+var SortFile string
+
+var (
+	sortMutex   sync.Mutex
+	sortOrders  map[string]SortOrder
+	sortsLoaded string
+)
+
+// SortOf returns the sort order remembered for the category
+func SortOf(category string) SortOrder {
+	sortMutex.Lock()
+	defer sortMutex.Unlock()
+	loadSortOrdersLocked()
+	if order, exists := sortOrders[categoryName(category)]; exists {
+		return order
+	}
+	return SortOrder{Key: SortUpdated}
+}
+
+// SetSort remembers the sort order of the category
+func SetSort(category string, order SortOrder) error {
+	sortMutex.Lock()
+	defer sortMutex.Unlock()
+	loadSortOrdersLocked()
+	sortOrders[categoryName(category)] = order
+	if SortFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(sortOrders)
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(SortFile, data)
+}
+
+// loadSortOrdersLocked reads SortFile again when the profile changed it
+func loadSortOrdersLocked() {
+	if sortOrders != nil && sortsLoaded == SortFile {
+		return
+	}
+	sortOrders = make(map[string]SortOrder)
+	sortsLoaded = SortFile
+	if SortFile == "" {
+		return
+	}
+	if data, err := os.ReadFile(SortFile); err == nil {
+		_ = json.Unmarshal(data, &sortOrders)
+	}
+}
+
+// categoryName returns the group name of a category selector label
+func categoryName(category string) string {
+	if name, exists := labelsToCategories[category]; exists {
+		return name
+	}
+	return category
+}
+
+// Filter keeps the entries matching every criterion, empty ones match all
+type Filter struct {
+	Query     string
+	Formats   []verniy.MediaFormat
+	Statuses  []verniy.MediaStatus
+	Genres    []string
+	Unwatched bool
+}
+
+// Active counts the criteria other than the query
+func (f Filter) Active() int {
+	count := 0
+	for _, active := range []bool{len(f.Formats) > 0, len(f.Statuses) > 0, len(f.Genres) > 0, f.Unwatched} {
+		if active {
+			count++
+		}
+	}
+	return count
+}
+
+func (f Filter) matchCriteria(entry verniy.MediaList) bool {
+	media := entry.Media
+	if media == nil {
+		return false
+	}
+	if len(f.Formats) > 0 && (media.Format == nil || !slices.Contains(f.Formats, *media.Format)) {
+		return false
+	}
+	if len(f.Statuses) > 0 && (media.Status == nil || !slices.Contains(f.Statuses, *media.Status)) {
+		return false
+	}
+	for _, genre := range f.Genres {
+		if !slices.Contains(media.Genres, genre) {
+			return false
+		}
+	}
+	if f.Unwatched && UnwatchedEpisodes(entry) == 0 {
+		return false
+	}
+	return true
+}
+
+// AiredEpisodes returns the number of episodes already out, 0 when it's
+// unknown or the anime hasn't started airing
+func AiredEpisodes(media *verniy.Media) int {
+	if media == nil {
+		return 0
+	}
+	if media.NextAiringEpisode != nil {
+		return media.NextAiringEpisode.Episode - 1
+	}
+	if media.Status != nil && *media.Status == verniy.MediaStatusNotYetReleased {
+		return 0
+	}
+	if media.Episodes != nil {
+		return *media.Episodes
+	}
+	return 0
+}
+
+// UnwatchedEpisodes returns the number of aired episodes not watched yet
+func UnwatchedEpisodes(entry verniy.MediaList) int {
+	progress := 0
+	if entry.Progress != nil {
+		progress = *entry.Progress
+	}
+	return max(AiredEpisodes(entry.Media)-progress, 0)
+}
+
+// Query returns the entries of the category matching the filter, sorted in
+// the order remembered for the category. With a query the best matches come
+// first, the category order only separates the equal ones.
+func Query(category string, filter Filter) *[]verniy.MediaList {
+	fullList := FindList(category)
+	entries := make([]verniy.MediaList, 0, len(*fullList))
+	for _, entry := range *fullList {
+		if filter.matchCriteria(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	SortEntries(entries, SortOf(category))
+	if filter.Query == "" {
+		return &entries
+	}
+
+	ranked := fuzzy.Rank(filter.Query, len(entries), func(i int) []string {
+		return MediaTitles(entries[i].Media)
+	})
+	matches := make([]verniy.MediaList, len(ranked))
+	for i, index := range ranked {
+		matches[i] = entries[index]
+	}
+	return &matches
+}
+
+// SortEntries sorts in place, entries without the sorted value go last
+func SortEntries(entries []verniy.MediaList, order SortOrder) {
+	// value returns the sort value, smaller first, and false when missing
+	var value func(entry verniy.MediaList) (float64, bool)
+	switch order.Key {
+	case SortTitle:
+		sort.SliceStable(entries, func(i, j int) bool {
+			a, b := sortTitle(entries[i]), sortTitle(entries[j])
+			if order.Reversed {
+				return a > b
+			}
+			return a < b
+		})
+		return
+	case SortUpdated:
+		value = func(entry verniy.MediaList) (float64, bool) {
+			if entry.UpdatedAt == nil {
+				return 0, false
+			}
+			return -float64(*entry.UpdatedAt), true
+		}
+	case SortScore:
+		value = func(entry verniy.MediaList) (float64, bool) {
+			if entry.Score == nil || *entry.Score == 0 {
+				return 0, false
+			}
+			return -*entry.Score, true
+		}
+	case SortRemaining:
+		value = func(entry verniy.MediaList) (float64, bool) {
+			// Airing anime often have no episode count, the next episode
+			// tells how many are out
+			if entry.Media == nil || (entry.Media.Episodes == nil && entry.Media.NextAiringEpisode == nil) {
+				return 0, false
+			}
+			return float64(UnwatchedEpisodes(entry)), true
+		}
+	case SortNextAiring:
+		value = func(entry verniy.MediaList) (float64, bool) {
+			if entry.Media == nil || entry.Media.NextAiringEpisode == nil {
+				return 0, false
+			}
+			return float64(entry.Media.NextAiringEpisode.AiringAt), true
+		}
+	case SortAverageScore:
+		value = func(entry verniy.MediaList) (float64, bool) {
+			if entry.Media == nil || entry.Media.AverageScore == nil {
+				return 0, false
+			}
+			return -float64(*entry.Media.AverageScore), true
+		}
+	default:
+		return
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, okA := value(entries[i])
+		b, okB := value(entries[j])
+		if okA != okB {
+			return okA
+		}
+		if order.Reversed {
+			return a > b
+		}
+		return a < b
+	})
+}
+
+func sortTitle(entry verniy.MediaList) string {
+	if name := AnimeToName(entry.Media); name != nil {
+		return strings.ToLower(*name)
+	}
+	return ""
+}
+
+// FilterChoices returns the formats, airing statuses and genres found in the
+// list, the filter only offers those
+func FilterChoices() (formats []verniy.MediaFormat, statuses []verniy.MediaStatus, genres []string) {
+	seen := make(map[string]bool)
+	for _, group := range UserData {
+		for _, entry := range group.Entries {
+			media := entry.Media
+			if media == nil {
+				continue
+			}
+			if media.Format != nil && !seen["format:"+string(*media.Format)] {
+				seen["format:"+string(*media.Format)] = true
+				formats = append(formats, *media.Format)
+			}
+			if media.Status != nil && !seen["status:"+string(*media.Status)] {
+				seen["status:"+string(*media.Status)] = true
+				statuses = append(statuses, *media.Status)
+			}
+			for _, genre := range media.Genres {
+				if !seen["genre:"+genre] {
+					seen["genre:"+genre] = true
+					genres = append(genres, genre)
+				}
+			}
+		}
+	}
+	slices.Sort(formats)
+	slices.Sort(statuses)
+	slices.Sort(genres)
+	return formats, statuses, genres
+}
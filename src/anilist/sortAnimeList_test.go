@@ -0,0 +1,215 @@
+package anilist
+
+import (
+	"AnimeGUI/verniy"
+	"slices"
+	"testing"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// testEntry is an entry of the list, next is the next airing episode, 0
+// when none is scheduled
+func testEntry(title string, progress, episodes, next int, status verniy.MediaStatus) verniy.MediaList {
+	media := &verniy.Media{
+		Title:  &verniy.MediaTitle{English: ptr(title)},
+		Status: &status,
+	}
+	if episodes > 0 {
+		media.Episodes = ptr(episodes)
+	}
+	if next > 0 {
+		media.NextAiringEpisode = &verniy.AiringSchedule{Episode: next, AiringAt: next * 1000}
+	}
+	return verniy.MediaList{Progress: ptr(progress), Media: media}
+}
+
+// useList makes the entries the only category of the list
+func useList(t *testing.T, category string, entries []verniy.MediaList) {
+	t.Helper()
+	previousData, previousCategories := UserData, categoriesToInt
+	UserData = []verniy.MediaListGroup{{Name: ptr(category), Entries: entries}}
+	categoriesToInt = map[string]int{category: 0}
+	t.Cleanup(func() {
+		UserData, categoriesToInt = previousData, previousCategories
+	})
+}
+
+func TestAiredEpisodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		media *verniy.Media
+		want  int
+	}{
+		{name: "no media", media: nil, want: 0},
+		{name: "airing", media: testEntry("a", 0, 12, 5, verniy.MediaStatusReleasing).Media, want: 4},
+		{name: "finished", media: testEntry("a", 0, 12, 0, verniy.MediaStatusFinished).Media, want: 12},
+		{name: "not yet released", media: testEntry("a", 0, 12, 0, verniy.MediaStatusNotYetReleased).Media, want: 0},
+		{name: "first episode scheduled", media: testEntry("a", 0, 12, 1, verniy.MediaStatusNotYetReleased).Media, want: 0},
+		{name: "unknown count", media: testEntry("a", 0, 0, 0, verniy.MediaStatusReleasing).Media, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AiredEpisodes(tt.media); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnwatchedEpisodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry verniy.MediaList
+		want  int
+	}{
+		{name: "behind", entry: testEntry("a", 2, 12, 6, verniy.MediaStatusReleasing), want: 3},
+		{name: "caught up", entry: testEntry("a", 5, 12, 6, verniy.MediaStatusReleasing), want: 0},
+		{name: "ahead of Anilist", entry: testEntry("a", 8, 12, 6, verniy.MediaStatusReleasing), want: 0},
+		{name: "finished", entry: testEntry("a", 10, 12, 0, verniy.MediaStatusFinished), want: 2},
+		{name: "no progress", entry: verniy.MediaList{Media: testEntry("a", 0, 3, 0, verniy.MediaStatusFinished).Media}, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnwatchedEpisodes(tt.entry); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterMatchCriteria(t *testing.T) {
+	entry := testEntry("Frieren", 3, 28, 5, verniy.MediaStatusReleasing)
+	entry.Media.Format = ptr(verniy.MediaFormatTv)
+	entry.Media.Genres = []string{"Adventure", "Fantasy"}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{name: "empty", filter: Filter{}, want: true},
+		{name: "format", filter: Filter{Formats: []verniy.MediaFormat{verniy.MediaFormatMovie, verniy.MediaFormatTv}}, want: true},
+		{name: "other format", filter: Filter{Formats: []verniy.MediaFormat{verniy.MediaFormatMovie}}, want: false},
+		{name: "status", filter: Filter{Statuses: []verniy.MediaStatus{verniy.MediaStatusReleasing}}, want: true},
+		{name: "other status", filter: Filter{Statuses: []verniy.MediaStatus{verniy.MediaStatusFinished}}, want: false},
+		{name: "every genre", filter: Filter{Genres: []string{"Fantasy", "Adventure"}}, want: true},
+		{name: "missing genre", filter: Filter{Genres: []string{"Fantasy", "Comedy"}}, want: false},
+		{name: "unwatched", filter: Filter{Unwatched: true}, want: true},
+		{name: "all together", filter: Filter{Formats: []verniy.MediaFormat{verniy.MediaFormatTv}, Genres: []string{"Fantasy"}, Unwatched: true}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matchCriteria(entry); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	caughtUp := testEntry("Frieren", 4, 28, 5, verniy.MediaStatusReleasing)
+	if (Filter{Unwatched: true}).matchCriteria(caughtUp) {
+		t.Error("caught up entry kept by the unwatched filter")
+	}
+	if (Filter{}).matchCriteria(verniy.MediaList{}) {
+		t.Error("entry without media kept")
+	}
+	if (Filter{Formats: []verniy.MediaFormat{verniy.MediaFormatTv}}).matchCriteria(caughtUp) {
+		t.Error("entry without format kept by the format filter")
+	}
+}
+
+func TestFilterActive(t *testing.T) {
+	if got := (Filter{Query: "frieren"}).Active(); got != 0 {
+		t.Errorf("query counted as a criterion: %d", got)
+	}
+	filter := Filter{Genres: []string{"Fantasy"}, Unwatched: true}
+	if got := filter.Active(); got != 2 {
+		t.Errorf("got %d criteria, want 2", got)
+	}
+}
+
+func titles(entries []verniy.MediaList) []string {
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = *AnimeToName(entry.Media)
+	}
+	return names
+}
+
+func TestSortEntries(t *testing.T) {
+	entries := []verniy.MediaList{
+		testEntry("Bocchi", 1, 12, 0, verniy.MediaStatusFinished),
+		testEntry("Apothecary", 2, 24, 9, verniy.MediaStatusReleasing),
+		testEntry("Chainsaw", 3, 0, 0, verniy.MediaStatusReleasing),
+		testEntry("Dandadan", 4, 12, 6, verniy.MediaStatusReleasing),
+	}
+	entries[0].UpdatedAt = ptr(300)
+	entries[1].UpdatedAt = ptr(100)
+	entries[3].UpdatedAt = ptr(200)
+	entries[0].Score = ptr(7.5)
+	entries[2].Score = ptr(9.0)
+
+	tests := []struct {
+		order SortOrder
+		want  []string
+	}{
+		{SortOrder{Key: SortTitle}, []string{"Apothecary", "Bocchi", "Chainsaw", "Dandadan"}},
+		{SortOrder{Key: SortTitle, Reversed: true}, []string{"Dandadan", "Chainsaw", "Bocchi", "Apothecary"}},
+		{SortOrder{Key: SortUpdated}, []string{"Bocchi", "Dandadan", "Apothecary", "Chainsaw"}},
+		{SortOrder{Key: SortUpdated, Reversed: true}, []string{"Apothecary", "Dandadan", "Bocchi", "Chainsaw"}},
+		{SortOrder{Key: SortScore}, []string{"Chainsaw", "Bocchi", "Apothecary", "Dandadan"}},
+		{SortOrder{Key: SortRemaining}, []string{"Dandadan", "Apothecary", "Bocchi", "Chainsaw"}},
+		{SortOrder{Key: SortNextAiring}, []string{"Dandadan", "Apothecary", "Bocchi", "Chainsaw"}},
+	}
+	for _, tt := range tests {
+		sorted := append([]verniy.MediaList(nil), entries...)
+		SortEntries(sorted, tt.order)
+		if got := titles(sorted); !slices.Equal(got, tt.want) {
+			t.Errorf("%s reversed %v: got %v, want %v", tt.order.Key, tt.order.Reversed, got, tt.want)
+		}
+	}
+
+	// An airing anime without an episode count is sorted by the aired ones
+	airing := append([]verniy.MediaList{testEntry("One Piece", 1100, 0, 1103, verniy.MediaStatusReleasing)}, entries...)
+	SortEntries(airing, SortOrder{Key: SortRemaining})
+	if got, want := titles(airing), []string{"Dandadan", "One Piece", "Apothecary", "Bocchi", "Chainsaw"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	entries := []verniy.MediaList{
+		testEntry("Attack on Titan Final Season", 0, 16, 0, verniy.MediaStatusFinished),
+		testEntry("Frieren: Beyond Journey's End", 10, 28, 0, verniy.MediaStatusFinished),
+		testEntry("Attack on Titan", 25, 25, 0, verniy.MediaStatusFinished),
+		testEntry("Dandadan", 0, 12, 0, verniy.MediaStatusReleasing),
+	}
+	entries[0].Media.Synonyms = []string{"Shingeki no Kyojin: The Final Season"}
+	entries[2].Media.Synonyms = []string{"Shingeki no Kyojin"}
+	useList(t, "Watching", entries)
+	if err := SetSort("Watching", SortOrder{Key: SortTitle}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "category order", filter: Filter{}, want: []string{"Attack on Titan", "Attack on Titan Final Season", "Dandadan", "Frieren: Beyond Journey's End"}},
+		{name: "criteria", filter: Filter{Statuses: []verniy.MediaStatus{verniy.MediaStatusFinished}, Unwatched: true}, want: []string{"Attack on Titan Final Season", "Frieren: Beyond Journey's End"}},
+		{name: "query", filter: Filter{Query: "frieren"}, want: []string{"Frieren: Beyond Journey's End"}},
+		{name: "query on a synonym", filter: Filter{Query: "shingeki"}, want: []string{"Attack on Titan", "Attack on Titan Final Season"}},
+		{name: "query and criteria", filter: Filter{Query: "shingeki", Unwatched: true}, want: []string{"Attack on Titan Final Season"}},
+		{name: "no match", filter: Filter{Query: "zzzz"}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := titles(*Query("Watching", tt.filter)); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package anilist
+
+import (
+	"AnimeGUI/verniy"
+	"sort"
+	"time"
+)
+
+// airingInterval is the usual time between two episodes, the list only knows
+// when the next one airs
+const airingInterval = 7 * 24 * time.Hour
+
+// UpNextOrder is how the Up Next queue is sorted
+type UpNextOrder int
+
+const (
+	RecentlyAired UpNextOrder = iota
+	RecentlyWatched
+)
+
+// UpNext returns the anime being watched or rewatched with aired episodes left
+// to watch. lastWatched gives when an anime was last played, zero when never.
+func UpNext(order UpNextOrder, lastWatched func(mediaID int) time.Time) []*verniy.MediaList {
+	var queue []*verniy.MediaList
+	for _, entry := range watchingEntries() {
+		if UnwatchedEpisodes(*entry) > 0 {
+			queue = append(queue, entry)
+		}
+	}
+
+	key := func(entry *verniy.MediaList) time.Time {
+		if order == RecentlyWatched {
+			return lastWatched(entry.Media.ID)
+		}
+		return LastAired(entry.Media)
+	}
+	sort.SliceStable(queue, func(i, j int) bool {
+		return key(queue[i]).After(key(queue[j]))
+	})
+	return queue
+}
+
+// LastAired estimates when the last episode aired, a week before the next one
+// for airing anime and the end date for finished ones. It is zero when
+// unknown.
+func LastAired(media *verniy.Media) time.Time {
+	if media == nil {
+		return time.Time{}
+	}
+	if next := media.NextAiringEpisode; next != nil {
+		if next.Episode <= 1 {
+			return time.Time{}
+		}
+		return time.Unix(int64(next.AiringAt), 0).Add(-airingInterval)
+	}
+	date := media.EndDate
+	if date == nil || date.Year == nil {
+		return time.Time{}
+	}
+	month, day := 1, 1
+	if date.Month != nil {
+		month = *date.Month
+	}
+	if date.Day != nil {
+		day = *date.Day
+	}
+	return time.Date(*date.Year, time.Month(month), day, 0, 0, 0, 0, time.Local)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"AnimeGUI/imagecache"
+	"AnimeGUI/verniy"
+	"context"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"github.com/charmbracelet/log"
+	"image"
+	"time"
+)
+
+// coverRatio is the width/height of Anilist covers, used to size the
+// placeholder like the image it stands for
+const coverRatio = 0.7
+
+const coverLoadTimeout = 30 * time.Second
+
+// loadCoverAsync shows the placeholder in holder then the cover once it's
+// loaded. The cover is dropped if stillSelected is false by then, the user
+// moved to another anime in the meantime.
+func loadCoverAsync(holder *canvas.Image, parent fyne.CanvasObject, url string, width float32, stillSelected func() bool) {
+	// The placeholder is sized like the cover it stands for
+	setCover(holder, parent, nil, theme.MediaPhotoIcon(), fyne.NewSize(width, width/coverRatio))
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), coverLoadTimeout)
+		defer cancel()
+
+		img, err := imagecache.Default.Image(ctx, url)
+		if err != nil {
+			log.Error("Can't load cover", err)
+			return
+		}
+		if !stillSelected() {
+			return
+		}
+		size := img.Bounds().Size()
+		setCover(holder, parent, img, nil, fyne.NewSize(width, width*float32(size.Y)/float32(size.X)))
+	}()
+}
+
+// setCover changes what holder shows through its fields, the image may be
+// drawn meanwhile so it is never replaced as a whole
+func setCover(holder *canvas.Image, parent fyne.CanvasObject, img image.Image, resource fyne.Resource, size fyne.Size) {
+	holder.File = ""
+	holder.Image = img
+	holder.Resource = resource
+	holder.FillMode = canvas.ImageFillContain
+	holder.SetMinSize(size)
+	holder.Refresh()
+	parent.Refresh()
+}
+
+// prefetchNeighbourCovers loads the covers the user is likely to select next
+func prefetchNeighbourCovers(list []verniy.MediaList, id int) {
+	var urls []string
+	for _, i := range []int{id + 1, id - 1, id + 2} {
+		if i < 0 || i >= len(list) || list[i].Media == nil || list[i].Media.CoverImage == nil {
+			continue
+		}
+		if url := list[i].Media.CoverImage.ExtraLarge; url != nil {
+			urls = append(urls, *url)
+		}
+	}
+	imagecache.Default.Prefetch(urls...)
+}
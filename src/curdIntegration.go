@@ -4,11 +4,16 @@ import (
 	curd "AnimeGUI/curdInteg"
 	"AnimeGUI/src/anilist"
 	"AnimeGUI/verniy"
+	"context"
+	"errors"
 	"fmt"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
 	"github.com/charmbracelet/log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync"
 	"time"
 )
 
@@ -17,6 +22,23 @@ var userCurdConfig curd.CurdConfig
 var databaseFile string
 var user curd.User
 
+// tokenLocked is true when the saved token is encrypted and its passphrase
+// must be asked before using it
+var tokenLocked bool
+var configFilePath string
+
+// currentProfile is the profile in use, set by -profile or the last used one
+var currentProfile string
+
+// resolveTimeout bounds the whole search, link and mpv start chain
+// of a single press on the Play button.
+const resolveTimeout = time.Minute
+
+var (
+	resolveMutex  sync.Mutex
+	cancelResolve context.CancelFunc
+)
+
 func startCurdInteg() {
 	//discordClientId := "1287457464148820089"
 
@@ -29,28 +51,68 @@ func startCurdInteg() {
 		homeDir = os.Getenv("HOME")
 	}
 
-	configFilePath := filepath.Join(homeDir, ".config", "curd", "curd.conf")
+	configFilePath = filepath.Join(homeDir, ".config", "curd", "curd.conf")
 
-	// load curd userCurdConfig
-	var err error
-	userCurdConfig, err = curd.LoadConfig(configFilePath)
+	baseConfig, err := curd.LoadConfig(configFilePath)
 	if err != nil {
 		fmt.Println("Error loading config:", err)
 		return
 	}
+	if currentProfile == "" {
+		currentProfile = baseConfig.Profile
+	}
+
 	curd.SetGlobalConfig(&userCurdConfig)
+	curd.SetAnilistClient(anilist.Client)
 
 	//var logFile = filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "debug.log")
 	//curd.ClearLogFile(logFile)
 
-	// Get the token from the token file
-	user.Token, err = curd.GetTokenFromFile(filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "token"))
+	// The passphrase of the encrypted token is asked by the login screen
+	curd.PassphrasePrompt = nil
+
+	if err := loadProfile(currentProfile); err != nil {
+		log.Error(err)
+		currentProfile = curd.DefaultProfile
+		if err := loadProfile(currentProfile); err != nil {
+			fmt.Println("Error loading config:", err)
+		}
+	}
+}
+
+// loadProfile loads the config and the token of the profile, the history is
+// loaded by secondCurdInit
+func loadProfile(profile string) error {
+	config, err := curd.LoadProfileConfig(configFilePath, profile)
 	if err != nil {
-		log.Error("Error reading token")
+		return err
 	}
-	if user.Token == "" {
-		setTokenGraphicaly(filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "token"), &user)
+	userCurdConfig = config
+	user = curd.User{}
+	tokenLocked = false
+	// Each profile has its own encrypted token
+	curd.SetTokenPassphrase("")
+
+	// Get the token from the credential store
+	user.Token, err = curd.GetTokenFromFile(tokenFilePath())
+	if errors.Is(err, curd.ErrPassphraseNeeded) {
+		tokenLocked = true
+	} else if err != nil {
+		log.Error("Error reading token", err)
 	}
+	user.TokenExpiresAt, err = curd.GetTokenExpiry(tokenFilePath())
+	if err != nil {
+		log.Error(err)
+	}
+	if user.Token != "" && !user.TokenExpiresAt.IsZero() && time.Now().After(user.TokenExpiresAt) {
+		log.Info("Anilist token expired, login needed")
+		user.Token = ""
+	}
+	return nil
+}
+
+func tokenFilePath() string {
+	return filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "token")
 }
 
 func secondCurdInit() {
@@ -58,15 +120,26 @@ func secondCurdInit() {
 		curd.ChangeToken(&userCurdConfig, &user)
 	}
 
+	anilist.SnapshotFile = filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "anilist_snapshot.json")
+	anilist.SortFile = filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "list_sort.json")
+
 	var err error
 	if user.Id == 0 {
-		user.Id, user.Username, err = curd.GetAnilistUserID(user.Token)
-		if err != nil {
-			log.Error(err)
+		if snapshot, snapshotErr := anilist.LoadSnapshot(); snapshotErr == nil && snapshot.Owner.ID != 0 {
+			// Known account, no need to wait for Anilist: fetching the list
+			// in the background checks the token anyway
+			user.Id = snapshot.Owner.ID
+			user.Username = snapshot.Owner.Username
+			user.AvatarURL = snapshot.Owner.AvatarURL
+		} else {
+			err = curd.ValidateToken(context.Background(), user.Token, &user)
+			if err != nil {
+				log.Error(err)
+			}
 		}
 	}
 
-	databaseFile = filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "curd_history.txt")
+	databaseFile = curd.LocalDatabaseFile(userCurdConfig.StoragePath)
 	localAnime = curd.LocalGetAllAnime(databaseFile)
 	for _, anime := range localAnime {
 		fmt.Println(anime)
@@ -77,7 +150,7 @@ func secondCurdInit() {
 	fmt.Println(user.Id)
 
 	/*allId := localAnime[0].AllanimeId
-	url, _ := curd.GetEpisodeURL(userCurdConfig, allId, 1)
+	url, _ := curd.GetEpisodeURL(context.Background(), userCurdConfig, allId, 1)
 	fmt.Println(curd.PrioritizeLink(url))*/
 }
 
@@ -93,9 +166,20 @@ func SearchFromLocalAniId(id int) *curd.Anime {
 type AllAnimeIdData struct {
 	Id   string
 	Name string
+	// Score is the confidence the result is the anime, see scoreLink
+	Score float64
 }
 
 func OnPlayButtonClick(animeName string, animeData *verniy.MediaList) {
+	startPlayback(animeName, animeData, 0)
+}
+
+// OnPlayEpisodeClick plays the given episode instead of the next one
+func OnPlayEpisodeClick(animeName string, animeData *verniy.MediaList, episode int) {
+	startPlayback(animeName, animeData, episode)
+}
+
+func startPlayback(animeName string, animeData *verniy.MediaList, episode int) {
 	if mpvPresent == false {
 		log.Error("MPV is not yet dl")
 		return
@@ -104,22 +188,58 @@ func OnPlayButtonClick(animeName string, animeData *verniy.MediaList) {
 		log.Error("Anime data is nil")
 		return
 	}
+
+	ctx, ok := beginResolving()
+	if !ok {
+		log.Info("Already resolving an episode")
+		return
+	}
+	go func() {
+		defer endResolving()
+		err := resolveAndPlay(ctx, animeName, animeData, episode)
+		if errors.Is(err, context.Canceled) {
+			log.Info("Playback cancelled")
+		} else if err != nil {
+			log.Error(err)
+		}
+	}()
+}
+
+// watchedBefore is the progress the next episode is played from. Airing
+// shows often have no episode count, the aired episodes cap it when known
+// and a finished show replays its last episode.
+func watchedBefore(animeData *verniy.MediaList) int {
+	if animeData.Progress == nil {
+		return 0
+	}
+	progress := *animeData.Progress
+	if aired := anilist.AiredEpisodes(animeData.Media); aired > 0 {
+		progress = min(progress, aired-1)
+	}
+	return max(progress, 0)
+}
+
+// resolveAndPlay finds the allanime id and the episode link then starts mpv,
+// every network step stops as soon as ctx is cancelled. episode is the
+// episode to play, 0 continues from the progress.
+func resolveAndPlay(ctx context.Context, animeName string, animeData *verniy.MediaList, episode int) error {
 	var allAnimeId string
-	animeProgress := 0
-	if animeData.Progress != nil && animeData.Media.Episodes != nil {
-		animeProgress = min(*animeData.Progress, *animeData.Media.Episodes-1)
+	animeProgress := watchedBefore(animeData)
+	if episode > 0 {
+		animeProgress = episode - 1
 	}
 	animePointer := SearchFromLocalAniId(animeData.Media.ID)
 	if animePointer == nil {
-		allAnimeId = searchAllAnimeData(anilist.AnimeToRomaji(animeData.Media), animeData.Media.Episodes, animeProgress)
+		allAnimeId = searchAllAnimeData(ctx, animeData, animeName, animeProgress)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if allAnimeId == "" {
-			log.Error("Failed to get allAnimeId")
-			return
+			return errors.New("failed to get allAnimeId")
 		}
 		err, _ := curd.LocalUpdateAnime(databaseFile, animeData.Media.ID, allAnimeId, animeProgress, 0, 0, animeName)
 		if err != nil {
-			log.Error("Can't update database file", err)
-			return
+			return fmt.Errorf("can't update database file: %w", err)
 		} else {
 			log.Info("Successfully updated database file")
 		}
@@ -131,158 +251,94 @@ func OnPlayButtonClick(animeName string, animeData *verniy.MediaList) {
 
 	animeProgress++
 
+	// An episode picked by hand is played even if it's a filler
+	if episode == 0 {
+		var err error
+		animeProgress, err = skipFlaggedEpisodes(ctx, animeData, animeProgress)
+		if errors.Is(err, context.Canceled) {
+			return err
+		} else if err != nil {
+			log.Error("Error checking filler episodes: " + err.Error())
+		}
+	}
+
 	log.Info("Anime Progress:", animeProgress)
 
-	url, err := curd.GetEpisodeURL(userCurdConfig, allAnimeId, animeProgress)
+	url, err := curd.GetEpisodeURLWithFallback(ctx, userCurdConfig, allAnimeId, anilist.AnimeToRomaji(animeData.Media), animeProgress)
 	if err != nil {
-		log.Error(err)
-		return
+		return err
 	}
 	finalLink := curd.PrioritizeLink(url)
 	if len(finalLink) < 5 {
-		log.Error("No valid link found")
-		return
+		return errors.New("no valid link found")
 	}
 	fmt.Println("Final Link:", finalLink)
 
-	mpvSocketPath, err := curd.StartVideo(finalLink, []string{}, fmt.Sprintf("%s - Episode %d", animeName, animeProgress))
+	// Last chance to back out before mpv opens
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	mpvSocketPath, err := curd.StartVideo(finalLink, mpvArgs(), fmt.Sprintf("%s - Episode %d", animeName, animeProgress))
 	if err != nil {
-		log.Error(err)
-		return
+		return err
 	}
 	fmt.Println("MPV Socket Path:", mpvSocketPath)
 	playingAnime := curd.Anime{AnilistId: animeData.Media.ID, AllanimeId: allAnimeId}
 	playingAnime.Ep.Player.SocketPath = mpvSocketPath
 	playingAnime.Title.English = animeName
 	playingAnime.Ep.Number = animeProgress - 1
-	playingAnime.TotalEpisodes = *animeData.Media.Episodes
+	if animeData.Media.Episodes != nil {
+		playingAnime.TotalEpisodes = *animeData.Media.Episodes
+	}
 	if animePointer != nil {
 		fmt.Println("AnimePointer:", animePointer.Ep.Number, playingAnime.Ep.Number)
 		if animePointer.Ep.Number == playingAnime.Ep.Number {
 			playingAnime.Ep.Player.PlaybackTime = animePointer.Ep.Player.PlaybackTime
+			playingAnime.Ep.SkipTimes = animePointer.Ep.SkipTimes
 		}
+		playingAnime.Ep.Player.Speed = animePointer.Ep.Player.Speed
 	}
 	playingAnimeLoop(playingAnime, animeData)
+	return nil
 }
 
-func searchAllAnimeData(animeName string, epNumber *int, animeProgress int) string {
-	fmt.Println(animeName)
-	searchAnimeResult, err := curd.SearchAnime(animeName, "sub")
-	fmt.Println(searchAnimeResult)
+func searchAllAnimeData(ctx context.Context, animeData *verniy.MediaList, animeName string, animeProgress int) string {
+	candidates, err := searchLinks(ctx, animeData.Media)
 	if err != nil {
 		log.Error(err)
-	}
-
-	var AllanimeId string
-
-	if epNumber != nil {
-		AllanimeId, err = curd.FindKeyByValue(searchAnimeResult, fmt.Sprintf("%v (%d episodes)", animeName, *epNumber))
-		if err != nil {
-			log.Error("Failed to find anime in animeList:", err)
-		}
-
+		return ""
 	}
 
 	// If unable to get Allanime id automatically get manually
+	AllanimeId := confidentLink(candidates)
 	if AllanimeId == "" {
-		var keyValueArray []AllAnimeIdData
 		log.Error("Failed to link anime automatically")
-		for key, value := range searchAnimeResult {
-			keyValueArray = append(keyValueArray, AllAnimeIdData{Id: key, Name: value})
-		}
-
-		selectCorrectLinking(keyValueArray, animeName, animeProgress)
+		selectCorrectLinking(animeData, candidates, animeName, animeProgress, false)
 		return ""
 	}
-	fmt.Println(AllanimeId)
+	log.Info("Linked automatically", "allanime", AllanimeId, "score", candidates[0].Score)
 	return AllanimeId
 }
 
-func playingAnimeLoop(playingAnime curd.Anime, animeData *verniy.MediaList) {
-	fmt.Println(playingAnime.Ep.Player.PlaybackTime, "ah oue")
-	// Get video duration
-	go func() {
-		for {
-			time.Sleep(1 * time.Second)
-			if playingAnime.Ep.Duration == 0 {
-				// Get video duration
-				durationPos, err := curd.MPVSendCommand(playingAnime.Ep.Player.SocketPath, []interface{}{"get_property", "duration"})
-				if err != nil {
-					log.Error("Error getting video duration: " + err.Error())
-				} else if durationPos != nil {
-					if duration, ok := durationPos.(float64); ok {
-						playingAnime.Ep.Duration = int(duration + 0.5) // Round to nearest integer
-						log.Infof("Video duration: %d seconds", playingAnime.Ep.Duration)
-
-						if playingAnime.Ep.Player.PlaybackTime > 10 {
-							_, err := curd.SeekMPV(playingAnime.Ep.Player.SocketPath, max(0, playingAnime.Ep.Player.PlaybackTime-5))
-							if err != nil {
-								log.Error("Error seeking video: " + err.Error())
-							}
-						} else {
-							log.Error("Error seeking video: playback time is", playingAnime.Ep.Player.PlaybackTime)
-						}
-						break
-					} else {
-						log.Error("Error: duration is not a float64")
-					}
-				}
-			}
-		}
-
-		for {
-			time.Sleep(1 * time.Second)
-			timePos, err := curd.MPVSendCommand(playingAnime.Ep.Player.SocketPath, []interface{}{"get_property", "time-pos"})
-			if err != nil {
-				log.Error("Error getting video position: " + err.Error())
-				fmt.Println("EH en vrai", playingAnime.Ep.Player.PlaybackTime, playingAnime.Ep.Duration)
-				percentageWatched := curd.PercentageWatched(playingAnime.Ep.Player.PlaybackTime, playingAnime.Ep.Duration)
-
-				if int(percentageWatched) >= userCurdConfig.PercentageToMarkComplete {
-					playingAnime.Ep.Number++
-					playingAnime.Ep.Player.PlaybackTime = 0
-					var newProgress int = playingAnime.Ep.Number
-					animeData.Progress = &newProgress
-					go UpdateAnimeProgress(playingAnime.AnilistId, playingAnime.Ep.Number)
-					episodeNumber.SetText(fmt.Sprintf("Episode %d/%d", playingAnime.Ep.Number, playingAnime.TotalEpisodes))
-				}
-
-				err, tempAnime := curd.LocalUpdateAnime(databaseFile, playingAnime.AnilistId, playingAnime.AllanimeId, playingAnime.Ep.Number, playingAnime.Ep.Player.PlaybackTime, 0, playingAnime.Title.English)
-				if err == nil && tempAnime != nil {
-					log.Info("Successfully updated database file")
-					localAnime = curd.LocalGetAllAnime(databaseFile)
-				}
-				displayLocalProgress()
-				break
-			}
-			if timePos != nil && playingAnime.Ep.Duration != 0 {
-				if timing, ok := timePos.(float64); ok {
-					playingAnime.Ep.Player.PlaybackTime = int(timing + 0.5)
-					log.Infof("Video position: %d seconds", playingAnime.Ep.Player.PlaybackTime)
-				} else {
-					log.Error("Error: time-pos is not a float64")
-				}
-
-			}
-
-		}
-	}()
-
-}
-
-func UpdateAnimeProgress(animeId int, episode int) {
-	err := curd.UpdateAnimeProgress(user.Token, animeId, episode)
-	if err != nil {
-		log.Error(err)
+// UpdateAnimeProgress queues the new progress in the outbox, from is the
+// progress shown before the change
+func UpdateAnimeProgress(animeId int, from int, episode int) {
+	if anilist.Updates == nil {
+		log.Error("Progress not saved, not logged in")
+		return
 	}
-
+	anilist.Updates.QueueProgress(animeId, from, episode)
+	anilist.SetLocalProgress(animeId, episode)
 }
 
 func deleteTokenFile() {
-	err := os.Remove(filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "token"))
+	err := curd.DeleteToken(tokenFilePath())
 	if err != nil {
 		log.Error(err)
 	}
+	if err := curd.WriteTokenExpiry(tokenFilePath(), time.Time{}); err != nil {
+		log.Error(err)
+	}
 }
 
 func displayLocalProgress() {
@@ -298,3 +354,55 @@ func displayLocalProgress() {
 		episodeLastPlayback.Hide()
 	}
 }
+
+// beginResolving starts a cancellable resolve and turns the Play button
+// into a Cancel button. It returns false if a resolve is already running.
+func beginResolving() (context.Context, bool) {
+	resolveMutex.Lock()
+	defer resolveMutex.Unlock()
+	if cancelResolve != nil {
+		return nil, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	cancelResolve = cancel
+	setPlayButtonResolving(true)
+	return ctx, true
+}
+
+func endResolving() {
+	resolveMutex.Lock()
+	if cancelResolve != nil {
+		cancelResolve()
+		cancelResolve = nil
+	}
+	resolveMutex.Unlock()
+	setPlayButtonResolving(false)
+}
+
+// cancelResolving aborts the running resolve, if any, and reports whether
+// there was one to cancel.
+func cancelResolving() bool {
+	resolveMutex.Lock()
+	defer resolveMutex.Unlock()
+	if cancelResolve == nil {
+		return false
+	}
+	cancelResolve()
+	return true
+}
+
+func setPlayButtonResolving(resolving bool) {
+	if playButton == nil {
+		return
+	}
+	if resolving {
+		playButton.SetText("Cancel")
+		playButton.SetIcon(theme.CancelIcon())
+		playButton.Importance = widget.DangerImportance
+	} else {
+		playButton.SetText("Play!")
+		playButton.SetIcon(theme.MediaPlayIcon())
+		playButton.Importance = widget.HighImportance
+	}
+	playButton.Refresh()
+}
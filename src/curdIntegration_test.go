@@ -0,0 +1,36 @@
+package main
+
+import (
+	"AnimeGUI/verniy"
+	"testing"
+)
+
+func TestWatchedBefore(t *testing.T) {
+	releasing, finished := verniy.MediaStatusReleasing, verniy.MediaStatusFinished
+	twelve := 12
+	tests := []struct {
+		name     string
+		progress *int
+		media    verniy.Media
+		want     int
+	}{
+		{name: "no progress", media: verniy.Media{Status: &finished, Episodes: &twelve}, want: 0},
+		{name: "airing without a count", progress: intPtr(4), media: verniy.Media{Status: &releasing, NextAiringEpisode: &verniy.AiringSchedule{Episode: 8}}, want: 4},
+		{name: "caught up on an airing show", progress: intPtr(7), media: verniy.Media{Status: &releasing, NextAiringEpisode: &verniy.AiringSchedule{Episode: 8}}, want: 6},
+		{name: "unknown count and schedule", progress: intPtr(4), media: verniy.Media{Status: &releasing}, want: 4},
+		{name: "finished show", progress: intPtr(5), media: verniy.Media{Status: &finished, Episodes: &twelve}, want: 5},
+		{name: "completed show", progress: intPtr(12), media: verniy.Media{Status: &finished, Episodes: &twelve}, want: 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			media := tt.media
+			if got := watchedBefore(&verniy.MediaList{Progress: tt.progress, Media: &media}); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"AnimeGUI/src/anilist"
+	"AnimeGUI/verniy"
+	"context"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"html"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const detailsTimeout = 20 * time.Second
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// detailView shows what Anilist knows about an anime. Relations open in the
+// view itself, back returns to the previous one.
+type detailView struct {
+	content *fyne.Container
+	scroll  *container.Scroll
+
+	// mutex guards the fields below and the content, the details are shown
+	// from the goroutine fetching them
+	mutex sync.Mutex
+	// history holds the anime shown before the current one, opened through
+	// relations
+	history []int
+	showing int
+	// request counts the anime opened, a fetch answering an older one is
+	// dropped
+	request int
+}
+
+func newDetailView() *detailView {
+	d := &detailView{content: container.NewVBox()}
+	d.scroll = container.NewVScroll(d.content)
+	d.showMessage("Select an anime to see its details")
+	return d
+}
+
+// setMedia shows the anime selected in the list, forgetting the relations
+// opened before
+func (d *detailView) setMedia(mediaID int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.history = nil
+	d.open(mediaID)
+}
+
+// isShowing tells if the anime is the one shown or loading
+func (d *detailView) isShowing(mediaID int) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.showing == mediaID
+}
+
+// open shows the anime, fetching it unless it was seen already. d.mutex must
+// be held.
+func (d *detailView) open(mediaID int) {
+	d.showing = mediaID
+	d.request++
+	request := d.request
+	if media := anilist.CachedDetails(mediaID); media != nil {
+		d.show(media)
+		return
+	}
+	d.showMessage("Loading…")
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), detailsTimeout)
+		defer cancel()
+		media, err := anilist.Details(ctx, mediaID)
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		if d.request != request {
+			return
+		}
+		if err != nil {
+			d.showMessage("Can't load the details: " + err.Error())
+			return
+		}
+		d.show(media)
+	}()
+}
+
+func (d *detailView) back() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if len(d.history) == 0 {
+		return
+	}
+	previous := d.history[len(d.history)-1]
+	d.history = d.history[:len(d.history)-1]
+	d.open(previous)
+}
+
+func (d *detailView) showMessage(text string) {
+	message := widget.NewLabel(text)
+	message.Wrapping = fyne.TextWrapWord
+	d.content.Objects = []fyne.CanvasObject{message}
+	d.content.Refresh()
+}
+
+func (d *detailView) show(media *verniy.Media) {
+	var objects []fyne.CanvasObject
+	if len(d.history) > 0 {
+		objects = append(objects, widget.NewButtonWithIcon("Back", theme.NavigateBackIcon(), d.back))
+	}
+
+	title := widget.NewLabelWithStyle(anilist.AnimeToRomaji(media), fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	if name := anilist.AnimeToName(media); name != nil {
+		title.SetText(*name)
+	}
+	title.Wrapping = fyne.TextWrapWord
+	objects = append(objects, title, wrappedLabel(mediaFacts(media)))
+
+	if studios := studioLinks(media); len(studios) > 0 {
+		objects = append(objects, container.NewHBox(append([]fyne.CanvasObject{widget.NewLabel("Studio")}, studios...)...))
+	}
+	if len(media.Genres) > 0 {
+		objects = append(objects, wrappedLabel("Genres: "+strings.Join(media.Genres, ", ")))
+	}
+	if tags := tagNames(media); len(tags) > 0 {
+		objects = append(objects, wrappedLabel("Tags: "+strings.Join(tags, ", ")))
+	}
+
+	if media.Description != nil {
+		objects = append(objects, widget.NewSeparator(), wrappedLabel(synopsis(*media.Description)))
+	}
+
+	if relations := d.relationButtons(media); len(relations) > 0 {
+		objects = append(objects, widget.NewSeparator(), widget.NewLabelWithStyle("Relations", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		objects = append(objects, relations...)
+	}
+
+	if links := externalLinks(media); len(links) > 0 {
+		objects = append(objects, widget.NewSeparator(), container.NewGridWithColumns(3, links...))
+	}
+
+	d.content.Objects = objects
+	d.content.Refresh()
+	d.scroll.ScrollToTop()
+}
+
+// relationButtons open the related anime in the view, manga open on
+// anilist.co
+func (d *detailView) relationButtons(media *verniy.Media) []fyne.CanvasObject {
+	if media.Relations == nil {
+		return nil
+	}
+	var buttons []fyne.CanvasObject
+	for _, edge := range media.Relations.Edges {
+		node := edge.Node
+		if node == nil {
+			continue
+		}
+		text := relationText(edge)
+		if node.Type != nil && *node.Type != verniy.MediaTypeAnime {
+			link, err := url.Parse(fmt.Sprintf("https://anilist.co/%s/%d", strings.ToLower(string(*node.Type)), node.ID))
+			if err == nil {
+				buttons = append(buttons, widget.NewHyperlink(text, link))
+			}
+			continue
+		}
+		current, relatedID := media.ID, node.ID
+		button := widget.NewButton(text, func() {
+			d.mutex.Lock()
+			defer d.mutex.Unlock()
+			d.history = append(d.history, current)
+			d.open(relatedID)
+		})
+		button.Alignment = widget.ButtonAlignLeading
+		buttons = append(buttons, button)
+	}
+	return buttons
+}
+
+func relationText(edge verniy.MediaEdge) string {
+	text := ""
+	if edge.RelationType != nil {
+		text = enumName(string(*edge.RelationType)) + ": "
+	}
+	if name := anilist.AnimeToName(edge.Node); name != nil {
+		text += *name
+	}
+	if edge.Node.Format != nil {
+		text += fmt.Sprintf(" (%s)", strings.ReplaceAll(string(*edge.Node.Format), "_", " "))
+	}
+	return text
+}
+
+// mediaFacts is the format, season, episodes, source and scores on one line
+func mediaFacts(media *verniy.Media) string {
+	var facts []string
+	if media.Format != nil {
+		facts = append(facts, strings.ReplaceAll(string(*media.Format), "_", " "))
+	}
+	if media.Season != nil && media.SeasonYear != nil {
+		facts = append(facts, fmt.Sprintf("%s %d", enumName(string(*media.Season)), *media.SeasonYear))
+	} else if media.SeasonYear != nil {
+		facts = append(facts, fmt.Sprint(*media.SeasonYear))
+	}
+	if media.Status != nil {
+		facts = append(facts, enumName(string(*media.Status)))
+	}
+	if media.Episodes != nil {
+		episodes := fmt.Sprintf("%d episodes", *media.Episodes)
+		if media.Duration != nil {
+			episodes += fmt.Sprintf(" of %d min", *media.Duration)
+		}
+		facts = append(facts, episodes)
+	}
+	if media.Source != nil {
+		facts = append(facts, "Source: "+enumName(string(*media.Source)))
+	}
+	if media.AverageScore != nil {
+		facts = append(facts, fmt.Sprintf("Average score %d%%", *media.AverageScore))
+	}
+	if media.Popularity != nil {
+		facts = append(facts, fmt.Sprintf("Popularity %d", *media.Popularity))
+	}
+	return strings.Join(facts, " · ")
+}
+
+// studioLinks open the main animation studios on anilist.co
+func studioLinks(media *verniy.Media) []fyne.CanvasObject {
+	if media.Studios == nil {
+		return nil
+	}
+	var links []fyne.CanvasObject
+	for _, edge := range media.Studios.Edges {
+		if edge.Node == nil || !edge.IsMain {
+			continue
+		}
+		link, err := url.Parse(fmt.Sprintf("https://anilist.co/studio/%d", edge.Node.ID))
+		if err != nil {
+			continue
+		}
+		links = append(links, widget.NewHyperlink(edge.Node.Name, link))
+	}
+	return links
+}
+
+// tagNames lists the tags best ranked first, spoilers are left out
+func tagNames(media *verniy.Media) []string {
+	sorted := slices.Clone(media.Tags)
+	slices.SortStableFunc(sorted, func(a, b verniy.MediaTag) int {
+		return valueOr(b.Rank, 0) - valueOr(a.Rank, 0)
+	})
+	var tags []string
+	for _, tag := range sorted {
+		if valueOr(tag.IsGeneralSpoiler, false) || valueOr(tag.IsMediaSpoiler, false) {
+			continue
+		}
+		tags = append(tags, tag.Name)
+	}
+	return tags
+}
+
+// externalLinks are the trailer and the sites Anilist knows for the anime
+func externalLinks(media *verniy.Media) []fyne.CanvasObject {
+	var links []fyne.CanvasObject
+	if trailer := trailerURL(media.Trailer); trailer != nil {
+		links = append(links, widget.NewHyperlink("Trailer", trailer))
+	}
+	for _, external := range media.ExternalLinks {
+		if link, err := url.Parse(external.URL); err == nil {
+			links = append(links, widget.NewHyperlink(external.Site, link))
+		}
+	}
+	return links
+}
+
+func trailerURL(trailer *verniy.MediaTrailer) *url.URL {
+	if trailer == nil || trailer.ID == nil || trailer.Site == nil {
+		return nil
+	}
+	var link string
+	switch *trailer.Site {
+	case "youtube":
+		link = "https://www.youtube.com/watch?v=" + url.QueryEscape(*trailer.ID)
+	case "dailymotion":
+		link = "https://www.dailymotion.com/video/" + url.PathEscape(*trailer.ID)
+	default:
+		return nil
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+// synopsis turns the HTML description of Anilist into plain text
+func synopsis(description string) string {
+	text := strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n").Replace(description)
+	text = html.UnescapeString(htmlTag.ReplaceAllString(text, ""))
+	// Anilist puts a <br> after each paragraph already ending with a newline
+	for strings.Contains(text, "\n\n\n") {
+		text = strings.ReplaceAll(text, "\n\n\n", "\n\n")
+	}
+	return strings.TrimSpace(text)
+}
+
+func wrappedLabel(text string) *widget.Label {
+	label := widget.NewLabel(text)
+	label.Wrapping = fyne.TextWrapWord
+	return label
+}
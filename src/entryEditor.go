@@ -0,0 +1,234 @@
+package main
+
+import (
+	"AnimeGUI/src/anilist"
+	"AnimeGUI/verniy"
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openEntryEditor edits every field of the selected entry, saved as a single
+// mutation
+func openEntryEditor() {
+	if animeSelected == nil || animeSelected.Media == nil {
+		return
+	}
+	entry := animeSelected
+
+	statuses := make([]string, len(anilist.StatusOrder))
+	for i, status := range anilist.StatusOrder {
+		statuses[i] = anilist.StatusNames[status]
+	}
+	selectStatus := widget.NewSelect(statuses, nil)
+	currentStatus := ""
+	if entry.Status != nil {
+		currentStatus = anilist.StatusNames[*entry.Status]
+		selectStatus.Selected = currentStatus
+	}
+
+	currentScore := valueOr(entry.Score, 0)
+	scoreInput, getScore := newScoreInput(anilist.ScoreFormat, currentScore)
+
+	repeat := widget.NewEntry()
+	if entry.Repeat != nil {
+		repeat.SetText(strconv.Itoa(*entry.Repeat))
+	}
+	repeat.SetPlaceHolder("0")
+	repeat.Validator = func(s string) error {
+		if n, err := strconv.Atoi(s); s != "" && (err != nil || n < 0) {
+			return errors.New("a positive number")
+		}
+		return nil
+	}
+
+	startedAt := newFuzzyDateEntry(entry.StartedAt)
+	completedAt := newFuzzyDateEntry(entry.CompletedAt)
+
+	notes := widget.NewMultiLineEntry()
+	notes.Wrapping = fyne.TextWrapWord
+	notes.SetMinRowsVisible(4)
+	if entry.Notes != nil {
+		notes.SetText(*entry.Notes)
+	}
+
+	private := widget.NewCheck("", nil)
+	private.Checked = valueOr(entry.Private, false)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Status", selectStatus),
+		widget.NewFormItem("Score", scoreInput),
+		widget.NewFormItem("Rewatches", repeat),
+		widget.NewFormItem("Started", startedAt),
+		widget.NewFormItem("Completed", completedAt),
+		widget.NewFormItem("Notes", notes),
+		widget.NewFormItem("Private", private),
+	}
+
+	title := "Edit entry"
+	if name := anilist.AnimeToName(entry.Media); name != nil {
+		title = *name
+	}
+	editor := dialog.NewForm(title, "Save", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		var edit anilist.EntryEdit
+		if score := getScore(); score != currentScore {
+			edit.Score = &score
+		}
+		if n, _ := strconv.Atoi(repeat.Text); n != valueOr(entry.Repeat, 0) {
+			edit.Repeat = &n
+		}
+		if date, _ := parseFuzzyDate(startedAt.Text); formatFuzzyDate(date) != formatFuzzyDate(entry.StartedAt) {
+			edit.StartedAt = date
+		}
+		if date, _ := parseFuzzyDate(completedAt.Text); formatFuzzyDate(date) != formatFuzzyDate(entry.CompletedAt) {
+			edit.CompletedAt = date
+		}
+		if text := notes.Text; text != valueOr(entry.Notes, "") {
+			edit.Notes = &text
+		}
+		if checked := private.Checked; checked != valueOr(entry.Private, false) {
+			edit.Private = &checked
+		}
+
+		var status verniy.MediaListStatus
+		if selectStatus.Selected != currentStatus {
+			for _, s := range anilist.StatusOrder {
+				if anilist.StatusNames[s] == selectStatus.Selected {
+					status = s
+				}
+			}
+		}
+		if status == "" && edit == (anilist.EntryEdit{}) {
+			return
+		}
+		if err := anilist.EditEntry(categoryRadio, entry.Media.ID, status, edit); err != nil {
+			dialog.ShowError(err, window)
+		}
+	}, window)
+	editor.Resize(fyne.NewSize(450, 500))
+	editor.Show()
+}
+
+// newScoreInput returns the score widget of the format and a getter of the
+// score in that format, 0 is no score
+func newScoreInput(format verniy.ScoreFormat, score float64) (fyne.CanvasObject, func() float64) {
+	var choices []string
+	switch format {
+	case "":
+		// Saving a score in the wrong format would change it a lot
+		return widget.NewLabel("Unknown score format, connect to Anilist first"), func() float64 { return score }
+	case verniy.ScoreFormatPoint5:
+		choices = []string{"No score", "1 star", "2 stars", "3 stars", "4 stars", "5 stars"}
+	case verniy.ScoreFormatPoint3:
+		choices = []string{"No score", ":(", ":|", ":)"}
+	}
+	if choices != nil {
+		selectScore := widget.NewSelect(choices, nil)
+		selectScore.SetSelectedIndex(min(max(int(score), 0), len(choices)-1))
+		return selectScore, func() float64 { return float64(max(selectScore.SelectedIndex(), 0)) }
+	}
+
+	maxScore, step, precision := 10.0, 0.1, 1
+	switch format {
+	case verniy.ScoreFormatPoint100:
+		maxScore, step, precision = 100, 1, 0
+	case verniy.ScoreFormatPoint10:
+		step, precision = 1, 0
+	}
+	slider := widget.NewSlider(0, maxScore)
+	slider.Step = step
+	slider.Value = min(score, maxScore)
+	value := widget.NewLabel("")
+	showValue := func(f float64) {
+		if f == 0 {
+			value.SetText("No score")
+			return
+		}
+		value.SetText(strconv.FormatFloat(f, 'f', precision, 64))
+	}
+	showValue(slider.Value)
+	slider.OnChanged = showValue
+	return container.NewBorder(nil, nil, nil, value, slider), func() float64 { return slider.Value }
+}
+
+// newFuzzyDateEntry edits a date Anilist allows to be partial, like 2024 or
+// 2024-03
+func newFuzzyDateEntry(date *verniy.FuzzyDate) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("YYYY-MM-DD")
+	entry.SetText(formatFuzzyDate(date))
+	entry.Validator = func(s string) error {
+		_, err := parseFuzzyDate(s)
+		return err
+	}
+	entry.ActionItem = widget.NewButton("Today", func() {
+		entry.SetText(time.Now().Format("2006-01-02"))
+	})
+	return entry
+}
+
+func formatFuzzyDate(date *verniy.FuzzyDate) string {
+	if date == nil || date.Year == nil {
+		return ""
+	}
+	text := fmt.Sprintf("%04d", *date.Year)
+	if date.Month != nil {
+		text += fmt.Sprintf("-%02d", *date.Month)
+		if date.Day != nil {
+			text += fmt.Sprintf("-%02d", *date.Day)
+		}
+	}
+	return text
+}
+
+// parseFuzzyDate reads YYYY, YYYY-MM or YYYY-MM-DD, an empty text clears the
+// date
+func parseFuzzyDate(text string) (*verniy.FuzzyDate, error) {
+	text = strings.TrimSpace(text)
+	date := &verniy.FuzzyDate{}
+	if text == "" {
+		return date, nil
+	}
+
+	parts := strings.Split(text, "-")
+	if len(parts) > 3 {
+		return nil, errors.New("YYYY-MM-DD")
+	}
+	limits := []int{9999, 12, 31}
+	values := make([]*int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 || n > limits[i] {
+			return nil, errors.New("YYYY-MM-DD")
+		}
+		values[i] = &n
+	}
+	date.Year = values[0]
+	if len(values) > 1 {
+		date.Month = values[1]
+	}
+	if len(values) > 2 {
+		date.Day = values[2]
+		if _, err := time.Parse("2006-1-2", text); err != nil {
+			return nil, errors.New("not a valid date")
+		}
+	}
+	return date, nil
+}
+
+func valueOr[T any](value *T, fallback T) T {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
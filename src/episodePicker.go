@@ -0,0 +1,209 @@
+package main
+
+import (
+	curd "AnimeGUI/curdInteg"
+	"AnimeGUI/src/anilist"
+	"AnimeGUI/verniy"
+	"context"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pickerEpisode is one row of the episode picker
+type pickerEpisode struct {
+	Number int
+	Info   curd.Episode
+	// HasInfo is false until the Jikan metadata arrived
+	HasInfo bool
+}
+
+// openEpisodePicker shows every episode of the selected anime and lets the
+// user play any of them
+func openEpisodePicker() {
+	animeData := animeSelected
+	if animeData == nil {
+		return
+	}
+	animeName := anilist.AnimeToName(animeData.Media)
+	if animeName == nil {
+		return
+	}
+	name := *animeName
+
+	var (
+		episodesMutex sync.Mutex
+		episodes      []pickerEpisode
+	)
+
+	status := widget.NewLabel("Loading episodes...")
+	progress := widget.NewProgressBarInfinite()
+
+	var dialogPicker *dialog.CustomDialog
+	list := widget.NewList(
+		func() int {
+			episodesMutex.Lock()
+			defer episodesMutex.Unlock()
+			return len(episodes)
+		},
+		func() fyne.CanvasObject {
+			number := widget.NewLabelWithStyle("EP 000", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			title := widget.NewLabel("Title")
+			title.Truncation = fyne.TextTruncateEllipsis
+			details := widget.NewLabelWithStyle("Details", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+			play := widget.NewButtonWithIcon("", theme.MediaPlayIcon(), nil)
+			return container.NewBorder(nil, nil, number, play, container.NewVBox(title, details))
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			episodesMutex.Lock()
+			ep := episodes[i]
+			episodesMutex.Unlock()
+
+			row := o.(*fyne.Container)
+			center := row.Objects[0].(*fyne.Container)
+			center.Objects[0].(*widget.Label).SetText(episodeTitle(ep))
+			center.Objects[1].(*widget.Label).SetText(episodeDetails(ep, animeData))
+			row.Objects[1].(*widget.Label).SetText(fmt.Sprintf("EP %d", ep.Number))
+			row.Objects[2].(*widget.Button).OnTapped = func() {
+				dialogPicker.Hide()
+				OnPlayEpisodeClick(name, animeData, ep.Number)
+			}
+		})
+
+	content := container.NewBorder(container.NewVBox(status, progress), nil, nil, nil, list)
+	dialogPicker = dialog.NewCustom(fmt.Sprintf("Episodes of %s", name), "Close", content, window)
+	dialogPicker.Resize(fyne.NewSize(600, 700))
+	dialogPicker.Show()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+		defer cancel()
+
+		numbers, available := loadEpisodeNumbers(ctx, animeData)
+		episodesMutex.Lock()
+		episodes = make([]pickerEpisode, len(numbers))
+		for i, number := range numbers {
+			episodes[i] = pickerEpisode{Number: number}
+		}
+		episodesMutex.Unlock()
+		if available {
+			status.SetText(fmt.Sprintf("%d episodes available", len(numbers)))
+		} else {
+			status.SetText("Anime not linked yet, provider availability unknown")
+		}
+		list.Refresh()
+
+		if len(numbers) > 0 {
+			scrollTo := 0
+			if animeData.Progress != nil {
+				scrollTo = min(*animeData.Progress, len(numbers)-1)
+			}
+			list.ScrollTo(scrollTo)
+		}
+
+		malId, err := curd.GetAnimeMalID(ctx, animeData.Media.ID)
+		if err != nil {
+			log.Error("Can't get MAL id for episode metadata", err)
+			progress.Hide()
+			return
+		}
+		infos, err := curd.GetAnimeEpisodes(ctx, malId)
+		if err != nil {
+			log.Error("Can't get episode metadata", err)
+		}
+
+		episodesMutex.Lock()
+		for i := range episodes {
+			if info, ok := infos[episodes[i].Number]; ok {
+				episodes[i].Info = info
+				episodes[i].HasInfo = true
+			}
+		}
+		episodesMutex.Unlock()
+		progress.Hide()
+		list.Refresh()
+	}()
+}
+
+// loadEpisodeNumbers lists the episodes the provider has. When the anime
+// isn't linked to the provider yet the count from Anilist is used and
+// available is false.
+func loadEpisodeNumbers(ctx context.Context, animeData *verniy.MediaList) (numbers []int, available bool) {
+	var allAnimeId string
+	if localDbAnime := SearchFromLocalAniId(animeData.Media.ID); localDbAnime != nil {
+		allAnimeId = localDbAnime.AllanimeId
+	} else {
+		candidates, err := searchLinks(ctx, animeData.Media)
+		if err != nil {
+			log.Error(err)
+		}
+		allAnimeId = confidentLink(candidates)
+	}
+
+	if allAnimeId != "" {
+		episodeList, err := curd.EpisodesList(ctx, allAnimeId, userCurdConfig.SubOrDub)
+		if err != nil {
+			log.Error(err)
+		}
+		for _, ep := range episodeList {
+			number, err := strconv.ParseFloat(ep, 64)
+			// Half episodes (recaps like 12.5) can't be tracked on Anilist
+			if err != nil || number != float64(int(number)) || number < 1 {
+				continue
+			}
+			numbers = append(numbers, int(number))
+		}
+		if len(numbers) > 0 {
+			return numbers, true
+		}
+	}
+
+	for i := 1; i <= anilist.AiredEpisodes(animeData.Media); i++ {
+		numbers = append(numbers, i)
+	}
+	return numbers, false
+}
+
+func episodeTitle(ep pickerEpisode) string {
+	if ep.HasInfo && ep.Info.Title.English != "" {
+		return ep.Info.Title.English
+	}
+	return fmt.Sprintf("Episode %d", ep.Number)
+}
+
+// episodeDetails builds the aired date, filler/recap badges and the watched
+// or resume state of an episode
+func episodeDetails(ep pickerEpisode, animeData *verniy.MediaList) string {
+	var details []string
+	if ep.HasInfo {
+		if aired, err := time.Parse(time.RFC3339, ep.Info.Aired); err == nil {
+			details = append(details, "Aired "+aired.Format("2006-01-02"))
+		}
+		if ep.Info.IsFiller {
+			details = append(details, "[Filler]")
+		}
+		if ep.Info.IsRecap {
+			details = append(details, "[Recap]")
+		}
+	}
+
+	localDbAnime := SearchFromLocalAniId(animeData.Media.ID)
+	if localDbAnime != nil && localDbAnime.Ep.Number+1 == ep.Number && localDbAnime.Ep.Player.PlaybackTime > 0 {
+		details = append(details, fmt.Sprintf("Resume at %s", time.Second*time.Duration(localDbAnime.Ep.Player.PlaybackTime)))
+	} else if animeData.Progress != nil && ep.Number <= *animeData.Progress {
+		details = append(details, "Watched")
+	}
+
+	if len(details) == 0 {
+		return " "
+	}
+	return strings.Join(details, " · ")
+}
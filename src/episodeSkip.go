@@ -0,0 +1,114 @@
+package main
+
+import (
+	curd "AnimeGUI/curdInteg"
+	"AnimeGUI/src/anilist"
+	"AnimeGUI/verniy"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// skippedEpisode is a filler or recap episode passed over before playing
+type skippedEpisode struct {
+	Number int
+	Reason string
+}
+
+var (
+	skippedMutex    sync.Mutex
+	skippedEpisodes = map[int][]skippedEpisode{}
+)
+
+// skipFlaggedEpisodes moves episode past the filler and recap episodes when
+// SkipFiller or SkipRecap are on. Skipped episodes are marked as watched and
+// the first episode to play is returned.
+func skipFlaggedEpisodes(ctx context.Context, animeData *verniy.MediaList, episode int) (int, error) {
+	if !userCurdConfig.SkipFiller && !userCurdConfig.SkipRecap {
+		return episode, nil
+	}
+
+	malId, err := curd.GetAnimeMalID(ctx, animeData.Media.ID)
+	if err != nil {
+		return episode, err
+	}
+
+	last := anilist.AiredEpisodes(animeData.Media)
+	var skipped []skippedEpisode
+	for last == 0 || episode <= last {
+		if ctx.Err() != nil {
+			return episode, ctx.Err()
+		}
+
+		var anime curd.Anime
+		if err := curd.GetEpisodeData(ctx, malId, episode, &anime); err != nil {
+			break
+		}
+
+		var reason string
+		if userCurdConfig.SkipFiller && anime.Ep.IsFiller {
+			reason = "filler"
+		} else if userCurdConfig.SkipRecap && anime.Ep.IsRecap {
+			reason = "recap"
+		}
+		if reason == "" {
+			break
+		}
+
+		skipped = append(skipped, skippedEpisode{Number: episode, Reason: reason})
+		episode++
+		// Jikan allows 3 requests per second
+		time.Sleep(time.Second / 3)
+	}
+
+	if len(skipped) == 0 {
+		return episode, nil
+	}
+
+	skippedMutex.Lock()
+	skippedEpisodes[animeData.Media.ID] = append(skippedEpisodes[animeData.Media.ID], skipped...)
+	skippedMutex.Unlock()
+
+	progress := episode - 1
+	from := 0
+	if animeData.Progress != nil {
+		from = *animeData.Progress
+	}
+	animeData.Progress = &progress
+	UpdateAnimeProgress(animeData.Media.ID, from, progress)
+	if animeSelected == animeData {
+		if animeData.Media.Episodes != nil {
+			episodeNumber.SetText(fmt.Sprintf("Episode %d/%d", progress, *animeData.Media.Episodes))
+		}
+		displaySkippedEpisodes()
+	}
+
+	return episode, nil
+}
+
+// displaySkippedEpisodes lists in the detail pane the episodes skipped for
+// the selected anime
+func displaySkippedEpisodes() {
+	if animeSelected == nil {
+		episodeSkipped.Hide()
+		return
+	}
+
+	skippedMutex.Lock()
+	skipped := skippedEpisodes[animeSelected.Media.ID]
+	skippedMutex.Unlock()
+
+	if len(skipped) == 0 {
+		episodeSkipped.Hide()
+		return
+	}
+
+	parts := make([]string, len(skipped))
+	for i, ep := range skipped {
+		parts[i] = fmt.Sprintf("EP%d (%s)", ep.Number, ep.Reason)
+	}
+	episodeSkipped.SetText("Skipped " + strings.Join(parts, ", "))
+	episodeSkipped.Show()
+}
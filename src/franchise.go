@@ -0,0 +1,133 @@
+package main
+
+import (
+	"AnimeGUI/src/anilist"
+	"AnimeGUI/verniy"
+	"context"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"strings"
+	"time"
+)
+
+const franchiseTimeout = 2 * time.Minute
+
+// openFranchiseDialog lists the prequels, sequels, side stories and spin-offs
+// of the selected anime in release order and suggests the next one to watch
+func openFranchiseDialog() {
+	if animeSelected == nil || animeSelected.Media == nil {
+		return
+	}
+	mediaID := animeSelected.Media.ID
+
+	progress := widget.NewLabel("Looking for related anime…")
+	content := container.NewStack(container.NewCenter(progress))
+	franchiseDialog := dialog.NewCustom("Franchise", "Close", content, window)
+	franchiseDialog.Resize(fyne.NewSize(700, 600))
+
+	ctx, cancel := context.WithTimeout(context.Background(), franchiseTimeout)
+	franchiseDialog.SetOnClosed(cancel)
+	franchiseDialog.Show()
+
+	go func() {
+		entries, err := anilist.Franchise(ctx, mediaID, func(found int) {
+			progress.SetText(fmt.Sprintf("Looking for related anime… %d found", found))
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			progress.SetText("Can't load the franchise: " + err.Error())
+			return
+		}
+		content.Objects = []fyne.CanvasObject{newFranchiseView(entries)}
+		content.Refresh()
+	}()
+}
+
+// newFranchiseView shows the suggestion above every anime of the franchise
+func newFranchiseView(entries []anilist.FranchiseEntry) fyne.CanvasObject {
+	rows := container.NewVBox()
+	var suggestion *fyne.Container
+	var refresh func()
+	refresh = func() {
+		rows.Objects = nil
+		for i := range entries {
+			rows.Add(franchiseRow(&entries[i], refresh))
+		}
+		rows.Refresh()
+
+		suggestion.Objects = nil
+		next := anilist.NextInFranchise(entries)
+		if next == nil {
+			suggestion.Add(widget.NewLabel("Nothing left to watch in this franchise"))
+		} else {
+			title := widget.NewLabelWithStyle("Up next: "+franchiseTitle(next.Media), fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			title.Wrapping = fyne.TextWrapWord
+			suggestion.Add(container.NewBorder(nil, nil, nil, addButtons(next, refresh), title))
+		}
+		suggestion.Refresh()
+	}
+	suggestion = container.NewVBox()
+	refresh()
+	return container.NewBorder(container.NewVBox(suggestion, widget.NewSeparator()), nil, nil, nil, container.NewVScroll(rows))
+}
+
+func franchiseRow(entry *anilist.FranchiseEntry, refresh func()) fyne.CanvasObject {
+	var details []string
+	if date := formatFuzzyDate(entry.Media.StartDate); date != "" {
+		details = append(details, date)
+	}
+	if entry.Media.Format != nil {
+		details = append(details, strings.ReplaceAll(string(*entry.Media.Format), "_", " "))
+	}
+	if entry.Relation != "" {
+		details = append(details, enumName(string(entry.Relation)))
+	}
+	title := widget.NewLabel(franchiseTitle(entry.Media) + "\n" + strings.Join(details, " · "))
+	title.Wrapping = fyne.TextWrapWord
+
+	var right fyne.CanvasObject
+	if entry.Status == "" || entry.Status == verniy.MediaListStatusPlanning {
+		right = addButtons(entry, refresh)
+	} else {
+		right = widget.NewLabel(anilist.StatusNames[entry.Status])
+	}
+	return container.NewBorder(nil, nil, nil, right, title)
+}
+
+// addButtons put the anime in Planning or Watching, an anime already planned
+// can only be started
+func addButtons(entry *anilist.FranchiseEntry, refresh func()) fyne.CanvasObject {
+	add := func(status verniy.MediaListStatus) func() {
+		return func() {
+			if err := anilist.MoveEntry(categoryRadio, entry.Media.ID, status, nil); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			entry.Status = status
+			refresh()
+		}
+	}
+	buttons := container.NewHBox(layout.NewSpacer())
+	if entry.Status == "" {
+		buttons.Add(widget.NewButton(anilist.StatusNames[verniy.MediaListStatusPlanning], add(verniy.MediaListStatusPlanning)))
+	} else {
+		buttons.Add(widget.NewLabel(anilist.StatusNames[entry.Status]))
+	}
+	watch := widget.NewButton(anilist.StatusNames[verniy.MediaListStatusCurrent], add(verniy.MediaListStatusCurrent))
+	watch.Importance = widget.HighImportance
+	buttons.Add(watch)
+	return buttons
+}
+
+func franchiseTitle(media *verniy.Media) string {
+	if name := anilist.AnimeToName(media); name != nil {
+		return *name
+	}
+	return anilist.AnimeToRomaji(media)
+}
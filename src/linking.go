@@ -0,0 +1,228 @@
+package main
+
+import (
+	curd "AnimeGUI/curdInteg"
+	"AnimeGUI/fuzzy"
+	"AnimeGUI/src/anilist"
+	"AnimeGUI/verniy"
+	"context"
+	"fmt"
+	"fyne.io/fyne/v2/dialog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// linkThreshold is the confidence a provider result needs to be linked
+	// without asking the user
+	linkThreshold = 0.8
+	// linkMargin is how far ahead of the second result the best one must be,
+	// two close results are left to the user
+	linkMargin = 0.03
+	// airingTolerance is how many episodes the provider may be ahead or
+	// behind Anilist for a show still airing
+	airingTolerance = 2
+)
+
+var (
+	providerLabel = regexp.MustCompile(`^(.*) \((\d+|Unknown) episodes\)$`)
+	yearHint      = regexp.MustCompile(`\s*\(((?:19|20)\d\d)\)`)
+	seasonHints   = []*regexp.Regexp{
+		regexp.MustCompile(`\bseason (\d+)\b`),
+		regexp.MustCompile(`\b(\d+)(?:st|nd|rd|th) season\b`),
+		regexp.MustCompile(`\bs(\d+)$`),
+		regexp.MustCompile(`\bpart (\d+)\b`),
+	}
+	romanSeasons = map[string]int{"ii": 2, "iii": 3, "iv": 4, "v": 5, "vi": 6}
+)
+
+// rankLinks scores every provider result against the anime, best first
+func rankLinks(media *verniy.Media, results map[string]string) []AllAnimeIdData {
+	candidates := make([]AllAnimeIdData, 0, len(results))
+	for id, label := range results {
+		candidates = append(candidates, AllAnimeIdData{Id: id, Name: label, Score: scoreLink(media, label)})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	return candidates
+}
+
+// confidentLink returns the id of the best result when it is sure enough to
+// be linked without asking
+func confidentLink(candidates []AllAnimeIdData) string {
+	if len(candidates) == 0 || candidates[0].Score < linkThreshold {
+		return ""
+	}
+	if len(candidates) > 1 && candidates[0].Score-candidates[1].Score < linkMargin {
+		return ""
+	}
+	return candidates[0].Id
+}
+
+// scoreLink is the confidence, from 0 to 1, that the provider label is the
+// anime. Titles are compared loosely, then the season number, the year and
+// the episode count must agree.
+func scoreLink(media *verniy.Media, label string) float64 {
+	name, episodes := parseProviderLabel(label)
+	titles := anilist.MediaTitles(media)
+
+	year := 0
+	if match := yearHint.FindStringSubmatch(name); match != nil {
+		year, _ = strconv.Atoi(match[1])
+		name = yearHint.ReplaceAllString(name, "")
+	}
+
+	score := 0.0
+	for _, title := range titles {
+		score = max(score, fuzzy.Similarity(name, yearHint.ReplaceAllString(title, "")))
+	}
+
+	if seasonOf(name) != seasonOfMedia(titles) {
+		score *= 0.5
+	}
+	if year != 0 && media.SeasonYear != nil && *media.SeasonYear != year {
+		score *= 0.6
+	}
+	return score * episodesFactor(media, episodes)
+}
+
+// episodesFactor checks the episode count of the provider against Anilist,
+// the provider may be a bit off while the show airs
+func episodesFactor(media *verniy.Media, episodes int) float64 {
+	if episodes < 0 {
+		return 0.9
+	}
+	finished := media.Status != nil && *media.Status == verniy.MediaStatusFinished
+	switch {
+	case finished && media.Episodes != nil:
+		if episodes == *media.Episodes {
+			return 1
+		}
+		// Recaps and specials are sometimes counted
+		if episodes == *media.Episodes+1 || episodes == *media.Episodes-1 {
+			return 0.9
+		}
+		return 0.7
+	case media.Status != nil && *media.Status == verniy.MediaStatusReleasing:
+		aired := anilist.AiredEpisodes(media)
+		if episodes >= aired-airingTolerance && episodes <= aired+airingTolerance {
+			return 1
+		}
+		if media.Episodes != nil && episodes <= *media.Episodes {
+			return 0.9
+		}
+		return 0.7
+	case media.Episodes != nil && episodes == *media.Episodes:
+		return 1
+	}
+	return 0.9
+}
+
+// parseProviderLabel splits "Name (N episodes)", episodes is -1 when unknown
+func parseProviderLabel(label string) (string, int) {
+	match := providerLabel.FindStringSubmatch(label)
+	if match == nil {
+		return label, -1
+	}
+	episodes, err := strconv.Atoi(match[2])
+	if err != nil {
+		return match[1], -1
+	}
+	return match[1], episodes
+}
+
+// seasonOf finds the season number in a title, 1 when there is none. Only
+// numbers after a season or part marker count, a bare one is often part of
+// the name like Kaiju No. 8.
+func seasonOf(title string) int {
+	text := strings.Join(strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return r == ':' || r == ' ' || r == '-' || r == ','
+	}), " ")
+	for _, hint := range seasonHints {
+		if match := hint.FindStringSubmatch(text); match != nil {
+			if n, err := strconv.Atoi(match[1]); err == nil && n > 0 {
+				return n
+			}
+		}
+	}
+	if words := strings.Fields(text); len(words) > 1 {
+		if n, exists := romanSeasons[words[len(words)-1]]; exists {
+			return n
+		}
+	}
+	return 1
+}
+
+// seasonOfMedia is the season found in any title, English ones often number
+// seasons the Romaji one doesn't
+func seasonOfMedia(titles []string) int {
+	season := 1
+	for _, title := range titles {
+		season = max(season, seasonOf(title))
+	}
+	return season
+}
+
+// searchLinks searches the provider with the Romaji title, then the English
+// one when nothing is good enough, and ranks every result
+func searchLinks(ctx context.Context, media *verniy.Media) ([]AllAnimeIdData, error) {
+	results := make(map[string]string)
+	var firstErr error
+	for _, title := range []string{anilist.AnimeToRomaji(media), valueOr(anilist.AnimeToName(media), "")} {
+		if title == "" {
+			continue
+		}
+		found, err := curd.SearchAnime(ctx, title, "sub")
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for id, label := range found {
+			results[id] = label
+		}
+		if confidentLink(rankLinks(media, results)) != "" {
+			break
+		}
+	}
+	if len(results) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return rankLinks(media, results), nil
+}
+
+// linkLabel shows a result with its confidence in the manual dialog
+func linkLabel(candidate AllAnimeIdData) string {
+	return fmt.Sprintf("%s  [%.0f%%]", candidate.Name, candidate.Score*100)
+}
+
+// openRelinkDialog searches the provider again for the selected anime so
+// a wrong automatic link can be replaced
+func openRelinkDialog() {
+	if animeSelected == nil || animeSelected.Media == nil {
+		return
+	}
+	entry := animeSelected
+	animeName := valueOr(anilist.AnimeToName(entry.Media), anilist.AnimeToRomaji(entry.Media))
+	go func() {
+		candidates, err := searchLinks(context.Background(), entry.Media)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		progress := 0
+		if current := SearchFromLocalAniId(entry.Media.ID); current != nil {
+			progress = current.Ep.Number
+		} else if entry.Progress != nil {
+			progress = *entry.Progress
+		}
+		selectCorrectLinking(entry, candidates, animeName, progress, true)
+	}()
+}
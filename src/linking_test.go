@@ -0,0 +1,47 @@
+package main
+
+import (
+	"AnimeGUI/verniy"
+	"testing"
+)
+
+func TestSeasonOf(t *testing.T) {
+	tests := []struct {
+		title string
+		want  int
+	}{
+		{"Kaiju No. 8", 1},
+		{"Kaijuu 8-gou", 1},
+		{"Mob Psycho 100", 1},
+		{"Spy x Family Season 2", 2},
+		{"Jujutsu Kaisen 2nd Season", 2},
+		{"Oshi no Ko S2", 2},
+		{"Bungou Stray Dogs Part 5", 5},
+		{"Mob Psycho 100 III", 3},
+		{"Overlord IV", 4},
+		{"V", 1},
+	}
+	for _, tt := range tests {
+		if got := seasonOf(tt.title); got != tt.want {
+			t.Errorf("seasonOf(%q) = %d, want %d", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestScoreLinkNumberInTitle(t *testing.T) {
+	english, romaji := "Kaiju No. 8", "Kaijuu 8-gou"
+	status := verniy.MediaStatusFinished
+	episodes := 12
+	media := &verniy.Media{
+		Title:    &verniy.MediaTitle{English: &english, Romaji: &romaji},
+		Status:   &status,
+		Episodes: &episodes,
+	}
+	candidates := rankLinks(media, map[string]string{
+		"a": "Kaijuu 8-gou (12 episodes)",
+		"b": "Kaijuu 8-gou 2nd Season (11 episodes)",
+	})
+	if candidates[0].Id != "a" || confidentLink(candidates) != "a" {
+		t.Errorf("got %+v", candidates)
+	}
+}
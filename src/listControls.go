@@ -0,0 +1,174 @@
+package main
+
+import (
+	"AnimeGUI/src/anilist"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/log"
+	"slices"
+	"strings"
+)
+
+// listFilter filters the main list, its query is the text of the filter entry
+var listFilter anilist.Filter
+
+// newListControls returns the sort and filter controls of the main list and
+// a function showing the sort remembered for a category. onChange is called
+// when the list must be rebuilt.
+func newListControls(category func() string, onChange func()) (fyne.CanvasObject, func(string)) {
+	listFilter = anilist.Filter{}
+
+	sortKeys := make([]string, len(anilist.SortKeys))
+	for i, key := range anilist.SortKeys {
+		sortKeys[i] = string(key)
+	}
+	selectSort := widget.NewSelect(sortKeys, nil)
+	reverse := widget.NewButtonWithIcon("", theme.MoveDownIcon(), nil)
+	filterButton := widget.NewButtonWithIcon("Filters", theme.SearchIcon(), nil)
+
+	// Showing the sort of another category doesn't change anything
+	showing := false
+	showSortOf := func(category string) {
+		order := anilist.SortOf(category)
+		showing = true
+		selectSort.SetSelected(string(order.Key))
+		showing = false
+		if order.Reversed {
+			reverse.SetIcon(theme.MoveUpIcon())
+		} else {
+			reverse.SetIcon(theme.MoveDownIcon())
+		}
+	}
+	saveSort := func(order anilist.SortOrder) {
+		if err := anilist.SetSort(category(), order); err != nil {
+			log.Error("Failed to save the sort order", "err", err)
+		}
+		showSortOf(category())
+		onChange()
+	}
+
+	selectSort.OnChanged = func(key string) {
+		if showing || category() == "" {
+			return
+		}
+		order := anilist.SortOf(category())
+		order.Key = anilist.SortKey(key)
+		saveSort(order)
+	}
+	reverse.OnTapped = func() {
+		if category() == "" {
+			return
+		}
+		order := anilist.SortOf(category())
+		order.Reversed = !order.Reversed
+		saveSort(order)
+	}
+	filterButton.OnTapped = func() {
+		showFilterDialog(func() {
+			if active := listFilter.Active(); active > 0 {
+				filterButton.SetText(fmt.Sprintf("Filters (%d)", active))
+				filterButton.Importance = widget.HighImportance
+			} else {
+				filterButton.SetText("Filters")
+				filterButton.Importance = widget.MediumImportance
+			}
+			filterButton.Refresh()
+			onChange()
+		})
+	}
+
+	showSortOf("")
+	sortRow := container.NewBorder(nil, nil, widget.NewLabel("Sort"), reverse, selectSort)
+	return container.NewBorder(nil, nil, nil, filterButton, sortRow), showSortOf
+}
+
+// showFilterDialog edits listFilter with the values found in the list,
+// onApply is called once it changed
+func showFilterDialog(onApply func()) {
+	formats, statuses, genres := anilist.FilterChoices()
+
+	formatNames := make([]string, len(formats))
+	for i, format := range formats {
+		formatNames[i] = strings.ReplaceAll(string(format), "_", " ")
+	}
+	checkFormats := widget.NewCheckGroup(formatNames, nil)
+	checkFormats.Horizontal = true
+	for _, format := range listFilter.Formats {
+		checkFormats.Selected = append(checkFormats.Selected, strings.ReplaceAll(string(format), "_", " "))
+	}
+
+	statusNames := make([]string, len(statuses))
+	for i, status := range statuses {
+		statusNames[i] = enumName(string(status))
+	}
+	checkStatuses := widget.NewCheckGroup(statusNames, nil)
+	checkStatuses.Horizontal = true
+	for _, status := range listFilter.Statuses {
+		checkStatuses.Selected = append(checkStatuses.Selected, enumName(string(status)))
+	}
+
+	// A check group doesn't wrap, the many genres go in a grid instead
+	selectedGenres := make(map[string]bool)
+	genresGrid := container.NewGridWithColumns(3)
+	for _, genre := range genres {
+		genre := genre
+		check := widget.NewCheck(genre, func(checked bool) { selectedGenres[genre] = checked })
+		check.Checked = slices.Contains(listFilter.Genres, genre)
+		selectedGenres[genre] = check.Checked
+		genresGrid.Add(check)
+	}
+
+	unwatched := widget.NewCheck("Only with unwatched aired episodes", nil)
+	unwatched.Checked = listFilter.Unwatched
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle("Format", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		checkFormats,
+		widget.NewLabelWithStyle("Airing status", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		checkStatuses,
+		widget.NewLabelWithStyle("Genres, all of them", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		genresGrid,
+		unwatched,
+	)
+
+	filterDialog := dialog.NewCustomConfirm("Filters", "Apply", "Cancel", container.NewVScroll(content), func(apply bool) {
+		if !apply {
+			return
+		}
+		listFilter.Formats = nil
+		for i, name := range formatNames {
+			if slices.Contains(checkFormats.Selected, name) {
+				listFilter.Formats = append(listFilter.Formats, formats[i])
+			}
+		}
+		listFilter.Statuses = nil
+		for i, name := range statusNames {
+			if slices.Contains(checkStatuses.Selected, name) {
+				listFilter.Statuses = append(listFilter.Statuses, statuses[i])
+			}
+		}
+		listFilter.Genres = nil
+		for _, genre := range genres {
+			if selectedGenres[genre] {
+				listFilter.Genres = append(listFilter.Genres, genre)
+			}
+		}
+		listFilter.Unwatched = unwatched.Checked
+		onApply()
+	}, window)
+	filterDialog.Resize(fyne.NewSize(600, 500))
+	filterDialog.Show()
+}
+
+// enumName turns an Anilist enum like NOT_YET_RELEASED into Not yet released
+func enumName(value string) string {
+	text := strings.ReplaceAll(value, "_", " ")
+	if text == "" {
+		return text
+	}
+	return text[:1] + strings.ToLower(text[1:])
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	curd "AnimeGUI/curdInteg"
+	"AnimeGUI/imagecache"
 	"AnimeGUI/src/anilist"
 	"AnimeGUI/verniy"
+	"context"
+	"flag"
 	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -20,9 +23,8 @@ import (
 	ttwidget "github.com/dweymouth/fyne-tooltip/widget"
 	"image"
 	"image/color"
-	"io"
-	"net/http"
 	"net/url"
+	"sync"
 	"time"
 )
 
@@ -33,14 +35,21 @@ var (
 	appW                fyne.App
 	episodeNumber       = widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
 	episodeLastPlayback = widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{})
+	episodeSkipped      = widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{Italic: true})
 	changedToken        bool
 	mpvPresent          bool
 	grayScaleList       uint8 = 35
+	playButton          *widget.Button
+	categoryRadio       *widget.RadioGroup
 )
 
 func main() {
 	const AppName = "AnimeGUI"
 
+	profile := curd.RegisterProfileFlag(flag.CommandLine)
+	flag.Parse()
+	currentProfile = *profile
+
 	go dowloadMPV()
 
 	appW = app.New()
@@ -48,7 +57,6 @@ func main() {
 	window.Resize(fyne.NewSize(1000, 700))
 	window.CenterOnScreen()
 
-	initMenuOption()
 	window.Show()
 
 	appW.Settings().SetTheme(&forcedVariant{
@@ -58,6 +66,10 @@ func main() {
 	//log.Info("Color", appW.Settings().Theme().Color(theme.ColorNameFocus, theme.VariantDark))
 
 	startCurdInteg()
+	initMenuOption()
+	if user.Token == "" {
+		setTokenGraphicaly(tokenFilePath(), &user)
+	}
 	if !changedToken {
 		fmt.Println(window.Title(), AppName)
 		initMainApp()
@@ -88,19 +100,24 @@ func updateAnimeNames(data binding.ExternalStringList) (first bool) {
 	return first
 }
 
-func GetImageFromUrl(url string) image.Image {
-	resp, err := http.Get(url)
-	if err != nil {
-		fmt.Println("Error downloading image:", err)
-		return nil
+// indexOfMedia returns the position of the anime in the list, 0 when it
+// isn't in it
+func indexOfMedia(list []verniy.MediaList, mediaID int) int {
+	for i, anime := range list {
+		if anime.Media != nil && anime.Media.ID == mediaID {
+			return i
+		}
 	}
-	defer func(Body io.ReadCloser) {
-		_ = Body.Close()
-	}(resp.Body)
+	return 0
+}
+
+func GetImageFromUrl(url string) image.Image {
+	ctx, cancel := context.WithTimeout(context.Background(), coverLoadTimeout)
+	defer cancel()
 
-	img, _, err := image.Decode(resp.Body)
+	img, err := imagecache.Default.Image(ctx, url)
 	if err != nil {
-		fmt.Println("Error decoding image:", err)
+		fmt.Println("Error loading image:", err)
 		return nil
 	}
 	return img
@@ -120,24 +137,84 @@ func getAnimeImageFromImage(img image.Image, newWidth float32) *canvas.Image {
 	return imageEx
 }
 
-func selectCorrectLinking(allAnimeList []AllAnimeIdData, animeName string, animeProgress int) {
+// selectCorrectLinking lets the user pick the provider show of the entry,
+// best scored first. A relink replaces the current mapping and keeps the
+// local progress instead of starting the playback.
+func selectCorrectLinking(entry *verniy.MediaList, allAnimeList []AllAnimeIdData, animeName string, animeProgress int, relink bool) {
+	current := SearchFromLocalAniId(entry.Media.ID)
+	currentLabel := widget.NewLabel("Not linked yet")
+	if current != nil {
+		currentLabel.SetText("Currently linked to " + current.AllanimeId)
+		for _, candidate := range allAnimeList {
+			if candidate.Id == current.AllanimeId {
+				currentLabel.SetText("Currently linked to " + candidate.Name)
+			}
+		}
+	}
+	currentLabel.Wrapping = fyne.TextWrapWord
+
+	// A search replaces the candidates while the list shows them
+	var candidatesMutex sync.Mutex
+	candidate := func(i int) AllAnimeIdData {
+		candidatesMutex.Lock()
+		defer candidatesMutex.Unlock()
+		return allAnimeList[i]
+	}
+
 	linkingList := widget.NewList(func() int {
+		candidatesMutex.Lock()
+		defer candidatesMutex.Unlock()
 		return len(allAnimeList)
 	},
 		func() fyne.CanvasObject {
 			return widget.NewLabel("template")
 		},
 		func(i widget.ListItemID, o fyne.CanvasObject) {
-			o.(*widget.Label).SetText(allAnimeList[i].Name)
+			o.(*widget.Label).SetText(linkLabel(candidate(i)))
 		})
 
-	linkingContainer := container.NewBorder(nil, nil, nil, nil, linkingList)
+	// The titles may be too different, another search can be typed
+	searchEntry := widget.NewEntry()
+	searchEntry.SetPlaceHolder("Search the provider")
+	searchEntry.OnSubmitted = func(s string) {
+		if s == "" {
+			return
+		}
+		go func() {
+			results, err := curd.SearchAnime(context.Background(), s, "sub")
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			ranked := rankLinks(entry.Media, results)
+			candidatesMutex.Lock()
+			allAnimeList = ranked
+			candidatesMutex.Unlock()
+			linkingList.UnselectAll()
+			linkingList.Refresh()
+		}()
+	}
+
+	linkingContainer := container.NewBorder(container.NewVBox(currentLabel, searchEntry), nil, nil, nil, linkingList)
 	dialogC := dialog.NewCustom("Select the correct anime", "Cancel", linkingContainer, window)
 
 	linkingList.OnSelected = func(index widget.ListItemID) {
-		fmt.Print("Selected:", allAnimeList[index])
+		selected := candidate(index)
+		fmt.Print("Selected:", selected)
 		dialogC.Hide()
-		err, tempAnime := curd.LocalUpdateAnime(databaseFile, animeSelected.Media.ID, allAnimeList[index].Id, animeProgress, 0, 0, animeName)
+		var err error
+		var tempAnime []curd.Anime
+		if relink && current != nil {
+			if current.AllanimeId == selected.Id {
+				return
+			}
+			linked := *current
+			linked.AllanimeId = selected.Id
+			curd.LocalDeleteAnime(databaseFile, current.AnilistId, current.AllanimeId)
+			err, tempAnime = curd.LocalSaveAnime(databaseFile, linked)
+		} else {
+			err, tempAnime = curd.LocalUpdateAnime(databaseFile, entry.Media.ID, selected.Id, animeProgress, 0, 0, animeName)
+		}
 		if err != nil {
 			log.Error("Can't update database file", err)
 			return
@@ -145,7 +222,9 @@ func selectCorrectLinking(allAnimeList []AllAnimeIdData, animeName string, anime
 		if tempAnime != nil {
 			localAnime = tempAnime
 		}
-		OnPlayButtonClick(animeName, animeSelected)
+		if !relink {
+			OnPlayButtonClick(animeName, entry)
+		}
 	}
 
 	dialogC.Resize(fyne.NewSize(600, 900))
@@ -159,7 +238,7 @@ func changeEpisodeInApp(variation int) {
 	newNumber := *currentSelected.Progress + variation
 	fmt.Println("New number:", newNumber, *currentSelected.Progress)
 	if newNumber >= 0 && newNumber <= *currentSelected.Media.Episodes {
-		go UpdateAnimeProgress(currentSelected.Media.ID, newNumber)
+		UpdateAnimeProgress(currentSelected.Media.ID, *currentSelected.Progress, newNumber)
 		currentSelected.Progress = &newNumber
 		episodeNumber.SetText(fmt.Sprintf("Episode %d/%d", newNumber, *currentSelected.Media.Episodes))
 	}
@@ -168,6 +247,8 @@ func changeEpisodeInApp(variation int) {
 func initMainApp() {
 	secondCurdInit()
 	anilist.Client.AccessToken = user.Token
+	startOutbox()
+	startAiringNotifier()
 	window.SetTitle("Benri")
 	fmt.Println(localAnime)
 
@@ -177,45 +258,69 @@ func initMainApp() {
 		&[]string{},
 	)
 
-	listDisplay := widget.NewListWithData(data,
+	// Rows of the anime being watched show how many aired episodes are left
+	listDisplay := widget.NewList(data.Length,
 		func() fyne.CanvasObject {
-			return &widget.Label{Text: "template"}
+			badge := &widget.Label{Importance: widget.SuccessImportance, TextStyle: fyne.TextStyle{Bold: true}}
+			name := &widget.Label{Text: "template", Truncation: fyne.TextTruncateEllipsis}
+			return container.NewBorder(nil, nil, nil, badge, name)
 		},
-		func(i binding.DataItem, o fyne.CanvasObject) {
-			o.(*widget.Label).Bind(i.(binding.String))
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			row := o.(*fyne.Container)
+			if item, err := data.GetItem(id); err == nil {
+				row.Objects[0].(*widget.Label).Bind(item.(binding.String))
+			}
+			badge := row.Objects[1].(*widget.Label)
+			if animeList == nil || id >= len(*animeList) {
+				badge.Hide()
+				return
+			}
+			if unwatched := unwatchedBadge((*animeList)[id]); unwatched != "" {
+				badge.SetText(unwatched)
+				badge.Show()
+			} else {
+				badge.Hide()
+			}
 		})
+	data.AddListener(binding.NewDataListener(listDisplay.Refresh))
 
 	input := widget.NewEntry()
 	input.SetPlaceHolder("Filter anime name")
 
-	radiobox := widget.NewRadioGroup([]string{"Watching", "Planning", "Completed", "Dropped"}, func(s string) {
-		if input.Text == "" {
-			animeList = anilist.FindList(s)
-		} else {
-			animeList = anilist.FindListWithQuery(s, input.Text)
+	// showList rebuilds the list from the category, the filters and the sort,
+	// the selected anime stays selected when it is still in it
+	var radiobox *widget.RadioGroup
+	showList := func(keepSelection bool) {
+		previousID := 0
+		if keepSelection && animeSelected != nil && animeSelected.Media != nil {
+			previousID = animeSelected.Media.ID
 		}
+		animeList = anilist.Query(radiobox.Selected, listFilter)
 		if updateAnimeNames(data) {
-			listDisplay.Unselect(0)
-			listDisplay.Select(0)
-			listDisplay.ScrollToTop()
+			index := indexOfMedia(*animeList, previousID)
+			listDisplay.Unselect(index)
+			listDisplay.Select(index)
+			if index == 0 {
+				listDisplay.ScrollToTop()
+			} else {
+				listDisplay.ScrollTo(index)
+			}
 		}
+	}
+	listControls, showSortOf := newListControls(func() string { return radiobox.Selected }, func() { showList(true) })
+
+	// Options are the categories of the list, set once it is loaded
+	radiobox = widget.NewRadioGroup(nil, func(s string) {
+		showSortOf(s)
+		showList(true)
 	})
 	radiobox.Required = true
 	radiobox.Horizontal = true
 
 	input.OnChanged = func(s string) {
 		debounced(func() {
-			fmt.Println("Search:", s)
-			if s == "" {
-				animeList = anilist.FindList(radiobox.Selected)
-			} else {
-				animeList = anilist.FindListWithQuery(radiobox.Selected, s)
-			}
-			if updateAnimeNames(data) {
-				listDisplay.Unselect(0)
-				listDisplay.Select(0)
-				listDisplay.ScrollToTop()
-			}
+			listFilter.Query = s
+			showList(false)
 		})
 	}
 
@@ -223,6 +328,8 @@ func initMainApp() {
 		widget.NewToolbarAction(theme.ContentAddIcon(), func() {
 			setDialogAddAnime()
 		}),
+		widget.NewToolbarAction(theme.GridIcon(), openSeasonalBrowser),
+		widget.NewToolbarAction(theme.HistoryIcon(), openAiringCalendar),
 		widget.NewToolbarSeparator(),
 		widget.NewToolbarAction(theme.MailAttachmentIcon(), func() {
 			if animeSelected == nil {
@@ -243,11 +350,12 @@ func initMainApp() {
 		}),
 	)
 
-	inputContainer := container.NewBorder(nil, nil, nil, toolbar, input)
+	inputContainer := container.NewBorder(nil, nil, newUserBox(), toolbar, input)
 
 	vbox := container.NewVBox(
 		inputContainer,
-		radiobox,
+		container.NewBorder(nil, nil, nil, newOfflineIndicator(), container.NewHScroll(radiobox)),
+		listControls,
 	)
 
 	/*if themeVariant == theme.VariantDark {
@@ -257,7 +365,8 @@ func initMainApp() {
 
 	leftSide := container.NewBorder(vbox, nil, nil, nil, listContainer)
 
-	go anilist.GetData(radiobox, user.Username, deleteTokenFile)
+	categoryRadio = radiobox
+	go loadAnilistData()
 
 	imageEx := &canvas.Image{}
 
@@ -267,14 +376,27 @@ func initMainApp() {
 	episodeMinus := widget.NewButton(" - ", func() { changeEpisodeInApp(-1) })
 	episodePlus := widget.NewButton(" + ", func() { changeEpisodeInApp(1) })
 
-	episodeContainer := container.NewHBox(layout.NewSpacer(), episodeMinus, episodeNumber, episodePlus, layout.NewSpacer())
+	episodeList := widget.NewButtonWithIcon("", theme.ListIcon(), openEpisodePicker)
+	moveEntry := ttwidget.NewButtonWithIcon("", theme.ContentRedoIcon(), openMoveDialog)
+	moveEntry.SetToolTip("Move to…")
+	editEntry := ttwidget.NewButtonWithIcon("", theme.DocumentCreateIcon(), openEntryEditor)
+	editEntry.SetToolTip("Edit entry")
+	relinkEntry := ttwidget.NewButtonWithIcon("", theme.MailAttachmentIcon(), openRelinkDialog)
+	relinkEntry.SetToolTip("Link to another stream")
+	franchise := ttwidget.NewButtonWithIcon("", theme.NavigateNextIcon(), openFranchiseDialog)
+	franchise.SetToolTip("Franchise and what to watch next")
+
+	episodeContainer := container.NewHBox(layout.NewSpacer(), episodeMinus, episodeNumber, episodePlus, episodeList, moveEntry, editEntry, relinkEntry, franchise, layout.NewSpacer())
 
 	//nextEpisodeLabel := widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
 	nextEpisodeLabel := &canvas.Text{Text: "", Color: color.RGBA{156, 190, 93, 255}, Alignment: fyne.TextAlignCenter, TextStyle: fyne.TextStyle{Bold: true}, TextSize: theme.TextSize()}
 	nextEpisodeLabel.Hide()
 
-	button := widget.NewButtonWithIcon("Play!", theme.MediaPlayIcon(), func() {
+	playButton = widget.NewButtonWithIcon("Play!", theme.MediaPlayIcon(), func() {
 		//fmt.Println(anilist.Search())
+		if cancelResolving() {
+			return
+		}
 		fmt.Println(animeSelected.Media.ID)
 		if animeName.Text == "" {
 			return
@@ -282,16 +404,37 @@ func initMainApp() {
 		OnPlayButtonClick(animeName.Text, animeSelected)
 	})
 
-	button.IconPlacement = widget.ButtonIconTrailingText
-	button.Importance = widget.HighImportance
+	playButton.IconPlacement = widget.ButtonIconTrailingText
+	playButton.Importance = widget.HighImportance
+
+	playContainer := container.NewHBox(layout.NewSpacer(), playButton, layout.NewSpacer())
 
-	playContainer := container.NewHBox(layout.NewSpacer(), button, layout.NewSpacer())
+	episodeSkipped.Wrapping = fyne.TextWrapWord
+	episodeSkipped.Hide()
 
-	imageContainer := container.NewVBox(imageEx, animeName, episodeContainer, nextEpisodeLabel, episodeLastPlayback, layout.NewSpacer(), playContainer)
+	imageContainer := container.NewVBox(imageEx, animeName, episodeContainer, nextEpisodeLabel, episodeLastPlayback, episodeSkipped, layout.NewSpacer(), playContainer)
+
+	// The details are only fetched while their tab is open
+	details := newDetailView()
+	detailsTab := container.NewTabItemWithIcon("Details", theme.InfoIcon(), details.scroll)
+	upNext := newUpNextView()
+	upNextTab := container.NewTabItemWithIcon("Up Next", theme.MediaFastForwardIcon(), upNext.content)
+	rightSide := container.NewAppTabs(container.NewTabItemWithIcon("Watch", theme.MediaPlayIcon(), imageContainer), detailsTab, upNextTab)
+	rightSide.OnSelected = func(tab *container.TabItem) {
+		if tab == upNextTab {
+			upNext.refresh()
+		}
+		if tab == detailsTab && animeSelected != nil && animeSelected.Media != nil && !details.isShowing(animeSelected.Media.ID) {
+			details.setMedia(animeSelected.Media.ID)
+		}
+	}
 
 	listDisplay.OnSelected = func(id int) {
 		listName, err := data.GetValue(id)
 		animeSelected = &(*animeList)[id]
+		if rightSide.Selected() == detailsTab {
+			details.setMedia(animeSelected.Media.ID)
+		}
 		if err == nil {
 			animeName.SetText(listName)
 			animeName.SetToolTip(anilist.AnimeToRomaji(animeSelected.Media))
@@ -311,18 +454,13 @@ func initMainApp() {
 			episodeNumber.SetText("No episode data")
 		}
 		displayLocalProgress()
+		displaySkippedEpisodes()
 
+		selected := animeSelected
 		imageLink := *animeSelected.Media.CoverImage.ExtraLarge
-
-		imageFile := GetImageFromUrl(imageLink)
-		if imageFile == nil {
-			log.Error("No image found")
-			return
-		}
-
-		*imageEx = *getAnimeImageFromImage(imageFile, 300)
-		imageContainer.Refresh()
+		loadCoverAsync(imageEx, imageContainer, imageLink, 300, func() bool { return animeSelected == selected })
+		prefetchNeighbourCovers(*animeList, id)
 	}
 
-	window.SetContent(fynetooltip.AddWindowToolTipLayer(container.NewBorder(nil, nil, nil, imageContainer, leftSide), window.Canvas()))
+	window.SetContent(fynetooltip.AddWindowToolTipLayer(container.NewBorder(nil, nil, nil, rightSide, leftSide), window.Canvas()))
 }
@@ -1,22 +1,69 @@
 package main
 
 import (
-	"fmt"
+	curd "AnimeGUI/curdInteg"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/log"
 )
 
 var dialogMenuOption *dialog.CustomDialog
 
 func initMenuOption() {
+	skipOpeningCheck := widget.NewCheck("", func(b bool) {
+		userCurdConfig.SkipOp = b
+		saveCurdConfig()
+	})
+	skipOpeningCheck.Checked = userCurdConfig.SkipOp
+
+	skipEndingCheck := widget.NewCheck("", func(b bool) {
+		userCurdConfig.SkipEd = b
+		saveCurdConfig()
+	})
+	skipEndingCheck.Checked = userCurdConfig.SkipEd
+
+	bingeCheck := widget.NewCheck("", func(b bool) {
+		userCurdConfig.BingeMode = b
+		saveCurdConfig()
+	})
+	bingeCheck.Checked = userCurdConfig.BingeMode
+
+	nextPromptCheck := widget.NewCheck("", func(b bool) {
+		userCurdConfig.NextEpisodePrompt = b
+		saveCurdConfig()
+	})
+	nextPromptCheck.Checked = userCurdConfig.NextEpisodePrompt
+
+	airingCheck := widget.NewCheck("", func(b bool) {
+		userCurdConfig.AiringNotifications = b
+		saveCurdConfig()
+	})
+	airingCheck.Checked = userCurdConfig.AiringNotifications
+
+	availableCheck := widget.NewCheck("", func(b bool) {
+		userCurdConfig.NotifyWhenAvailable = b
+		saveCurdConfig()
+	})
+	availableCheck.Checked = userCurdConfig.NotifyWhenAvailable
+
 	rowSkipOpening := container.New(layout.NewFormLayout(),
+		widget.NewLabelWithStyle("Profile", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		newProfileSwitcher(),
 		widget.NewLabelWithStyle("Automatically skip Opening", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		widget.NewCheck("", func(b bool) { fmt.Println(b) }),
+		skipOpeningCheck,
 		widget.NewLabelWithStyle("Automatically skip Ending", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		widget.NewCheck("", func(b bool) { fmt.Println(b) }),
+		skipEndingCheck,
+		widget.NewLabelWithStyle("Play next episode automatically", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		bingeCheck,
+		widget.NewLabelWithStyle("Ask before next episode", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		nextPromptCheck,
+		widget.NewLabelWithStyle("Notify when an episode airs", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		airingCheck,
+		widget.NewLabelWithStyle("Notify when it can be watched", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		availableCheck,
 	)
 	//form := container.New(layout.NewFormLayout(), rowSkipOpening)
 	menuOption := container.NewBorder(nil, nil, nil, nil, rowSkipOpening)
@@ -27,3 +74,12 @@ func initMenuOption() {
 func openMenuOption() {
 	dialogMenuOption.Show()
 }
+
+func saveCurdConfig() {
+	if configFilePath == "" {
+		return
+	}
+	if err := curd.SaveProfileConfig(configFilePath, currentProfile, userCurdConfig); err != nil {
+		log.Error(err)
+	}
+}
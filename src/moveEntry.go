@@ -0,0 +1,75 @@
+package main
+
+import (
+	"AnimeGUI/src/anilist"
+	"AnimeGUI/verniy"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"slices"
+)
+
+// openMoveDialog moves the selected anime to another status and picks the
+// custom lists it is in
+func openMoveDialog() {
+	if animeSelected == nil || animeSelected.Media == nil {
+		return
+	}
+	entry := animeSelected
+
+	statuses := make([]string, len(anilist.StatusOrder))
+	for i, status := range anilist.StatusOrder {
+		statuses[i] = anilist.StatusNames[status]
+	}
+	selectStatus := widget.NewRadioGroup(statuses, nil)
+	selectStatus.Required = true
+	currentStatus := ""
+	if entry.Status != nil {
+		currentStatus = anilist.StatusNames[*entry.Status]
+		selectStatus.Selected = currentStatus
+	}
+	items := []*widget.FormItem{widget.NewFormItem("Status", selectStatus)}
+
+	customLists := anilist.CustomListNames()
+	var currentLists []string
+	for _, name := range customLists {
+		if entry.CustomLists[name] {
+			currentLists = append(currentLists, name)
+		}
+	}
+	checkLists := widget.NewCheckGroup(customLists, nil)
+	checkLists.Selected = currentLists
+	if len(customLists) > 0 {
+		items = append(items, widget.NewFormItem("Custom lists", checkLists))
+	}
+
+	title := "Move to…"
+	if name := anilist.AnimeToName(entry.Media); name != nil {
+		title = "Move " + *name + " to…"
+	}
+	dialog.ShowForm(title, "Move", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		var status verniy.MediaListStatus
+		if selectStatus.Selected != currentStatus {
+			for _, s := range anilist.StatusOrder {
+				if anilist.StatusNames[s] == selectStatus.Selected {
+					status = s
+				}
+			}
+		}
+		var lists map[string]bool
+		if !slices.Equal(checkLists.Selected, currentLists) {
+			lists = make(map[string]bool, len(customLists))
+			for _, name := range customLists {
+				lists[name] = slices.Contains(checkLists.Selected, name)
+			}
+		}
+		if status == "" && lists == nil {
+			return
+		}
+		if err := anilist.MoveEntry(categoryRadio, entry.Media.ID, status, lists); err != nil {
+			dialog.ShowError(err, window)
+		}
+	}, window)
+}
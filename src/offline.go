@@ -0,0 +1,209 @@
+package main
+
+import (
+	"AnimeGUI/src/anilist"
+	"AnimeGUI/verniy"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const reconnectInterval = 30 * time.Second
+
+var (
+	reconnectMutex sync.Mutex
+	reconnecting   bool
+
+	offlineButton   *widget.Button
+	offlineListener = binding.NewDataListener(func() {
+		if offline, _ := anilist.Offline.Get(); offline {
+			startReconnecting()
+		}
+		refreshOfflineIndicator()
+	})
+)
+
+// startOutbox replaces the outbox with the one of the profile in use, the
+// changes left by the previous run are sent again
+func startOutbox() {
+	if anilist.Updates != nil {
+		anilist.Updates.Stop()
+	}
+	outbox, err := anilist.NewOutbox(filepath.Join(os.ExpandEnv(userCurdConfig.StoragePath), "anilist_outbox.json"), user.Token)
+	if err != nil {
+		log.Error(err)
+	}
+	outbox.OnChange = refreshOfflineIndicator
+	anilist.Updates = outbox
+	outbox.Start()
+}
+
+// loadAnilistData shows the list, going offline when Anilist can't be
+// reached
+func loadAnilistData() error {
+	owner := anilist.Owner{ID: user.Id, Username: user.Username, AvatarURL: user.AvatarURL}
+	err := anilist.GetData(categoryRadio, owner, deleteTokenFile)
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+func startReconnecting() {
+	reconnectMutex.Lock()
+	defer reconnectMutex.Unlock()
+	if reconnecting {
+		return
+	}
+	reconnecting = true
+	go reconnectLoop()
+}
+
+// reconnectLoop fetches the list until Anilist answers again. It doesn't
+// stop on Offline going back to false, a change sent by the outbox clears it
+// while the list shown still is the snapshot.
+func reconnectLoop() {
+	defer func() {
+		reconnectMutex.Lock()
+		reconnecting = false
+		reconnectMutex.Unlock()
+	}()
+	for {
+		time.Sleep(reconnectInterval)
+		if user.Token == "" {
+			return
+		}
+		if err := loadAnilistData(); err == nil || verniy.IsUnauthorized(err) {
+			return
+		}
+	}
+}
+
+// newOfflineIndicator is shown next to the categories while Anilist can't be
+// reached or changes are waiting to be sent, tapping it lists them
+func newOfflineIndicator() fyne.CanvasObject {
+	offlineButton = widget.NewButtonWithIcon("", theme.WarningIcon(), showOutboxDialog)
+	offlineButton.Importance = widget.LowImportance
+	offlineButton.Hide()
+	// The main view is rebuilt on profile switches, keep a single listener
+	anilist.Offline.RemoveListener(offlineListener)
+	anilist.Offline.AddListener(offlineListener)
+	refreshOfflineIndicator()
+	return offlineButton
+}
+
+func refreshOfflineIndicator() {
+	if offlineButton == nil {
+		return
+	}
+	offline, _ := anilist.Offline.Get()
+	var pending, failed int
+	if anilist.Updates != nil {
+		for _, m := range anilist.Updates.Pending() {
+			if m.Failed {
+				failed++
+			} else {
+				pending++
+			}
+		}
+	}
+	if !offline && pending == 0 && failed == 0 {
+		offlineButton.Hide()
+		return
+	}
+
+	var parts []string
+	if offline {
+		parts = append(parts, "Offline")
+	}
+	if pending > 0 {
+		parts = append(parts, fmt.Sprintf("%d pending", pending))
+	}
+	offlineButton.Importance = widget.LowImportance
+	if failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", failed))
+		offlineButton.Importance = widget.DangerImportance
+	}
+	offlineButton.SetText(strings.Join(parts, ", "))
+	offlineButton.Show()
+}
+
+// showOutboxDialog lists the changes not on Anilist yet, failed ones can be
+// retried or discarded
+func showOutboxDialog() {
+	rows := container.NewVBox()
+	var fill func()
+	fill = func() {
+		rows.RemoveAll()
+		var pending []anilist.Mutation
+		if anilist.Updates != nil {
+			pending = anilist.Updates.Pending()
+		}
+		if len(pending) == 0 {
+			rows.Add(widget.NewLabel("Everything is saved on Anilist"))
+		}
+		for _, m := range pending {
+			mediaID := m.MediaID
+			retry := widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), func() {
+				anilist.Updates.Retry(mediaID)
+				fill()
+			})
+			discard := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+				anilist.Updates.Discard(mediaID)
+				fill()
+			})
+			rows.Add(container.NewBorder(nil, nil, nil, container.NewHBox(retry, discard),
+				widget.NewLabelWithStyle(mutationTitle(m), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+				widget.NewLabel(mutationState(m))))
+		}
+	}
+	fill()
+
+	outboxDialog := dialog.NewCustom("Changes not on Anilist yet", "Close", container.NewVScroll(rows), window)
+	outboxDialog.Resize(fyne.NewSize(500, 400))
+	outboxDialog.Show()
+}
+
+func mutationTitle(m anilist.Mutation) string {
+	title := fmt.Sprintf("Anime %d", m.MediaID)
+	if entry := anilist.FindEntry(m.MediaID); entry != nil {
+		if name := anilist.AnimeToName(entry.Media); name != nil {
+			title = *name
+		}
+	}
+	var changes []string
+	if m.Progress != nil {
+		changes = append(changes, fmt.Sprintf("episode %d", *m.Progress))
+	}
+	if m.Status != "" {
+		changes = append(changes, strings.ToLower(anilist.StatusNames[m.Status]))
+	}
+	if m.CustomLists != nil {
+		changes = append(changes, "custom lists")
+	}
+	if m.EntryEdit != (anilist.EntryEdit{}) {
+		changes = append(changes, "entry details")
+	}
+	return fmt.Sprintf("%s: %s", title, strings.Join(changes, ", "))
+}
+
+func mutationState(m anilist.Mutation) string {
+	switch {
+	case m.Failed:
+		return "Failed: " + m.Error
+	case m.Error != "":
+		return fmt.Sprintf("Retrying at %s, %s", m.NextTry.Format("15:04:05"), m.Error)
+	default:
+		return "Waiting to be sent"
+	}
+}
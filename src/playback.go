@@ -0,0 +1,344 @@
+package main
+
+import (
+	curd "AnimeGUI/curdInteg"
+	"AnimeGUI/src/anilist"
+	"AnimeGUI/verniy"
+	"context"
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2/dialog"
+	"github.com/charmbracelet/log"
+	"sync/atomic"
+	"time"
+)
+
+// mpvArgs are the extra mpv arguments used for every episode. In binge mode
+// mpv stays open at the end of the file so the next one can be loaded in it.
+func mpvArgs() []string {
+	if userCurdConfig.BingeMode {
+		return []string{"--keep-open=yes"}
+	}
+	return []string{}
+}
+
+// playbackCount is the number of running playingAnimeLoop, the profile
+// can't change while one of them may still save progress
+var playbackCount atomic.Int32
+
+// playingAnimeLoop follows the playback in mpv and saves the progress at the
+// end of each episode. In binge mode it keeps going with the next episodes.
+func playingAnimeLoop(playingAnime curd.Anime, animeData *verniy.MediaList) {
+	playbackCount.Add(1)
+	go func() {
+		defer playbackCount.Add(-1)
+		ctx := context.Background()
+		client, err := dialPlayingMPV(ctx, playingAnime.Ep.Player.SocketPath)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		defer func() { client.Close() }()
+		restoreSpeed(ctx, client, &playingAnime)
+
+		for {
+			mpvClosed := watchEpisode(ctx, client, &playingAnime)
+			completed := saveEpisodeProgress(&playingAnime, animeData)
+
+			next, err := nextBingeEpisode(completed, &playingAnime, animeData)
+			if err != nil {
+				log.Info(err)
+				if !mpvClosed {
+					client.Command(ctx, "quit")
+				}
+				return
+			}
+
+			title := fmt.Sprintf("%s - Episode %d", playingAnime.Title.English, next.number)
+			if mpvClosed {
+				// The user closed mpv, the next episode needs a new one
+				client.Close()
+				socketPath, err := curd.StartVideo(next.link, mpvArgs(), title)
+				if err != nil {
+					log.Error(err)
+					return
+				}
+				playingAnime.Ep.Player.SocketPath = socketPath
+				client, err = dialPlayingMPV(ctx, socketPath)
+				if err != nil {
+					log.Error(err)
+					return
+				}
+				restoreSpeed(ctx, client, &playingAnime)
+			} else {
+				if err := client.SetProperty(ctx, "force-media-title", title); err != nil {
+					log.Error(err)
+				}
+				if _, err := client.Command(ctx, "loadfile", next.link, "replace"); err != nil {
+					log.Error("Error loading next episode: " + err.Error())
+					return
+				}
+			}
+
+			playingAnime.Ep.Duration = 0
+			playingAnime.Ep.Player.PlaybackTime = 0
+			playingAnime.Ep.SkipTimes = curd.SkipTimes{}
+		}
+	}()
+}
+
+func dialPlayingMPV(ctx context.Context, socketPath string) (*curd.MPVClient, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	client, err := curd.DialMPV(dialCtx, socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.Observe(ctx, curd.MPVDuration, curd.MPVTimePos, curd.MPVSpeed, curd.MPVEOFReached)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// watchEpisode follows one episode until mpv quits or, with keep-open, the
+// end of the file is reached. It reports whether mpv was closed.
+func watchEpisode(ctx context.Context, client *curd.MPVClient, playingAnime *curd.Anime) (mpvClosed bool) {
+	skipTimesChan := make(chan curd.SkipTimes, 1)
+	// Resuming an episode reuses the skip times saved with it
+	if (userCurdConfig.SkipOp || userCurdConfig.SkipEd) && playingAnime.Ep.SkipTimes == (curd.SkipTimes{}) {
+		go func(episode int) {
+			skipTimes, err := fetchSkipTimes(playingAnime.AnilistId, episode)
+			if err != nil {
+				log.Error("Error getting skip times: " + err.Error())
+				return
+			}
+			skipTimesChan <- skipTimes
+		}(playingAnime.Ep.Number + 1)
+	}
+	var skippedOp, skippedEd bool
+
+	// Events is closed when mpv quits
+	events := client.Events()
+	for {
+		var event curd.MPVEvent
+		var ok bool
+		select {
+		case playingAnime.Ep.SkipTimes = <-skipTimesChan:
+			log.Info("Skip times:", playingAnime.Ep.SkipTimes)
+			continue
+		case event, ok = <-events:
+			if !ok {
+				return true
+			}
+		}
+
+		if event.Unavailable {
+			continue
+		}
+		switch event.Property {
+		case curd.MPVDuration:
+			if playingAnime.Ep.Duration != 0 || event.Float <= 0 {
+				continue
+			}
+			playingAnime.Ep.Duration = int(event.Float + 0.5) // Round to nearest integer
+			log.Infof("Video duration: %d seconds", playingAnime.Ep.Duration)
+
+			if playingAnime.Ep.Player.PlaybackTime > 10 {
+				err := client.Seek(ctx, max(0, playingAnime.Ep.Player.PlaybackTime-5))
+				if err != nil {
+					log.Error("Error seeking video: " + err.Error())
+				}
+			}
+		case curd.MPVTimePos:
+			// Ignore positions until the resume seek happened
+			if playingAnime.Ep.Duration == 0 {
+				continue
+			}
+			playingAnime.Ep.Player.PlaybackTime = int(event.Float + 0.5)
+
+			skipTimes := playingAnime.Ep.SkipTimes
+			if userCurdConfig.SkipOp && !skippedOp && inSkipInterval(playingAnime.Ep.Player.PlaybackTime, skipTimes.Op) {
+				skippedOp = true
+				if err := client.Seek(ctx, skipTimes.Op.End); err != nil {
+					log.Error("Error skipping opening: " + err.Error())
+				}
+			}
+			if userCurdConfig.SkipEd && !skippedEd && inSkipInterval(playingAnime.Ep.Player.PlaybackTime, skipTimes.Ed) {
+				skippedEd = true
+				if err := client.Seek(ctx, skipTimes.Ed.End); err != nil {
+					log.Error("Error skipping ending: " + err.Error())
+				}
+			}
+		case curd.MPVSpeed:
+			playingAnime.Ep.Player.Speed = event.Float
+		case curd.MPVEOFReached:
+			if event.Bool && playingAnime.Ep.Duration != 0 {
+				playingAnime.Ep.Player.PlaybackTime = playingAnime.Ep.Duration
+				return false
+			}
+		}
+	}
+}
+
+// saveEpisodeProgress updates Anilist and the local history once an episode
+// stopped playing and reports whether it counted as watched.
+func saveEpisodeProgress(playingAnime *curd.Anime, animeData *verniy.MediaList) (completed bool) {
+	percentageWatched := curd.PercentageWatched(playingAnime.Ep.Player.PlaybackTime, playingAnime.Ep.Duration)
+
+	if int(percentageWatched) >= userCurdConfig.PercentageToMarkComplete {
+		completed = true
+		from := 0
+		if animeData.Progress != nil {
+			from = *animeData.Progress
+		}
+		playingAnime.Ep.Number++
+		playingAnime.Ep.Player.PlaybackTime = 0
+		// Replaying an older episode only moves the local resume position,
+		// Anilist never goes backwards
+		if playingAnime.Ep.Number > from {
+			var newProgress int = playingAnime.Ep.Number
+			animeData.Progress = &newProgress
+			UpdateAnimeProgress(playingAnime.AnilistId, from, playingAnime.Ep.Number)
+			episodeNumber.SetText(fmt.Sprintf("Episode %d/%d", playingAnime.Ep.Number, playingAnime.TotalEpisodes))
+		}
+	}
+
+	err, tempAnime := curd.LocalSaveAnime(databaseFile, playbackRecord(*playingAnime, completed))
+	if err == nil && tempAnime != nil {
+		log.Info("Successfully updated database file")
+		localAnime = curd.LocalGetAllAnime(databaseFile)
+	}
+	displayLocalProgress()
+	return completed
+}
+
+// playbackRecord is the history entry of the anime once an episode stopped.
+// The skip times belong to the episode played, they are kept to resume it
+// only.
+func playbackRecord(playingAnime curd.Anime, completed bool) curd.Anime {
+	record := curd.Anime{
+		AnilistId:  playingAnime.AnilistId,
+		AllanimeId: playingAnime.AllanimeId,
+		Title:      playingAnime.Title,
+		SubOrDub:   userCurdConfig.SubOrDub,
+	}
+	record.Ep.Number = playingAnime.Ep.Number
+	record.Ep.Player.PlaybackTime = playingAnime.Ep.Player.PlaybackTime
+	if !completed {
+		record.Ep.Duration = playingAnime.Ep.Duration
+		record.Ep.SkipTimes = playingAnime.Ep.SkipTimes
+	}
+	if userCurdConfig.SaveMpvSpeed {
+		record.Ep.Player.Speed = playingAnime.Ep.Player.Speed
+	}
+	return record
+}
+
+// restoreSpeed sets the speed saved for the anime in the new mpv
+func restoreSpeed(ctx context.Context, client *curd.MPVClient, playingAnime *curd.Anime) {
+	speed := playingAnime.Ep.Player.Speed
+	if !userCurdConfig.SaveMpvSpeed || speed <= 0 || speed == 1 {
+		return
+	}
+	if err := client.SetProperty(ctx, string(curd.MPVSpeed), speed); err != nil {
+		log.Error("Error restoring the speed: " + err.Error())
+	}
+}
+
+type bingeEpisode struct {
+	number int
+	link   string
+}
+
+// nextBingeEpisode decides if the next episode should be played and resolves
+// its link. The error explains why binge stops.
+func nextBingeEpisode(completed bool, playingAnime *curd.Anime, animeData *verniy.MediaList) (bingeEpisode, error) {
+	if !userCurdConfig.BingeMode {
+		return bingeEpisode{}, errors.New("binge mode is off")
+	}
+	if !completed {
+		return bingeEpisode{}, errors.New("episode was not finished, stopping binge")
+	}
+
+	next := bingeEpisode{number: playingAnime.Ep.Number + 1}
+	number, err := resolveStep(func(ctx context.Context) (int, error) {
+		return skipFlaggedEpisodes(ctx, animeData, next.number)
+	})
+	if errors.Is(err, context.Canceled) || errors.Is(err, errAlreadyResolving) {
+		return bingeEpisode{}, err
+	} else if err != nil {
+		log.Error("Error checking filler episodes: " + err.Error())
+	}
+	next.number = number
+	playingAnime.Ep.Number = next.number - 1
+
+	if last := anilist.AiredEpisodes(animeData.Media); last > 0 && next.number > last {
+		return bingeEpisode{}, fmt.Errorf("episode %d is not available yet, stopping binge", next.number)
+	}
+
+	// The question is asked outside of a resolve, the user can take their
+	// time and play something else meanwhile
+	if userCurdConfig.NextEpisodePrompt && !confirmNextEpisode(playingAnime.Title.English, next.number) {
+		return bingeEpisode{}, errors.New("next episode declined")
+	}
+
+	ctx, ok := beginResolving()
+	if !ok {
+		return bingeEpisode{}, errAlreadyResolving
+	}
+	defer endResolving()
+
+	links, err := curd.GetEpisodeURLWithFallback(ctx, userCurdConfig, playingAnime.AllanimeId, anilist.AnimeToRomaji(animeData.Media), next.number)
+	if err != nil {
+		return bingeEpisode{}, err
+	}
+	next.link = curd.PrioritizeLink(links)
+	if len(next.link) < 5 {
+		return bingeEpisode{}, errors.New("no valid link found")
+	}
+	return next, nil
+}
+
+var errAlreadyResolving = errors.New("another episode is being resolved")
+
+// resolveStep runs a network step of binge mode as a cancellable resolve
+func resolveStep(step func(ctx context.Context) (int, error)) (int, error) {
+	ctx, ok := beginResolving()
+	if !ok {
+		return 0, errAlreadyResolving
+	}
+	defer endResolving()
+	return step(ctx)
+}
+
+// confirmNextEpisode asks the user before launching the next episode and
+// blocks until they answer
+func confirmNextEpisode(animeName string, episode int) bool {
+	answer := make(chan bool, 1)
+	dialog.ShowConfirm("Next episode", fmt.Sprintf("Play %s episode %d?", animeName, episode), func(b bool) {
+		answer <- b
+	}, window)
+	return <-answer
+}
+
+// fetchSkipTimes gets the opening and ending intervals of an episode from AniSkip
+func fetchSkipTimes(anilistId int, episode int) (curd.SkipTimes, error) {
+	ctx := context.Background()
+	malId, err := curd.GetAnimeMalID(ctx, anilistId)
+	if err != nil {
+		return curd.SkipTimes{}, err
+	}
+
+	var anime curd.Anime
+	err = curd.GetAndParseAniSkipData(ctx, malId, episode, 1, &anime)
+	return anime.Ep.SkipTimes, err
+}
+
+// inSkipInterval is true while the position is in the interval, the last
+// second is left out so seeking to the end doesn't trigger it again
+func inSkipInterval(position int, skip curd.Skip) bool {
+	return skip.Start != skip.End && position >= skip.Start && position < skip.End-1
+}
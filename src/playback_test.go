@@ -0,0 +1,49 @@
+package main
+
+import (
+	curd "AnimeGUI/curdInteg"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlaybackRecordRoundTrip(t *testing.T) {
+	previous := userCurdConfig
+	userCurdConfig = curd.CurdConfig{SaveMpvSpeed: true, SubOrDub: "dub"}
+	t.Cleanup(func() { userCurdConfig = previous })
+
+	playing := curd.Anime{AnilistId: 1, AllanimeId: "a", Title: curd.AnimeTitle{English: "Show"}}
+	playing.Ep.Number = 3
+	playing.Ep.Duration = 1420
+	playing.Ep.Player.PlaybackTime = 600
+	playing.Ep.Player.Speed = 1.5
+	playing.Ep.Player.SocketPath = "/tmp/mpv"
+	playing.Ep.SkipTimes = curd.SkipTimes{Op: curd.Skip{Start: 10, End: 100}}
+
+	databaseFile := filepath.Join(t.TempDir(), "history.json")
+	if err, _ := curd.LocalSaveAnime(databaseFile, playbackRecord(playing, false)); err != nil {
+		t.Fatal(err)
+	}
+	saved := curd.LocalFindAnime(curd.LocalGetAllAnime(databaseFile), 1, "a")
+	if saved == nil {
+		t.Fatal("entry not saved")
+	}
+	if saved.Ep.Number != 3 || saved.Ep.Player.PlaybackTime != 600 || saved.Ep.Player.Speed != 1.5 ||
+		saved.Ep.SkipTimes != playing.Ep.SkipTimes || saved.SubOrDub != "dub" || saved.Ep.Duration != 1420 {
+		t.Errorf("saved as %+v", saved)
+	}
+
+	// Finished, the next episode starts without the skip times of this one
+	playing.Ep.Number, playing.Ep.Player.PlaybackTime = 4, 0
+	if err, _ := curd.LocalSaveAnime(databaseFile, playbackRecord(playing, true)); err != nil {
+		t.Fatal(err)
+	}
+	saved = curd.LocalFindAnime(curd.LocalGetAllAnime(databaseFile), 1, "a")
+	if saved.Ep.Number != 4 || saved.Ep.SkipTimes != (curd.SkipTimes{}) || saved.Ep.Player.Speed != 1.5 {
+		t.Errorf("saved as %+v", saved)
+	}
+
+	userCurdConfig.SaveMpvSpeed = false
+	if record := playbackRecord(playing, true); record.Ep.Player.Speed != 0 {
+		t.Errorf("speed kept with SaveMpvSpeed off: %v", record.Ep.Player.Speed)
+	}
+}
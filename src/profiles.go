@@ -0,0 +1,107 @@
+package main
+
+import (
+	curd "AnimeGUI/curdInteg"
+	"AnimeGUI/src/anilist"
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/log"
+)
+
+// newProfileSwitcher lists the profiles, picking one switches to it
+func newProfileSwitcher() fyne.CanvasObject {
+	profiles, err := curd.ListProfiles(configFilePath)
+	if err != nil {
+		log.Error(err)
+	}
+
+	selectProfile := widget.NewSelect(profiles, nil)
+	selectProfile.Selected = currentProfile
+	selectProfile.OnChanged = func(profile string) {
+		if profile == currentProfile {
+			return
+		}
+		if err := switchProfile(profile); err != nil {
+			selectProfile.Selected = currentProfile
+			selectProfile.Refresh()
+			dialog.ShowError(err, window)
+		}
+	}
+
+	newProfile := widget.NewButtonWithIcon("", theme.ContentAddIcon(), openNewProfileDialog)
+	return container.NewBorder(nil, nil, nil, newProfile, selectProfile)
+}
+
+func openNewProfileDialog() {
+	name := widget.NewEntry()
+	name.SetPlaceHolder("Name")
+	name.Validator = func(s string) error {
+		if !curd.ValidProfileName(s) || s == curd.DefaultProfile {
+			return errors.New("letters, digits, - and _ only")
+		}
+		return nil
+	}
+
+	dialog.ShowForm("New profile", "Create", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Profile", name),
+	}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		if err := curd.CreateProfile(configFilePath, name.Text); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		if err := switchProfile(name.Text); err != nil {
+			dialog.ShowError(err, window)
+		}
+	}, window)
+}
+
+// switchProfile reloads everything tied to the account without restarting:
+// config, token, history and the Anilist lists
+func switchProfile(profile string) error {
+	if playbackCount.Load() > 0 {
+		return errors.New("close the player before switching profile")
+	}
+	cancelResolving()
+
+	// Nothing changed yet when the profile can't be loaded
+	if err := loadProfile(profile); err != nil {
+		return fmt.Errorf("can't load profile %s: %w", profile, err)
+	}
+	currentProfile = profile
+	if anilist.Updates != nil {
+		// Its changes stay on disk, sent when the profile is used again
+		anilist.Updates.Stop()
+		anilist.Updates = nil
+	}
+	if err := curd.SetActiveProfile(configFilePath, profile); err != nil {
+		log.Error(err)
+	}
+	// Saving the default profile config writes it back
+	userCurdConfig.Profile = profile
+
+	animeSelected = nil
+	animeList = nil
+	localAnime = nil
+	anilist.UserData = nil
+	anilist.ScoreFormat = ""
+
+	if dialogMenuOption != nil {
+		dialogMenuOption.Hide()
+	}
+	initMenuOption()
+
+	if user.Token == "" {
+		showLoginScreen(tokenFilePath(), &user)
+		return nil
+	}
+	initMainApp()
+	return nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"AnimeGUI/src/anilist"
+	"AnimeGUI/verniy"
+	"context"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/charmbracelet/log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	seasonalCoverWidth = 130
+	seasonalTimeout    = 30 * time.Second
+)
+
+var seasonalFormats = []verniy.MediaFormat{
+	verniy.MediaFormatTv,
+	verniy.MediaFormatTvShort,
+	verniy.MediaFormatMovie,
+	verniy.MediaFormatOVA,
+	verniy.MediaFormatONA,
+	verniy.MediaFormatSpecial,
+}
+
+var seasonalSorts = map[string]verniy.MediaSort{
+	"Popularity": verniy.MediaSortPopularityDesc,
+	"Score":      verniy.MediaSortScoreDesc,
+	"Trending":   verniy.MediaSortTrendingDesc,
+}
+
+// openSeasonalBrowser shows the anime of the previous, current and next
+// seasons as covers, the selected one is added like in the add dialog
+func openSeasonalBrowser() {
+	current := anilist.SeasonOf(time.Now())
+	seasons := []anilist.Season{current.Previous(), current, current.Next()}
+	seasonNames := make([]string, len(seasons))
+	for i, season := range seasons {
+		seasonNames[i] = season.String()
+	}
+
+	var shows []verniy.Media
+	var selectedAnime *verniy.Media
+	query := anilist.SeasonalQuery{Season: current, Sort: verniy.MediaSortPopularityDesc}
+
+	// coverURLs remembers the cover each cell shows, a cell reused for
+	// another anime must not get the cover still loading for the previous one
+	var coverMutex sync.Mutex
+	coverURLs := make(map[*canvas.Image]string)
+
+	grid := widget.NewGridWrap(func() int { return len(shows) },
+		func() fyne.CanvasObject {
+			cover := &canvas.Image{}
+			cover.SetMinSize(fyne.NewSize(seasonalCoverWidth, seasonalCoverWidth*1.45))
+			title := widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+			title.Truncation = fyne.TextTruncateEllipsis
+			info := widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{})
+			info.Truncation = fyne.TextTruncateEllipsis
+			badge := widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{Italic: true})
+			return container.NewVBox(container.NewCenter(cover), title, info, badge)
+		},
+		func(id widget.GridWrapItemID, o fyne.CanvasObject) {
+			if id >= len(shows) {
+				return
+			}
+			media := &shows[id]
+			cell := o.(*fyne.Container)
+			coverBox := cell.Objects[0].(*fyne.Container)
+			cover := coverBox.Objects[0].(*canvas.Image)
+			cell.Objects[1].(*widget.Label).SetText(franchiseTitle(media))
+			cell.Objects[2].(*widget.Label).SetText(seasonalInfo(media))
+			badge := cell.Objects[3].(*widget.Label)
+			if status := anilist.ListStatus(media.ID); status != "" {
+				badge.SetText("On list: " + anilist.StatusNames[status])
+				badge.Show()
+			} else {
+				badge.Hide()
+			}
+
+			if media.CoverImage == nil || media.CoverImage.Large == nil {
+				return
+			}
+			url := *media.CoverImage.Large
+			coverMutex.Lock()
+			unchanged := coverURLs[cover] == url
+			coverURLs[cover] = url
+			coverMutex.Unlock()
+			if unchanged {
+				return
+			}
+			loadCoverAsync(cover, coverBox, url, seasonalCoverWidth, func() bool {
+				coverMutex.Lock()
+				defer coverMutex.Unlock()
+				return coverURLs[cover] == url
+			})
+		})
+
+	status := widget.NewLabel("")
+	selectCategory := widget.NewSelect(displayCategories, nil)
+	selectCategory.SetSelected(displayCategories[0])
+	selectedName := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	selectedName.Truncation = fyne.TextTruncateEllipsis
+	addButton := &widget.Button{Text: "Add", Icon: theme.ConfirmIcon(), Importance: widget.HighImportance}
+	addBar := container.NewBorder(nil, nil, nil, container.NewHBox(widget.NewLabel("Adding to category:"), selectCategory, addButton), selectedName)
+	addBar.Hide()
+
+	addButton.OnTapped = func() {
+		if selectedAnime == nil {
+			return
+		}
+		err := anilist.UpdateAnimeStatus(selectedAnime.ID, displayToCategories[selectCategory.Selected])
+		if err != nil {
+			log.Error("Error updating anime status:", err)
+			dialog.ShowError(err, window)
+			return
+		}
+		log.Info("Anime queued for Anilist")
+		grid.Refresh()
+	}
+	grid.OnSelected = func(id widget.GridWrapItemID) {
+		if id >= len(shows) {
+			return
+		}
+		selectedAnime = &shows[id]
+		selectedName.SetText(franchiseTitle(selectedAnime))
+		addBar.Show()
+	}
+
+	// load fetches the season again, the answers of older queries are dropped
+	var loadMutex sync.Mutex
+	var loading int
+	load := func() {
+		loadMutex.Lock()
+		loading++
+		request := loading
+		loadMutex.Unlock()
+		q := query
+		status.SetText("Loading " + q.Season.String() + "…")
+		grid.UnselectAll()
+		selectedAnime = nil
+		addBar.Hide()
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), seasonalTimeout)
+			defer cancel()
+			result, err := anilist.SeasonalAnime(ctx, q)
+			loadMutex.Lock()
+			defer loadMutex.Unlock()
+			if request != loading {
+				return
+			}
+			if err != nil {
+				status.SetText("Can't load the season: " + err.Error())
+				return
+			}
+			shows = result
+			status.SetText(fmt.Sprintf("%d anime", len(shows)))
+			grid.Refresh()
+			grid.ScrollToTop()
+		}()
+	}
+
+	selectSeason := widget.NewSelect(seasonNames, func(name string) {
+		for _, season := range seasons {
+			if season.String() == name {
+				query.Season = season
+			}
+		}
+		load()
+	})
+
+	formatNames := make([]string, len(seasonalFormats))
+	for i, format := range seasonalFormats {
+		formatNames[i] = strings.ReplaceAll(string(format), "_", " ")
+	}
+	checkFormats := widget.NewCheckGroup(formatNames, func(selected []string) {
+		query.Formats = nil
+		for i, name := range formatNames {
+			for _, s := range selected {
+				if s == name {
+					query.Formats = append(query.Formats, seasonalFormats[i])
+				}
+			}
+		}
+		load()
+	})
+	checkFormats.Horizontal = true
+
+	const anyGenre = "Any genre"
+	selectGenre := widget.NewSelect([]string{anyGenre}, func(genre string) {
+		query.Genre = genre
+		if genre == anyGenre {
+			query.Genre = ""
+		}
+		load()
+	})
+	selectGenre.PlaceHolder = anyGenre
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), seasonalTimeout)
+		defer cancel()
+		genres, err := anilist.Genres(ctx)
+		if err != nil {
+			log.Error("Can't load the genres", err)
+			return
+		}
+		selectGenre.Options = append([]string{anyGenre}, genres...)
+		selectGenre.Refresh()
+	}()
+
+	selectSort := widget.NewSelect([]string{"Popularity", "Score", "Trending"}, func(name string) {
+		query.Sort = seasonalSorts[name]
+		load()
+	})
+	selectSort.Selected = "Popularity"
+
+	filters := container.NewVBox(
+		container.NewHBox(selectSeason, selectGenre, widget.NewLabel("Sort by"), selectSort, status),
+		checkFormats,
+	)
+
+	browser := dialog.NewCustomWithoutButtons("Seasonal anime", container.NewBorder(filters, addBar, nil, nil, grid), window)
+	browser.SetButtons([]fyne.CanvasObject{&widget.Button{Text: "Close", Icon: theme.CancelIcon(), OnTapped: browser.Hide}})
+	browser.Resize(fyne.NewSize(1000, 720))
+	browser.Show()
+
+	// Selecting the current season loads it
+	selectSeason.SetSelected(current.String())
+}
+
+// seasonalInfo is the format, episode count and score of a cover
+func seasonalInfo(media *verniy.Media) string {
+	var info []string
+	if media.Format != nil {
+		info = append(info, strings.ReplaceAll(string(*media.Format), "_", " "))
+	}
+	if media.Episodes != nil {
+		info = append(info, fmt.Sprintf("%d ep", *media.Episodes))
+	}
+	if media.AverageScore != nil {
+		info = append(info, fmt.Sprintf("%d%%", *media.AverageScore))
+	}
+	return strings.Join(info, " · ")
+}
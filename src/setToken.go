@@ -2,57 +2,219 @@ package main
 
 import (
 	curd "AnimeGUI/curdInteg"
+	"AnimeGUI/imagecache"
+	"AnimeGUI/verniy"
+	"context"
+	"errors"
 	"fmt"
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/charmbracelet/log"
-	"net/url"
+	ttwidget "github.com/dweymouth/fyne-tooltip/widget"
 	"strings"
+	"sync"
+	"time"
+)
+
+// loginTimeout is how long the login listener waits for the browser
+const loginTimeout = 5 * time.Minute
+
+var (
+	loginMutex  sync.Mutex
+	cancelLogin context.CancelFunc
 )
 
 func setTokenGraphicaly(tokenPath string, user *curd.User) {
 	changedToken = true
+	showLoginScreen(tokenPath, user)
+	window.Show()
+	appW.Run()
+}
+
+// showLoginScreen replaces the window content with the login, the main
+// window is built again once logged in
+func showLoginScreen(tokenPath string, user *curd.User) {
 	fmt.Println(tokenPath, "Token path")
 
-	window.SetTitle("Set Anilist Token")
-	//window.Resize(fyne.NewSize(700, 400))
+	window.SetTitle("Log in to Anilist")
 
-	/*window.SetOnClosed(func() {
+	labelTitle := widget.NewLabelWithStyle("Log in with your Anilist account", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	status := widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{})
+	status.Wrapping = fyne.TextWrapWord
+	progress := widget.NewProgressBarInfinite()
+	progress.Hide()
 
-	})*/
+	passphraseLabel := widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	passphraseLabel.Wrapping = fyne.TextWrapWord
+	passphrase := widget.NewPasswordEntry()
+	passphrase.SetPlaceHolder("Passphrase")
+	passphraseButton := widget.NewButton("OK", nil)
+	passphraseBox := container.NewVBox(passphraseLabel, container.NewBorder(nil, nil, nil, passphraseButton, passphrase))
+	passphraseBox.Hide()
 
-	urlLink, _ := url.Parse("https://anilist.co/api/v2/oauth/authorize?client_id=23782&response_type=token")
-	err := appW.OpenURL(urlLink)
-	if err != nil {
-		log.Error("Error opening url", err)
+	// askPassphrase shows the passphrase entry, retry runs once it's given
+	askPassphrase := func(message string, retry func()) {
+		submit := func() {
+			if passphrase.Text == "" {
+				return
+			}
+			curd.SetTokenPassphrase(passphrase.Text)
+			passphrase.SetText("")
+			passphraseBox.Hide()
+			go retry()
+		}
+		passphraseLabel.SetText(message)
+		passphrase.OnSubmitted = func(string) { submit() }
+		passphraseButton.OnTapped = submit
+		passphraseBox.Show()
 	}
-	hyperlink := widget.NewHyperlink("Website to generate token", urlLink)
-	hyperlink.Alignment = fyne.TextAlignCenter
 
-	labelTitle := widget.NewLabelWithStyle("Generate a token and paste it below", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	var complete func(token curd.OAuthToken)
+	complete = func(token curd.OAuthToken) {
+		err := finishLogin(token, tokenPath, user, status)
+		if errors.Is(err, curd.ErrPassphraseNeeded) {
+			askPassphrase("No keyring found, choose a passphrase to encrypt the token", func() { complete(token) })
+			return
+		}
+		if err == nil {
+			initMainApp()
+		}
+	}
+
+	var unlockSaved func()
+	unlockSaved = func() {
+		token, err := curd.GetTokenFromFile(tokenPath)
+		if err != nil {
+			log.Error(err)
+			status.SetText(fmt.Sprint("Can't unlock the saved login: ", err))
+			if errors.Is(err, curd.ErrWrongPassphrase) {
+				askPassphrase("Your saved login is encrypted, enter its passphrase", unlockSaved)
+			}
+			return
+		}
+		complete(curd.OAuthToken{AccessToken: token, ExpiresAt: user.TokenExpiresAt})
+	}
+	if tokenLocked {
+		askPassphrase("Your saved login is encrypted, enter its passphrase", unlockSaved)
+	}
 
 	input := widget.NewEntry()
 	input.SetPlaceHolder("Anilist token")
-	input.OnSubmitted = func(s string) {
-		fmt.Println(s)
-		if changeTokenCustom(s, tokenPath, user) {
-			initMainApp()
+	submitToken := func(s string) {
+		token := processToken(s)
+		if token == "" {
+			status.SetText("This is not an Anilist token")
+			return
 		}
+		go complete(curd.OAuthToken{AccessToken: token})
 	}
+	input.OnSubmitted = submitToken
+	validateButton := widget.NewButton("Validate", func() { submitToken(input.Text) })
+	pasteEntry := container.NewBorder(nil, nil, nil, validateButton, input)
 
-	validateButton := widget.NewButton("Validate", func() {
-		if changeTokenCustom(input.Text, tokenPath, user) {
-			initMainApp()
+	profileRow := container.NewHBox(layout.NewSpacer(), widget.NewLabel("Profile"), newProfileSwitcher(), layout.NewSpacer())
+
+	urlLink, err := curd.PasteLoginURL(&userCurdConfig)
+	if err != nil {
+		log.Error(err)
+		status.SetText(err.Error())
+	}
+
+	// The default client only shows the token, it is pasted back here
+	if !curd.LoopbackAvailable(&userCurdConfig) {
+		loginButton := widget.NewButtonWithIcon("Log in", theme.LoginIcon(), func() {
+			if urlLink == nil {
+				return
+			}
+			if err := appW.OpenURL(urlLink); err != nil {
+				log.Error("Can't open the login page", err)
+				status.SetText(fmt.Sprint("Can't open the browser, go to ", urlLink))
+				return
+			}
+			status.SetText("Copy the token shown by Anilist and paste it below")
+			window.Canvas().Focus(input)
+		})
+		loginButton.Importance = widget.HighImportance
+		centerBtnContainer := container.NewHBox(layout.NewSpacer(), loginButton, layout.NewSpacer())
+
+		window.SetContent(container.NewVBox(labelTitle, profileRow, passphraseBox, centerBtnContainer, status, pasteEntry))
+		return
+	}
+
+	var loginButton *widget.Button
+	loginButton = widget.NewButtonWithIcon("Log in", theme.LoginIcon(), func() {
+		if stopLogin() {
+			return
 		}
+		ctx, cancel := context.WithTimeout(context.Background(), loginTimeout)
+		loginMutex.Lock()
+		cancelLogin = cancel
+		loginMutex.Unlock()
+
+		loginButton.SetText("Cancel")
+		progress.Show()
+		status.SetText("Waiting for the login in the browser...")
+
+		go func() {
+			defer func() {
+				stopLogin()
+				loginButton.SetText("Log in")
+				progress.Hide()
+			}()
+
+			token, err := curd.LoopbackLogin(ctx, &userCurdConfig, appW.OpenURL)
+			if err != nil {
+				log.Error("Login failed", err)
+				status.SetText(loginErrorMessage(err))
+				return
+			}
+			complete(token)
+		}()
 	})
-	validateButton.Importance = widget.HighImportance
-	centerBtnContainer := container.NewHBox(layout.NewSpacer(), validateButton, layout.NewSpacer())
+	loginButton.Importance = widget.HighImportance
+	centerBtnContainer := container.NewHBox(layout.NewSpacer(), loginButton, layout.NewSpacer())
 
-	window.SetContent(container.NewVBox(labelTitle, hyperlink, input, centerBtnContainer))
-	window.Show()
-	appW.Run()
+	redirectInfo := widget.NewLabelWithStyle(
+		fmt.Sprintf("The Anilist client %s must redirect to %s", userCurdConfig.AnilistClientID, curd.OAuthRedirectURL(userCurdConfig.OAuthRedirectPort)),
+		fyne.TextAlignCenter, fyne.TextStyle{Italic: true})
+	redirectInfo.Wrapping = fyne.TextWrapWord
+
+	// Fallback when the browser can't reach the listener
+	pasteContainer := container.NewVBox(pasteEntry)
+	if urlLink != nil {
+		hyperlink := widget.NewHyperlink("Website to generate token", urlLink)
+		hyperlink.Alignment = fyne.TextAlignCenter
+		pasteContainer.Objects = append([]fyne.CanvasObject{hyperlink}, pasteContainer.Objects...)
+	}
+	accordion := widget.NewAccordion(widget.NewAccordionItem("Paste a token instead", pasteContainer))
+
+	window.SetContent(container.NewVBox(labelTitle, profileRow, passphraseBox, centerBtnContainer, progress, status, redirectInfo, accordion))
+}
+
+// stopLogin cancels the running login, it returns false when there was none
+func stopLogin() bool {
+	loginMutex.Lock()
+	defer loginMutex.Unlock()
+	if cancelLogin == nil {
+		return false
+	}
+	cancelLogin()
+	cancelLogin = nil
+	return true
+}
+
+func loginErrorMessage(err error) string {
+	switch {
+	case err == context.Canceled:
+		return "Login cancelled"
+	case err == context.DeadlineExceeded:
+		return "Login timed out"
+	}
+	return fmt.Sprint("Login failed: ", err)
 }
 
 func processToken(t string) string {
@@ -63,15 +225,68 @@ func processToken(t string) string {
 	return token
 }
 
-func changeTokenCustom(t, tokenPath string, user *curd.User) bool {
-	token := processToken(t)
-	if token == "" {
-		return false
+// finishLogin validates the token with Anilist before saving it, so a wrong
+// token never reaches the main window. The error is already shown in status.
+func finishLogin(token curd.OAuthToken, tokenPath string, user *curd.User, status *widget.Label) error {
+	if token.Expired() {
+		status.SetText("This token has expired")
+		return errors.New("token expired")
 	}
-	user.Token = token
-	err := curd.WriteTokenToFile(token, tokenPath)
-	if err != nil {
-		return false
+
+	status.SetText("Checking the token...")
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	if err := curd.ValidateToken(ctx, token.AccessToken, user); err != nil {
+		log.Error(err)
+		if verniy.IsUnauthorized(err) {
+			status.SetText("Anilist refused this token")
+		} else {
+			status.SetText("Can't reach Anilist to check the token")
+		}
+		return err
 	}
-	return true
+	user.TokenExpiresAt = token.ExpiresAt
+
+	if err := curd.WriteTokenToFile(token.AccessToken, tokenPath); err != nil {
+		log.Error(err)
+		if !errors.Is(err, curd.ErrPassphraseNeeded) {
+			status.SetText("Can't save the token")
+		}
+		return err
+	}
+	if err := curd.WriteTokenExpiry(tokenPath, token.ExpiresAt); err != nil {
+		log.Error(err)
+	}
+	tokenLocked = false
+	status.SetText(fmt.Sprintf("Logged in as %s", user.Username))
+	return nil
+}
+
+// newUserBox shows the logged in account, the tooltip tells when the token
+// expires
+func newUserBox() fyne.CanvasObject {
+	avatar := canvas.NewImageFromResource(theme.AccountIcon())
+	avatar.FillMode = canvas.ImageFillContain
+	avatar.SetMinSize(fyne.NewSize(32, 32))
+
+	name := ttwidget.NewLabelWithStyle(user.Username, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	if !user.TokenExpiresAt.IsZero() {
+		name.SetToolTip(fmt.Sprintf("Login valid until %s", user.TokenExpiresAt.Format("2006-01-02")))
+	}
+
+	if user.AvatarURL != "" {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), coverLoadTimeout)
+			defer cancel()
+			img, err := imagecache.Default.Image(ctx, user.AvatarURL)
+			if err != nil {
+				log.Error("Can't load avatar", err)
+				return
+			}
+			avatar.Resource = nil
+			avatar.Image = img
+			avatar.Refresh()
+		}()
+	}
+	return container.NewHBox(avatar, name)
 }
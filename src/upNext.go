@@ -0,0 +1,123 @@
+package main
+
+import (
+	"AnimeGUI/src/anilist"
+	"AnimeGUI/verniy"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"strings"
+	"time"
+)
+
+var upNextOrders = []string{"Recently aired", "Recently watched"}
+
+// unwatchedBadge is the badge of a list row, empty for the anime not being
+// watched or already caught up
+func unwatchedBadge(entry verniy.MediaList) string {
+	if entry.Media == nil {
+		return ""
+	}
+	status := anilist.ListStatus(entry.Media.ID)
+	if status != verniy.MediaListStatusCurrent && status != verniy.MediaListStatusRepeating {
+		return ""
+	}
+	if unwatched := anilist.UnwatchedEpisodes(entry); unwatched > 0 {
+		return fmt.Sprintf("+%d", unwatched)
+	}
+	return ""
+}
+
+// upNextView is the queue of the anime with aired episodes left, each one
+// continues from the local resume position in a click
+type upNextView struct {
+	content *fyne.Container
+	list    *widget.List
+	order   *widget.Select
+	empty   *widget.Label
+	queue   []*verniy.MediaList
+}
+
+func newUpNextView() *upNextView {
+	v := &upNextView{empty: widget.NewLabelWithStyle("You are caught up", fyne.TextAlignCenter, fyne.TextStyle{Italic: true})}
+	v.empty.Hide()
+
+	v.list = widget.NewList(func() int { return len(v.queue) },
+		func() fyne.CanvasObject {
+			title := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			title.Truncation = fyne.TextTruncateEllipsis
+			info := widget.NewLabel("")
+			info.Truncation = fyne.TextTruncateEllipsis
+			play := widget.NewButtonWithIcon("Continue", theme.MediaPlayIcon(), nil)
+			play.Importance = widget.HighImportance
+			return container.NewBorder(nil, nil, nil, container.NewCenter(play), container.NewVBox(title, info))
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			if id >= len(v.queue) {
+				return
+			}
+			entry := v.queue[id]
+			row := o.(*fyne.Container)
+			texts := row.Objects[0].(*fyne.Container)
+			name := franchiseTitle(entry.Media)
+			texts.Objects[0].(*widget.Label).SetText(name)
+			texts.Objects[1].(*widget.Label).SetText(upNextInfo(entry))
+			play := row.Objects[1].(*fyne.Container).Objects[0].(*widget.Button)
+			play.OnTapped = func() {
+				if cancelResolving() {
+					return
+				}
+				OnPlayButtonClick(name, entry)
+			}
+		})
+
+	v.order = widget.NewSelect(upNextOrders, func(string) { v.refresh() })
+	v.order.Selected = upNextOrders[0]
+	refresh := widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), v.refresh)
+
+	top := container.NewHBox(widget.NewLabel("Sort by"), v.order, refresh)
+	v.content = container.NewBorder(top, nil, nil, nil, container.NewStack(v.list, v.empty))
+	// The rows are only there to be continued
+	v.list.OnSelected = func(widget.ListItemID) { v.list.UnselectAll() }
+	return v
+}
+
+// refresh rebuilds the queue from the list and the local history
+func (v *upNextView) refresh() {
+	order := anilist.RecentlyAired
+	if v.order.Selected == upNextOrders[1] {
+		order = anilist.RecentlyWatched
+	}
+	v.queue = anilist.UpNext(order, func(mediaID int) time.Time {
+		if local := SearchFromLocalAniId(mediaID); local != nil {
+			return local.LastWatched
+		}
+		return time.Time{}
+	})
+	if len(v.queue) == 0 {
+		v.empty.Show()
+	} else {
+		v.empty.Hide()
+	}
+	v.list.Refresh()
+}
+
+// upNextInfo is the episode a row continues, the unwatched ones and the
+// resume position when the episode was left halfway
+func upNextInfo(entry *verniy.MediaList) string {
+	progress := 0
+	if entry.Progress != nil {
+		progress = *entry.Progress
+	}
+	info := []string{fmt.Sprintf("Episode %d", progress+1)}
+	if unwatched := anilist.UnwatchedEpisodes(*entry); unwatched > 1 {
+		info = append(info, fmt.Sprintf("%d unwatched", unwatched))
+	}
+	// Playback resumes only when the saved episode is the next one
+	if local := SearchFromLocalAniId(entry.Media.ID); local != nil && local.Ep.Number == progress && local.Ep.Player.PlaybackTime > 0 {
+		info = append(info, fmt.Sprintf("resume at %s", time.Second*time.Duration(local.Ep.Player.PlaybackTime)))
+	}
+	return strings.Join(info, " · ")
+}
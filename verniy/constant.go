@@ -245,6 +245,21 @@ const (
 	StaffSortRelevance      StaffSort = "RELEVANCE"
 )
 
+// AiringSort is sorting option for airing schedule list.
+type AiringSort string
+
+// Options for AiringSort.
+const (
+	AiringSortID          AiringSort = "ID"
+	AiringSortIDDesc      AiringSort = "ID_DESC"
+	AiringSortMediaID     AiringSort = "MEDIA_ID"
+	AiringSortMediaIDDesc AiringSort = "MEDIA_ID_DESC"
+	AiringSortTime        AiringSort = "TIME"
+	AiringSortTimeDesc    AiringSort = "TIME_DESC"
+	AiringSortEpisode     AiringSort = "EPISODE"
+	AiringSortEpisodeDesc AiringSort = "EPISODE_DESC"
+)
+
 // MediaSort is sorting option for anime & manga list.
 type MediaSort string
 
@@ -65,6 +65,18 @@
 //	  return *str
 //	}
 //
+// # Mutation
+//
+// Saving, updating and deleting user's list entries need `AccessToken`
+// to be set in the client. Use `MediaListInput` and only fill the fields
+// you want to change.
+//
+//	progress := 5
+//	entry, err := v.SaveMediaListEntry(verniy.MediaListInput{
+//	  MediaID:  21,
+//	  Progress: &progress,
+//	})
+//
 // # Rate Limit
 //
 // Anilist has default rate limit 90 requests per minute. If you go over
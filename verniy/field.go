@@ -1930,6 +1930,25 @@ func PageFieldStaff(param PageParamStaff, field StaffField, fields ...StaffField
 	}, str...))
 }
 
+// PageFieldAiringSchedules to generate page airing schedule query.
+func PageFieldAiringSchedules(param PageParamAiringSchedules, field AiringScheduleField, fields ...AiringScheduleField) PageField {
+	str := []string{string(field)}
+	for _, f := range fields {
+		str = append(str, string(f))
+	}
+	return PageField(FieldObject("airingSchedules", QueryParam{
+		"id":               param.ID,
+		"mediaId":          param.MediaID,
+		"episode":          param.Episode,
+		"notYetAired":      param.NotYetAired,
+		"id_in":            param.IDIn,
+		"mediaId_in":       param.MediaIDIn,
+		"airingAt_greater": param.AiringAtGreater,
+		"airingAt_lesser":  param.AiringAtLesser,
+		"sort":             param.Sort,
+	}, str...))
+}
+
 // MediaListCollectionField is fields for media list collection.
 type MediaListCollectionField string
 
@@ -24,18 +24,60 @@ type errorResponse struct {
 	} `json:"errors"`
 }
 
-func (c *Client) handleError(body []byte) error {
-	var e errorResponse
-	if err := json.Unmarshal(body, &e); err != nil {
-		return err
+// Error is an error response of the API.
+type Error struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Statuses are the status codes of each error in the response.
+	Statuses []int
+	Messages []string
+}
+
+func (e *Error) Error() string {
+	if len(e.Messages) == 0 {
+		return http.StatusText(e.StatusCode)
+	}
+	return strings.Join(e.Messages, " | ")
+}
+
+// IsUnauthorized to check if the error means the access token is invalid
+// or expired. Network errors and other API errors return false.
+func IsUnauthorized(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	if e.StatusCode == http.StatusUnauthorized {
+		return true
+	}
+	for _, s := range e.Statuses {
+		if s == http.StatusUnauthorized {
+			return true
+		}
+	}
+	// Anilist answers a bad token with 400 "Invalid token".
+	for _, m := range e.Messages {
+		if strings.EqualFold(m, "invalid token") {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) handleError(code int, body []byte) error {
+	e := &Error{StatusCode: code}
+
+	var r errorResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return e
 	}
 
-	errMsgs := make([]string, len(e.Errors))
-	for i, b := range e.Errors {
-		errMsgs[i] = b.Message
+	for _, b := range r.Errors {
+		e.Statuses = append(e.Statuses, b.Status)
+		e.Messages = append(e.Messages, b.Message)
 	}
 
-	return errors.New(strings.Join(errMsgs, " | "))
+	return e
 }
 
 func (c *Client) post(ctx context.Context, query string, v map[string]interface{}, model interface{}) error {
@@ -53,7 +95,7 @@ func (c *Client) post(ctx context.Context, query string, v map[string]interface{
 	}
 
 	if code != http.StatusOK {
-		return c.handleError(body)
+		return c.handleError(code, body)
 	}
 
 	if err = json.Unmarshal(body, &model); err != nil {
@@ -371,7 +371,7 @@ type MediaList struct {
 	Private               *bool            `json:"private"`
 	Notes                 *string          `json:"notes"`
 	HiddenFromStatusLists *bool            `json:"hiddenFromStatusLists"`
-	CustomLists           *string          `json:"customLists"`    // json
+	CustomLists           map[string]bool  `json:"customLists"`    // every custom list of the user, true when the entry is in it
 	AdvancedScores        *string          `json:"advancedScores"` // json
 	StartedAt             *FuzzyDate       `json:"startedAt"`
 	CompletedAt           *FuzzyDate       `json:"completedAt"`
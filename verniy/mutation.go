@@ -0,0 +1,199 @@
+package verniy
+
+import "context"
+
+// MediaListInput is input to save or bulk update user's anime & manga entry.
+//
+// Only non-nil (and non-empty) fields are sent to Anilist, so fields
+// you don't set will be left untouched. CustomLists is sent when it isn't
+// nil, an empty slice removes the entry from every custom list.
+type MediaListInput struct {
+	ID          int
+	MediaID     int
+	Status      MediaListStatus
+	Score       *float64
+	Progress    *int
+	Repeat      *int
+	Private     *bool
+	Notes       *string
+	CustomLists []string
+	StartedAt   *FuzzyDate
+	CompletedAt *FuzzyDate
+}
+
+type saveMediaListEntryResponse struct {
+	Data struct {
+		SaveMediaListEntry MediaList `json:"saveMediaListEntry"`
+	} `json:"data"`
+}
+
+type deleteMediaListEntryResponse struct {
+	Data struct {
+		DeleteMediaListEntry struct {
+			Deleted *bool `json:"deleted"`
+		} `json:"deleteMediaListEntry"`
+	} `json:"data"`
+}
+
+type updateMediaListEntriesResponse struct {
+	Data struct {
+		UpdateMediaListEntries []MediaList `json:"updateMediaListEntries"`
+	} `json:"data"`
+}
+
+var defaultMediaListMutationFields = []MediaListField{
+	MediaListFieldID,
+	MediaListFieldMediaID,
+	MediaListFieldStatus,
+	MediaListFieldScore,
+	MediaListFieldProgress,
+	MediaListFieldRepeat,
+	MediaListFieldPrivate,
+	MediaListFieldNotes,
+	MediaListFieldStartedAt,
+	MediaListFieldCompletedAt,
+	MediaListFieldUpdatedAt,
+}
+
+func (c *Client) mutationQuery(key string, params QueryParam, fields ...MediaListField) string {
+	p := make([]string, len(fields))
+	for i := range fields {
+		p[i] = string(fields[i])
+	}
+	return FieldObject(key, params, p...)
+}
+
+// toParams converts the input to graphql variable declarations, the
+// argument list of the mutation and the variable values.
+func (i MediaListInput) toParams() (QueryParam, QueryParam, queryVariable) {
+	types, params, vars := QueryParam{}, QueryParam{}, queryVariable{}
+	add := func(name, gqlType string, value interface{}) {
+		types["$"+name] = gqlType
+		params[name] = "$" + name
+		vars[name] = value
+	}
+
+	if i.ID != 0 {
+		add("id", "Int", i.ID)
+	}
+	if i.MediaID != 0 {
+		add("mediaId", "Int", i.MediaID)
+	}
+	if i.Status != "" {
+		add("status", "MediaListStatus", i.Status)
+	}
+	if i.Score != nil {
+		add("score", "Float", *i.Score)
+	}
+	if i.Progress != nil {
+		add("progress", "Int", *i.Progress)
+	}
+	if i.Repeat != nil {
+		add("repeat", "Int", *i.Repeat)
+	}
+	if i.Private != nil {
+		add("private", "Boolean", *i.Private)
+	}
+	if i.Notes != nil {
+		add("notes", "String", *i.Notes)
+	}
+	if i.CustomLists != nil {
+		add("customLists", "[String]", i.CustomLists)
+	}
+	if i.StartedAt != nil {
+		add("startedAt", "FuzzyDateInput", i.StartedAt)
+	}
+	if i.CompletedAt != nil {
+		add("completedAt", "FuzzyDateInput", i.CompletedAt)
+	}
+
+	return types, params, vars
+}
+
+// SaveMediaListEntry to create or update user's anime & manga entry.
+// Need access token.
+func (c *Client) SaveMediaListEntry(input MediaListInput, fields ...MediaListField) (*MediaList, error) {
+	return c.SaveMediaListEntryWithContext(context.Background(), input, fields...)
+}
+
+// SaveMediaListEntryWithContext to create or update user's anime & manga entry with context.
+// Need access token.
+func (c *Client) SaveMediaListEntryWithContext(ctx context.Context, input MediaListInput, fields ...MediaListField) (*MediaList, error) {
+	if len(fields) == 0 {
+		fields = defaultMediaListMutationFields
+	}
+
+	types, params, vars := input.toParams()
+	query := FieldObject("mutation", types, c.mutationQuery("SaveMediaListEntry", params, fields...))
+
+	var d saveMediaListEntryResponse
+	err := c.post(ctx, query, vars, &d)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d.Data.SaveMediaListEntry, nil
+}
+
+// DeleteMediaListEntry to delete user's anime & manga entry.
+// The id is the entry id (MediaList.ID), not the media id.
+// Need access token.
+func (c *Client) DeleteMediaListEntry(id int) (bool, error) {
+	return c.DeleteMediaListEntryWithContext(context.Background(), id)
+}
+
+// DeleteMediaListEntryWithContext to delete user's anime & manga entry with context.
+// Need access token.
+func (c *Client) DeleteMediaListEntryWithContext(ctx context.Context, id int) (bool, error) {
+	query := FieldObject("mutation", QueryParam{
+		"$id": "Int",
+	}, FieldObject("DeleteMediaListEntry", QueryParam{
+		"id": "$id",
+	}, "deleted"))
+
+	var d deleteMediaListEntryResponse
+	err := c.post(ctx, query, queryVariable{
+		"id": id,
+	}, &d)
+	if err != nil {
+		return false, err
+	}
+
+	if d.Data.DeleteMediaListEntry.Deleted == nil {
+		return false, nil
+	}
+	return *d.Data.DeleteMediaListEntry.Deleted, nil
+}
+
+// UpdateMediaListEntries to apply the same changes to multiple user's
+// anime & manga entries. ID and MediaID of the input are ignored,
+// entries are selected by their entry ids.
+// Need access token.
+func (c *Client) UpdateMediaListEntries(input MediaListInput, ids []int, fields ...MediaListField) ([]MediaList, error) {
+	return c.UpdateMediaListEntriesWithContext(context.Background(), input, ids, fields...)
+}
+
+// UpdateMediaListEntriesWithContext to apply the same changes to multiple
+// user's anime & manga entries with context.
+// Need access token.
+func (c *Client) UpdateMediaListEntriesWithContext(ctx context.Context, input MediaListInput, ids []int, fields ...MediaListField) ([]MediaList, error) {
+	if len(fields) == 0 {
+		fields = defaultMediaListMutationFields
+	}
+
+	input.ID, input.MediaID = 0, 0
+	types, params, vars := input.toParams()
+	types["$ids"] = "[Int]"
+	params["ids"] = "$ids"
+	vars["ids"] = ids
+
+	query := FieldObject("mutation", types, c.mutationQuery("UpdateMediaListEntries", params, fields...))
+
+	var d updateMediaListEntriesResponse
+	err := c.post(ctx, query, vars, &d)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Data.UpdateMediaListEntries, nil
+}
@@ -10,11 +10,12 @@ type pageResponse struct {
 
 // Page is pagination response from anilist.
 type Page struct {
-	PageInfo   PageInfo    `json:"pageInfo"`
-	Media      []Media     `json:"media"`
-	Characters []Character `json:"characters"`
-	Staff      []Staff     `json:"staff"`
-	Studios    []Studio    `json:"studios"`
+	PageInfo        PageInfo         `json:"pageInfo"`
+	Media           []Media          `json:"media"`
+	Characters      []Character      `json:"characters"`
+	Staff           []Staff          `json:"staff"`
+	Studios         []Studio         `json:"studios"`
+	AiringSchedules []AiringSchedule `json:"airingSchedules"`
 }
 
 func (c *Client) pageQuery(params QueryParam, fields ...PageField) string {
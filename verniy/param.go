@@ -208,6 +208,19 @@ type PageParamCharacters struct {
 	Sort       []CharacterSort
 }
 
+// PageParamAiringSchedules is page param for airing schedules.
+type PageParamAiringSchedules struct {
+	ID              int
+	MediaID         int
+	Episode         int
+	NotYetAired     *bool
+	IDIn            []int
+	MediaIDIn       []int
+	AiringAtGreater int
+	AiringAtLesser  int
+	Sort            []AiringSort
+}
+
 // PageParamStaff is page param for staff.
 type PageParamStaff struct {
 	ID         int
@@ -129,3 +129,23 @@ func (c *Client) SearchStaffWithContext(ctx context.Context, query PageParamStaf
 	}
 	return c.page(ctx, page, perPage, PageFieldStaff(query, "", fields...))
 }
+
+// SearchAiringSchedules to search airing schedules.
+func (c *Client) SearchAiringSchedules(query PageParamAiringSchedules, page int, perPage int, fields ...AiringScheduleField) (*Page, error) {
+	return c.SearchAiringSchedulesWithContext(context.Background(), query, page, perPage, fields...)
+}
+
+// SearchAiringSchedulesWithContext to search airing schedules with context.
+func (c *Client) SearchAiringSchedulesWithContext(ctx context.Context, query PageParamAiringSchedules, page int, perPage int, fields ...AiringScheduleField) (*Page, error) {
+	if len(fields) == 0 {
+		fields = []AiringScheduleField{
+			AiringScheduleFieldMediaID,
+			AiringScheduleFieldEpisode,
+			AiringScheduleFieldAiringAt,
+		}
+	}
+	if len(query.Sort) == 0 {
+		query.Sort = []AiringSort{AiringSortTime}
+	}
+	return c.page(ctx, page, perPage, PageFieldAiringSchedules(query, "", fields...))
+}
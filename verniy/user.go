@@ -65,6 +65,41 @@ func (c *Client) GetUserWithContext(ctx context.Context, username string, fields
 	return &d.Data.User, nil
 }
 
+type viewerResponse struct {
+	Data struct {
+		Viewer User `json:"viewer"`
+	} `json:"data"`
+}
+
+// GetViewer to get the user owning the access token.
+func (c *Client) GetViewer(fields ...UserField) (*User, error) {
+	return c.GetViewerWithContext(context.Background(), fields...)
+}
+
+// GetViewerWithContext to get the user owning the access token with context.
+func (c *Client) GetViewerWithContext(ctx context.Context, fields ...UserField) (*User, error) {
+	if len(fields) == 0 {
+		fields = []UserField{
+			UserFieldID,
+			UserFieldName,
+			UserFieldAvatar(UserAvatarFieldLarge, UserAvatarFieldMedium),
+		}
+	}
+
+	p := make([]string, len(fields))
+	for i := range fields {
+		p[i] = string(fields[i])
+	}
+	query := FieldObject("query", nil, FieldObject("Viewer", nil, p...))
+
+	var d viewerResponse
+	if err := c.post(ctx, query, nil, &d); err != nil {
+		return nil, err
+	}
+
+	return &d.Data.Viewer, nil
+}
+
 // GetUserFavouriteAnime to get user's favourite anime.
 func (c *Client) GetUserFavouriteAnime(username string, page int, perPage int) (*User, error) {
 	return c.GetUserFavouriteAnimeWithContext(context.Background(), username, page, perPage)